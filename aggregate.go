@@ -0,0 +1,459 @@
+package main
+
+import (
+	"container/heap"
+	"math"
+	"slices"
+	"strings"
+)
+
+// interface of the functions that aggregate the values of the minutes of a window into one value (--agg)
+// the values are pushed one at a time, and the result is calculated with the values pushed since the last reset
+// an aggregator without values has a result of 0
+type Aggregator interface {
+	Push(duration int)
+	Result() float64
+	Reset()
+}
+
+// interface of the aggregators that are updated as the window slides, instead of aggregating the whole window each minute
+// Add is called with the value of the minute that enters the window and Remove with the value of the one that leaves it
+// like with Push, the empty minutes are not added or removed
+type SlidingAggregator interface {
+	Aggregator
+	Add(duration int)
+	Remove(duration int)
+}
+
+// registry of the aggregators, by the name used in the --agg flag
+// adding an aggregation is implementing the interface and adding it here
+// the aggregators that also implement SlidingAggregator are slid with the window, see slideWindow
+var aggregators = map[string]func(options options) Aggregator{
+	"mean":         func(options options) Aggregator { return &meanAggregator{} },
+	"trimmed-mean": func(options options) Aggregator { return &trimmedMeanAggregator{trim: options.trim} },
+	"median":       func(options options) Aggregator { return newMedianAggregator() },
+	"max":          func(options options) Aggregator { return &maxAggregator{} },
+	"min":          func(options options) Aggregator { return &minAggregator{} },
+	"sum":          func(options options) Aggregator { return &sumAggregator{} },
+
+	"percentile":        func(options options) Aggregator { return &percentileAggregator{percentile: options.percentile} },
+	"approx-percentile": func(options options) Aggregator { return newApproxPercentileAggregator(options.percentile) },
+}
+
+// function to get the sorted names of the aggregators, for the error messages
+func aggregatorNames() string {
+	var names []string
+	for name := range aggregators {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	return strings.Join(names, ", ")
+}
+
+// function to create the aggregator of the options, the mean if it is not set
+func newAggregator(options options) Aggregator {
+	factory, ok := aggregators[options.metric]
+	if !ok {
+		factory = aggregators["mean"]
+	}
+
+	return factory(options)
+}
+
+// function to aggregate the values of the minutes of a window
+// this condition is necessary to be compliant with the example given that excludes minutes with no deliveries from the calculations
+// so only the values bigger than 0 are pushed
+func aggregateWindow(aggregator Aggregator, movingAverageQueue []int) float64 {
+	aggregator.Reset()
+
+	for _, value := range movingAverageQueue {
+		if value > 0 {
+			aggregator.Push(value)
+		}
+	}
+
+	return aggregator.Result()
+}
+
+// function to calculate the mean of the deliveries of a window (--average-mode=per-delivery)
+// the sum of the durations of the minutes divided by the number of deliveries of the minutes, 0 without deliveries
+// unlike the mean of the minutes, a minute with more deliveries has more weight
+func deliveriesMean(movingAverageQueue []int, deliveriesCountQueue []int) float64 {
+	var count = sumQueue(deliveriesCountQueue)
+	if count == 0 {
+		return 0
+	}
+	return float64(sumQueue(movingAverageQueue)) / float64(count)
+}
+
+// function to slide the window of an aggregator, with the value of the minute that enters the window
+// and, if the window is full, the value of the minute that leaves it
+func slideWindow(aggregator SlidingAggregator, added int, removed int, hasRemoved bool) float64 {
+	if added > 0 {
+		aggregator.Add(added)
+	}
+	if hasRemoved && removed > 0 {
+		aggregator.Remove(removed)
+	}
+
+	return aggregator.Result()
+}
+
+// aggregator with the moving average of the window, the sum of the values divided by how many there are
+type meanAggregator struct {
+	sum   int
+	count int
+}
+
+func (aggregator *meanAggregator) Push(duration int) {
+	aggregator.sum += duration
+	aggregator.count++
+}
+
+func (aggregator *meanAggregator) Result() float64 {
+	// guarding against the case that the file has in interval larger than the window size
+	// in that case the default value is 0
+	if aggregator.count == 0 {
+		return 0
+	}
+	return float64(aggregator.sum) / float64(aggregator.count)
+}
+
+func (aggregator *meanAggregator) Reset() {
+	aggregator.sum, aggregator.count = 0, 0
+}
+
+// aggregator with the trimmed mean of the window
+// the values are sorted and the lowest and highest trim fraction of them are discarded before averaging
+type trimmedMeanAggregator struct {
+	trim   float64
+	values []int
+}
+
+func (aggregator *trimmedMeanAggregator) Push(duration int) {
+	aggregator.values = append(aggregator.values, duration)
+}
+
+func (aggregator *trimmedMeanAggregator) Result() float64 {
+	var values = aggregator.values
+	slices.Sort(values)
+
+	// number of values discarded from each end, rounded down
+	var discarded = int(float64(len(values)) * aggregator.trim)
+	values = values[discarded : len(values)-discarded]
+
+	if len(values) == 0 {
+		return 0
+	}
+
+	var sum int
+	for _, value := range values {
+		sum += value
+	}
+
+	return float64(sum) / float64(len(values))
+}
+
+func (aggregator *trimmedMeanAggregator) Reset() {
+	aggregator.values = aggregator.values[:0]
+}
+
+// aggregator with the median of the window, the average of the two middle values if there is an even number of them
+// the values are kept in two heaps, the lower half in a max heap and the upper half in a min heap, so the median is at their tops
+// it is a sliding aggregator, a minute is added and removed in logarithmic time instead of sorting the window each minute
+// the removed values are only dropped when they reach the top of their heap, until then they are counted in delayed
+type medianAggregator struct {
+	// the lower half is stored negated, so the min heap has the highest value at the top
+	lower intHeap
+	upper intHeap
+
+	// number of values of each half that are not removed, the lower half has the extra value of an odd count
+	lowerCount int
+	upperCount int
+
+	// number of removed values still in the heaps, by value
+	delayed map[int]int
+}
+
+// function to create the aggregator of the median
+func newMedianAggregator() *medianAggregator {
+	return &medianAggregator{delayed: make(map[int]int)}
+}
+
+func (aggregator *medianAggregator) Push(duration int) {
+	aggregator.Add(duration)
+}
+
+func (aggregator *medianAggregator) Add(duration int) {
+	if aggregator.lowerCount == 0 || duration <= -aggregator.lower[0] {
+		heap.Push(&aggregator.lower, -duration)
+		aggregator.lowerCount++
+	} else {
+		heap.Push(&aggregator.upper, duration)
+		aggregator.upperCount++
+	}
+
+	aggregator.balance()
+}
+
+// the value must have been added, the tops of the heaps are never removed values, so the half of the value is known
+func (aggregator *medianAggregator) Remove(duration int) {
+	aggregator.delayed[duration]++
+
+	if aggregator.lowerCount > 0 && duration <= -aggregator.lower[0] {
+		aggregator.lowerCount--
+		if duration == -aggregator.lower[0] {
+			aggregator.prune(&aggregator.lower, -1)
+		}
+	} else {
+		aggregator.upperCount--
+		if duration == aggregator.upper[0] {
+			aggregator.prune(&aggregator.upper, 1)
+		}
+	}
+
+	aggregator.balance()
+}
+
+// function to move the top of one heap to the other, so the lower half has the same number of values or one more
+func (aggregator *medianAggregator) balance() {
+	if aggregator.lowerCount > aggregator.upperCount+1 {
+		heap.Push(&aggregator.upper, -heap.Pop(&aggregator.lower).(int))
+		aggregator.lowerCount--
+		aggregator.upperCount++
+		aggregator.prune(&aggregator.lower, -1)
+	} else if aggregator.lowerCount < aggregator.upperCount {
+		heap.Push(&aggregator.lower, -heap.Pop(&aggregator.upper).(int))
+		aggregator.upperCount--
+		aggregator.lowerCount++
+		aggregator.prune(&aggregator.upper, 1)
+	}
+}
+
+// function to drop the removed values from the top of a heap, the sign is -1 for the negated lower half
+func (aggregator *medianAggregator) prune(values *intHeap, sign int) {
+	for len(*values) > 0 {
+		top := sign * (*values)[0]
+		if aggregator.delayed[top] == 0 {
+			return
+		}
+
+		if aggregator.delayed[top]--; aggregator.delayed[top] == 0 {
+			delete(aggregator.delayed, top)
+		}
+		heap.Pop(values)
+	}
+}
+
+func (aggregator *medianAggregator) Result() float64 {
+	if aggregator.lowerCount == 0 {
+		return 0
+	}
+
+	if aggregator.lowerCount > aggregator.upperCount {
+		return float64(-aggregator.lower[0])
+	}
+	return float64(-aggregator.lower[0]+aggregator.upper[0]) / 2
+}
+
+func (aggregator *medianAggregator) Reset() {
+	*aggregator = medianAggregator{lower: aggregator.lower[:0], upper: aggregator.upper[:0], delayed: aggregator.delayed}
+	clear(aggregator.delayed)
+}
+
+// type of a min heap of integers, for container/heap
+type intHeap []int
+
+func (values intHeap) Len() int           { return len(values) }
+func (values intHeap) Less(i, j int) bool { return values[i] < values[j] }
+func (values intHeap) Swap(i, j int)      { values[i], values[j] = values[j], values[i] }
+
+func (values *intHeap) Push(value any) {
+	*values = append(*values, value.(int))
+}
+
+func (values *intHeap) Pop() any {
+	old := *values
+	value := old[len(old)-1]
+	*values = old[:len(old)-1]
+	return value
+}
+
+// aggregator with the highest value of the window
+type maxAggregator struct {
+	max    int
+	pushed bool
+}
+
+func (aggregator *maxAggregator) Push(duration int) {
+	if !aggregator.pushed || duration > aggregator.max {
+		aggregator.max = duration
+	}
+	aggregator.pushed = true
+}
+
+func (aggregator *maxAggregator) Result() float64 {
+	return float64(aggregator.max)
+}
+
+func (aggregator *maxAggregator) Reset() {
+	aggregator.max, aggregator.pushed = 0, false
+}
+
+// aggregator with the lowest value of the window
+type minAggregator struct {
+	min    int
+	pushed bool
+}
+
+func (aggregator *minAggregator) Push(duration int) {
+	if !aggregator.pushed || duration < aggregator.min {
+		aggregator.min = duration
+	}
+	aggregator.pushed = true
+}
+
+func (aggregator *minAggregator) Result() float64 {
+	return float64(aggregator.min)
+}
+
+func (aggregator *minAggregator) Reset() {
+	aggregator.min, aggregator.pushed = 0, false
+}
+
+// aggregator with the sum of the values of the window
+type sumAggregator struct {
+	sum int
+}
+
+func (aggregator *sumAggregator) Push(duration int) {
+	aggregator.sum += duration
+}
+
+func (aggregator *sumAggregator) Result() float64 {
+	return float64(aggregator.sum)
+}
+
+func (aggregator *sumAggregator) Reset() {
+	aggregator.sum = 0
+}
+
+// aggregator with a percentile of the window, like 95, interpolated between the two closest values
+// every value is kept and sorted, see approxPercentileAggregator for big windows
+type percentileAggregator struct {
+	percentile float64
+	values     []int
+}
+
+func (aggregator *percentileAggregator) Push(duration int) {
+	aggregator.values = append(aggregator.values, duration)
+}
+
+func (aggregator *percentileAggregator) Result() float64 {
+	var values = aggregator.values
+	if len(values) == 0 {
+		return 0
+	}
+
+	slices.Sort(values)
+
+	// the rank is the position of the percentile between the first (0) and the last value (len - 1)
+	rank := aggregator.percentile / 100 * float64(len(values)-1)
+	lower := int(math.Floor(rank))
+	if lower == len(values)-1 {
+		return float64(values[lower])
+	}
+	return float64(values[lower]) + (rank-float64(lower))*float64(values[lower+1]-values[lower])
+}
+
+func (aggregator *percentileAggregator) Reset() {
+	aggregator.values = aggregator.values[:0]
+}
+
+// aggregator with an approximate percentile of the window, with a histogram of buckets of logarithmic width
+// every value is counted in the bucket of its logarithm, so the percentile is within 1% of a value of the window
+// the values that enter and leave the window change the count of their bucket, so the aggregator slides with the window
+// and its memory and its time per minute depend on the number of buckets, a few hundreds, instead of the size of the window
+type approxPercentileAggregator struct {
+	percentile float64
+
+	// number of values of each bucket, by its index, and the number of values of all of them
+	counts []int
+	count  int
+}
+
+// relative accuracy of the buckets of approxPercentileAggregator, and the ratio between the bounds of a bucket
+const approxPercentileAccuracy = 0.01
+
+var approxPercentileGamma = (1 + approxPercentileAccuracy) / (1 - approxPercentileAccuracy)
+
+// function to create the aggregator of the percentile, like 95
+func newApproxPercentileAggregator(percentile float64) *approxPercentileAggregator {
+	return &approxPercentileAggregator{percentile: percentile}
+}
+
+// function to get the index of the bucket of a value, the values bigger than gamma^(i-1) and up to gamma^i
+func approxPercentileBucket(duration int) int {
+	return max(int(math.Ceil(math.Log(float64(duration))/math.Log(approxPercentileGamma))), 0)
+}
+
+// function to get the value of a bucket, the one with the same relative error to its two bounds
+func approxPercentileValue(bucket int) float64 {
+	return 2 * math.Pow(approxPercentileGamma, float64(bucket)) / (approxPercentileGamma + 1)
+}
+
+func (aggregator *approxPercentileAggregator) Push(duration int) {
+	aggregator.Add(duration)
+}
+
+func (aggregator *approxPercentileAggregator) Add(duration int) {
+	bucket := approxPercentileBucket(duration)
+	if bucket >= len(aggregator.counts) {
+		aggregator.counts = append(aggregator.counts, make([]int, bucket+1-len(aggregator.counts))...)
+	}
+	aggregator.counts[bucket]++
+	aggregator.count++
+}
+
+func (aggregator *approxPercentileAggregator) Remove(duration int) {
+	aggregator.counts[approxPercentileBucket(duration)]--
+	aggregator.count--
+}
+
+// the percentile is interpolated between the values of the buckets of the two closest ranks, like percentileAggregator
+func (aggregator *approxPercentileAggregator) Result() float64 {
+	if aggregator.count == 0 {
+		return 0
+	}
+
+	var rank = aggregator.percentile / 100 * float64(aggregator.count-1)
+	var lower = int(rank)
+
+	// the buckets of the ranks lower and lower+1, walking the buckets in order
+	var lowerValue, upperValue float64
+	var seen int
+	for bucket, count := range aggregator.counts {
+		if count == 0 {
+			continue
+		}
+		if seen <= lower && lower < seen+count {
+			lowerValue = approxPercentileValue(bucket)
+		}
+		if seen <= lower+1 && lower+1 < seen+count {
+			upperValue = approxPercentileValue(bucket)
+			break
+		}
+		seen += count
+	}
+	if lower+1 >= aggregator.count {
+		return lowerValue
+	}
+
+	return lowerValue + (rank-float64(lower))*(upperValue-lowerValue)
+}
+
+func (aggregator *approxPercentileAggregator) Reset() {
+	clear(aggregator.counts)
+	aggregator.count = 0
+}
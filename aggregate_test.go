@@ -0,0 +1,357 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_aggregators(t *testing.T) {
+
+	// the empty minutes are not pushed
+	window := []int{30, 0, 10, 50, 0, 20}
+
+	for _, test := range []struct {
+		name     string
+		window   []int
+		expected float64
+	}{
+		{"mean", window, 27.5},
+		{"mean", []int{0, 0}, 0},
+		{"max", window, 50},
+		{"max", []int{0, 0}, 0},
+		{"min", window, 10},
+		{"median", window, 25},
+		{"median", []int{30, 10, 50}, 30},
+		{"median", []int{7}, 7},
+		{"median", []int{0}, 0},
+		{"sum", window, 110},
+	} {
+		aggregator := aggregators[test.name](options{})
+
+		// the aggregators are reused, so the result must not depend on the previous windows
+		aggregateWindow(aggregator, []int{1000, 2000, 3000})
+
+		if result := aggregateWindow(aggregator, test.window); result != test.expected {
+			t.Errorf("Expected the %s of %v to be %f, got %f", test.name, test.window, test.expected, result)
+		}
+	}
+}
+
+func Test_main_Agg(t *testing.T) {
+
+	// the window of 18:24 has the minutes with 20, 31 and 54
+	for agg, expected := range map[string]float64{"mean": 35, "median": 31, "max": 54, "sum": 105} {
+		data := getContentFromConsole(t, "--input_file=./events.json", "--window_size=15", "--agg="+agg)
+
+		if last := data[len(data)-1]; last.Average_delivery_time != expected {
+			t.Errorf("Expected the %s of the last minute to be %f, got %f", agg, expected, last.Average_delivery_time)
+		}
+	}
+
+	if err := run(context.Background(), []string{"--input_file=./events.json", "--agg=mode"}, io.Discard, io.Discard); err == nil {
+		t.Errorf("Expected an error for an unknown aggregation")
+	}
+}
+
+func Test_percentileAggregators(t *testing.T) {
+
+	// the rank of the 95th percentile of 5 values is 3.8, between 40 and 50
+	for _, test := range []struct {
+		percentile float64
+		values     []int
+		expected   float64
+	}{
+		{95, []int{50, 10, 40, 20, 30}, 48},
+		{50, []int{50, 10, 40, 20}, 30},
+		{100, []int{50, 10, 40}, 50},
+		{95, []int{7}, 7},
+		{95, nil, 0},
+	} {
+		exact := aggregators["percentile"](options{percentile: test.percentile})
+		approx := aggregators["approx-percentile"](options{percentile: test.percentile})
+
+		// the approximation is within 1% of the exact percentile
+		for _, aggregator := range []Aggregator{exact, approx} {
+			aggregateWindow(aggregator, []int{1000, 2000, 3000, 4000, 5000, 6000})
+
+			if result := aggregateWindow(aggregator, test.values); math.Abs(result-test.expected) > 0.01*test.expected+1e-9 {
+				t.Errorf("Expected the %v percentile of %v to be %f, got %f with %T", test.percentile, test.values, test.expected, result, aggregator)
+			}
+		}
+	}
+}
+
+func Test_approxPercentileAggregator(t *testing.T) {
+
+	// durations with a long tail, like the deliveries
+	var random = rand.New(rand.NewSource(1))
+	var window = make([]int, 100000)
+	for i := range window {
+		window[i] = 1 + int(math.Exp(3+random.NormFloat64()))
+	}
+
+	for _, percentile := range []float64{50, 95, 99} {
+		exact := aggregateWindow(aggregators["percentile"](options{percentile: percentile}), window)
+		approx := aggregateWindow(aggregators["approx-percentile"](options{percentile: percentile}), window)
+
+		if math.Abs(approx-exact) > 0.01*exact {
+			t.Errorf("Expected the approximate %v percentile to be within 1%% of %f, got %f", percentile, exact, approx)
+		}
+	}
+
+	// sliding a window of 1000 minutes, with empty minutes, against the exact percentile of each window
+	var sliding = newApproxPercentileAggregator(95)
+	var exact = aggregators["percentile"](options{percentile: 95})
+	for i := range window[:5000] {
+		if i%7 == 0 {
+			window[i] = 0
+		}
+		expected := aggregateWindow(exact, window[max(i-999, 0):i+1])
+
+		if result := slideWindow(sliding, window[i], window[max(i-1000, 0)], i >= 1000); math.Abs(result-expected) > 0.01*expected {
+			t.Fatalf("Expected the approximate 95 percentile of the minute %d to be within 1%% of %f, got %f", i, expected, result)
+		}
+	}
+}
+
+func Test_main_ApproxPercentile(t *testing.T) {
+
+	// one delivery every minute for 2000 minutes, with a window of 1000 minutes
+	var random = rand.New(rand.NewSource(2))
+	var events strings.Builder
+	var start = time.Date(2018, 12, 26, 0, 0, 0, 0, time.UTC)
+	for minute := 0; minute < 2000; minute++ {
+		fmt.Fprintf(&events, `{"timestamp": "%s","duration": %d}`+"\n", start.Add(time.Duration(minute)*time.Minute).Format("2006-01-02 15:04:05"), 1+int(math.Exp(3+random.NormFloat64())))
+	}
+	inputFilePath := filepath.Join(t.TempDir(), "events.json")
+	if err := os.WriteFile(inputFilePath, []byte(events.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	exact := getContentFromConsole(t, "--input_file="+inputFilePath, "--window_size=1000", "--full-window-only", "--metric=percentile", "--percentile=95")
+	approx := getContentFromConsole(t, "--input_file="+inputFilePath, "--window_size=1000", "--full-window-only", "--approx-percentile=95")
+
+	if len(exact) == 0 || len(approx) != len(exact) {
+		t.Fatalf("Expected the same minutes, got %d and %d", len(exact), len(approx))
+	}
+	for i := range exact {
+		if math.Abs(approx[i].Average_delivery_time-exact[i].Average_delivery_time) > 0.01*exact[i].Average_delivery_time {
+			t.Errorf("Expected the approximate p95 of %s to be within 1%% of %f, got %f", exact[i].Date, exact[i].Average_delivery_time, approx[i].Average_delivery_time)
+		}
+	}
+
+	if err := run(context.Background(), []string{"--input_file=./events.json", "--approx-percentile=101"}, io.Discard, io.Discard); err == nil {
+		t.Errorf("Expected an error for a percentile above 100")
+	}
+}
+
+// the approximate percentile slid with the window against the exact one, that sorts the window each minute
+// the time per minute of the approximation doesn't grow with the size of the window
+func BenchmarkApproxPercentile(b *testing.B) {
+
+	var random = rand.New(rand.NewSource(5))
+	var minutes = make([]int, 20000)
+	for i := range minutes {
+		minutes[i] = 1 + int(math.Exp(3+random.NormFloat64()))
+	}
+
+	for _, windowSize := range []int{60, 1440, 10080} {
+		b.Run(fmt.Sprintf("approx/window=%d", windowSize), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				var sliding = newApproxPercentileAggregator(95)
+				for j, minute := range minutes {
+					slideWindow(sliding, minute, minutes[max(j-windowSize, 0)], j >= windowSize)
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("exact/window=%d", windowSize), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				var sorting = aggregators["percentile"](options{percentile: 95})
+				for j := range minutes {
+					aggregateWindow(sorting, minutes[max(j-windowSize+1, 0):j+1])
+				}
+			}
+		})
+	}
+}
+
+func Test_main_AverageMode(t *testing.T) {
+
+	// two deliveries of 10 and 30 in the first minute and one of 20 in the second
+	events := `{"timestamp": "2018-12-26 18:11:08","duration": 10}
+{"timestamp": "2018-12-26 18:11:40","duration": 30}
+{"timestamp": "2018-12-26 18:12:20","duration": 20}
+`
+	inputFilePath := filepath.Join(t.TempDir(), "events.json")
+	if err := os.WriteFile(inputFilePath, []byte(events), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// the minutes are 40 and 20, and their mean is 30, the deliveries are 10, 30 and 20, and their mean is 20
+	for averageMode, expected := range map[string][]float64{"per-minute": {0, 40, 30}, "per-delivery": {0, 20, 20}} {
+		output := getContentFromConsole(t, "--input_file="+inputFilePath, "--average-mode="+averageMode)
+		var averages []float64
+		for _, printableValues := range output {
+			averages = append(averages, printableValues.Average_delivery_time)
+		}
+		if !slices.Equal(averages, expected) {
+			t.Errorf("Expected the averages %v with --average-mode=%s, got %v", expected, averageMode, averages)
+		}
+	}
+
+	// the groups of --downsample are means of their deliveries too
+	if output := getContentFromConsole(t, "--input_file="+inputFilePath, "--average-mode=per-delivery", "--downsample", "--window_size=3"); len(output) != 1 || output[0].Average_delivery_time != 20 {
+		t.Errorf("Expected one group with the average 20, got %+v", output)
+	}
+
+	// with one delivery per minute the modes are the same
+	if perMinute, perDelivery := getContentFromConsole(t, "--input_file=./events.json"), getContentFromConsole(t, "--input_file=./events.json", "--average-mode=per-delivery"); !slices.Equal(perMinute, perDelivery) {
+		t.Errorf("Expected the same output with one delivery per minute, got %v and %v", perMinute, perDelivery)
+	}
+
+	for _, arguments := range [][]string{{"--average-mode=per-event"}, {"--average-mode=per-delivery", "--metric=median"}} {
+		if err := run(context.Background(), append(arguments, "--input_file=./events.json"), io.Discard, io.Discard); err == nil {
+			t.Errorf("Expected an error with %v", arguments)
+		}
+	}
+}
+
+func Test_main_StatsWindow(t *testing.T) {
+
+	// minutes with different durations, with a gap, so the windows of 3 and 8 minutes have different values
+	var events strings.Builder
+	for i, duration := range []int{10, 80, 30, 0, 0, 50, 20, 90, 40, 60, 70} {
+		if duration > 0 {
+			fmt.Fprintf(&events, `{"timestamp": "2018-12-26 18:%02d:10","duration": %d}`+"\n", 10+i, duration)
+		}
+	}
+
+	var compute = func(arguments ...string) []PrintableValues {
+		options, err := parseFlags(arguments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		series, err := computeSeries(context.Background(), strings.NewReader(events.String()), options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return series
+	}
+
+	// the average is of the window of --window_size, and the percentile of the window of --stats-window
+	var series = compute("--window_size=3", "--stats-window=8", "--percentile=90")
+	var averages = compute("--window_size=3")
+	var percentiles = compute("--window_size=8", "--metric=percentile", "--percentile=90")
+	if len(series) != len(averages) || len(series) != len(percentiles) {
+		t.Fatalf("Expected the same minutes, got %d, %d and %d", len(series), len(averages), len(percentiles))
+	}
+
+	var different bool
+	for i := range series {
+		if series[i].Average_delivery_time != averages[i].Average_delivery_time {
+			t.Errorf("Expected the average %v of the window of 3 minutes in %s, got %v", averages[i].Average_delivery_time, series[i].Date, series[i].Average_delivery_time)
+		}
+		if series[i].Percentile == nil || *series[i].Percentile != percentiles[i].Average_delivery_time {
+			t.Errorf("Expected the percentile %v of the window of 8 minutes in %s, got %v", percentiles[i].Average_delivery_time, series[i].Date, series[i].Percentile)
+		}
+		different = different || series[i].Average_delivery_time != percentiles[i].Average_delivery_time
+	}
+	if !different {
+		t.Errorf("Expected the two windows to have different values")
+	}
+
+	// the 8 minutes up to 18:21 have the values 20, 40, 50, 60, 70 and 90, the 90th percentile is between 70 and 90
+	if last := series[len(series)-1]; last.Date != "2018-12-26 18:21:00" || *last.Percentile != 80 {
+		t.Errorf("Expected the percentile 80 in 18:21, got %+v", last)
+	}
+
+	// the field is only present with the flag
+	if averages[0].Percentile != nil {
+		t.Errorf("Expected no percentile without --stats-window, got %v", *averages[0].Percentile)
+	}
+}
+
+func Test_medianAggregatorSliding(t *testing.T) {
+
+	// minutes with repeated values and empty minutes, slid through windows of several sizes
+	var random = rand.New(rand.NewSource(3))
+	var minutes = make([]int, 2000)
+	for i := range minutes {
+		if random.Intn(4) > 0 {
+			minutes[i] = 1 + random.Intn(50)
+		}
+	}
+
+	for _, windowSize := range []int{1, 2, 3, 10, 101} {
+		var sliding = newMedianAggregator()
+		var window []int
+
+		for i, minute := range minutes {
+			var removed int
+			var hasRemoved = len(window) == windowSize
+			if hasRemoved {
+				removed, window = window[0], window[1:]
+			}
+			window = append(window, minute)
+
+			// the median of the window by brute force, sorting the minutes with deliveries
+			var values []int
+			for _, value := range window {
+				if value > 0 {
+					values = append(values, value)
+				}
+			}
+			slices.Sort(values)
+			var expected float64
+			if len(values)%2 == 1 {
+				expected = float64(values[len(values)/2])
+			} else if len(values) > 0 {
+				expected = float64(values[len(values)/2-1]+values[len(values)/2]) / 2
+			}
+
+			if result := slideWindow(sliding, minute, removed, hasRemoved); result != expected {
+				t.Fatalf("Expected the median %f of the minute %d with a window of %d, got %f", expected, i, windowSize, result)
+			}
+		}
+	}
+}
+
+// the sliding median against sorting the window each minute, the percentile aggregator with the 50th percentile
+func BenchmarkMedian(b *testing.B) {
+
+	var random = rand.New(rand.NewSource(4))
+	var minutes = make([]int, 20000)
+	for i := range minutes {
+		minutes[i] = 1 + int(math.Exp(3+random.NormFloat64()))
+	}
+
+	for _, windowSize := range []int{60, 1440, 10080} {
+		b.Run(fmt.Sprintf("sliding/window=%d", windowSize), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				var sliding = newMedianAggregator()
+				for j, minute := range minutes {
+					slideWindow(sliding, minute, minutes[max(j-windowSize, 0)], j >= windowSize)
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("sorting/window=%d", windowSize), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				var sorting = aggregators["percentile"](options{percentile: 50})
+				for j := range minutes {
+					aggregateWindow(sorting, minutes[max(j-windowSize+1, 0):j+1])
+				}
+			}
+		})
+	}
+}
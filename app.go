@@ -2,34 +2,711 @@
 	go-challenge solves a technical challenge for Unbabel
 
 	Calculates the moving average for the time it took to deliver translations to clients.
-	Receives two optional flags, if the flags are not present, it will use the default values.
+	Receives optional flags, if the flags are not present, it will use the default values.
 	After performing the calculations, the program will output to the console.
+	If the program is interrupted (Ctrl+C) it stops calculating, writes the minutes calculated so far and exits.
+	The output is deterministic, running the program twice with the same input and flags produces the same bytes.
 
 	Usage:
 
 	go-challenge [flags]
+	go-challenge validate [flags]
+
+	The validate subcommand checks the input file instead of calculating the averages.
+	It prints the number of valid and invalid lines and the time span of the events,
+	and exits with an error with the first invalid line if there are any.
+	The flags of the input, like --input_file and --input-field-map, are used, the others are ignored.
 
 	The flags are
 
+	--config
+	Path to a YAML or TOML file with the values of the flags, to keep long invocations tidy.
+	The file has one "flag: value" (YAML) or "flag = value" (TOML) line per flag, the names are the same as in the command line.
+	The flags in the command line override the values in the file.
+	If the file can't be read or has an unknown flag the program will exit with an error.
+	The default value is "", which doesn't read a config file.
+
 	--input-file
 	Path to the file with the translations delivery's data.
+	The flag can be repeated to merge the events of several files, like exports of overlapping periods, into one series,
+	the minutes in more than one file are merged with --overlap-policy. The input_file of --stats-json is the first file.
+	Several files can't be used with --assume-sorted, --split-output-dir, --group-by=stream_id, --read-window-from-input or --align=data,
+	and the validate subcommand only checks the first file.
 	If the path is not valid, or it is unable to open the file the program will exit with an error.
 	The default value is "./events.json".
 
+	--overlap-policy
+	How the deliveries of a minute in more than one of the input files are merged, "sum", "first" or "last".
+	"sum" adds the deliveries of the files, for files with different events, like deltas.
+	"first" keeps the deliveries of the first file with the minute, in the order of the flags, and "last" the ones of the last file,
+	for files that are full re-exports of the same events, which would be counted twice by "sum".
+	The minutes are compared after the events are read, so a minute is kept or replaced with all its deliveries.
+	The statistics count the events of every file. If the value is not valid the program will exit with an error.
+	The default value is "sum".
+
 	--window_size
 	Positive integer with the width of the time window (in minutes) used to calculate the moving average.
-	If the value is not a integer greater or equal to 0 the program will exit with an error.
+	If the value is not a integer greater or equal to 0, or it is greater than --max-window-size, the program will exit with an error.
 	The default value is 10.
+
+	--max-window-size
+	Maximum of --window_size and --stats-window, in minutes, a sanity limit for the windows that are too big by mistake,
+	like 4000000000, which would keep a queue of billions of minutes in memory.
+	If the value is not a integer greater than 0 the program will exit with an error.
+	The default value is 10080, the minutes of a week.
+
+	The timestamps of the events have the "2006-01-02 15:04:05" format, with optional fractional seconds.
+	Timestamps without seconds, like "2006-01-02 15:04", are also accepted.
+	The timestamps can also be in RFC 3339 with an offset, like "2018-12-26T19:11:08+01:00", they are converted to UTC.
+	The durations can be integers, floats or strings with numbers, like 42, 42.0 or "42", also in scientific notation like 4.2e1.
+	The floats are rounded to the nearest integer, with halves rounded away from zero.
+
+	--value-field
+	Name of the numeric JSON key that is aggregated and averaged instead of the duration, like "nr_words".
+	The value is read like the duration, and the output keeps the "average_delivery_time" name.
+	It is the same as --duration-field, the last of the two flags wins.
+	The default value is "duration".
+
+	--input-field-map
+	Comma separated list of field=name pairs, to read the fields of the events from JSON keys with other names.
+	The fields that can be mapped are "timestamp" and "duration", for example "timestamp=ts,duration=dur_ms".
+	A missing mapped key is handled as a missing field, a line without a timestamp is skipped and a missing duration is 0.
+	If a field is not valid the program will exit with an error.
+	The default value is "", which uses the default names.
+
+	--timestamp-field
+	Name of the JSON key the timestamp of the events is read from, the same as --input-field-map=timestamp=name.
+	The default value is "timestamp".
+
+	--duration-field
+	Name of the JSON key the duration of the events is read from, the same as --input-field-map=duration=name.
+	The default value is "duration".
+
+	--duration_unit
+	Unit of the durations, "ms", "s" or "m". The events can have the unit of their duration in a "duration_unit" field,
+	the durations of the events in other units are converted to this unit before aggregation, rounded to the nearest integer,
+	so a file with events in "s" and "ms" has all of them in the same unit. The events without the field are in this unit.
+	An event with an unknown unit is skipped, and if the value is not a known unit the program will exit with an error.
+	The default value is "s".
+
+	--max-duration
+	Maximum duration of a delivery, in the unit of --duration_unit. The longer durations are clamped to it before aggregation,
+	so an outlier like a stuck translation reporting days of duration doesn't move the averages of its windows.
+	The clamped events are counted in the "clamped_durations" field of --stats-json.
+	If the value is negative the program will exit with an error.
+	The default value is 0, which doesn't clamp the durations.
+
+	--strict-schema
+	Rejects the events with fields that are not in the schema of the events, to catch the mistakes of the producers, like "timstamp".
+	The fields of the schema are timestamp, translation_id, source_language, target_language, client_name, event_name,
+	nr_words, duration and duration_unit, with the names of --input-field-map.
+	The events without a duration, or with a null duration, are also rejected.
+	Without --strict-schema they are deliveries with a duration of 0, counted in the "missing_durations" statistic of --stats-json.
+	The input file stops at the first rejected event with an error with its line number,
+	the events of --listen-tcp and --kafka are skipped, and the validate subcommand counts them as invalid lines.
+	The default value is false.
+
+	--metric
+	Metric calculated over the window, "mean", "trimmed-mean", "median", "max", "min", "sum", "percentile" or "approx-percentile".
+	The trimmed mean sorts the minutes of the window by duration and discards the top and bottom ones (see --trim) before averaging,
+	which reduces the influence of outliers.
+	The median is updated as the window slides, with the minute that enters and the one that leaves it, instead of sorting the window,
+	so it stays fast for big windows, like a week of minutes.
+	The percentile is interpolated between the two closest minutes of the window (see --percentile).
+	The approximate percentile counts the minutes of the window in buckets of logarithmic width and, like the median, is updated as the window slides,
+	so its memory and its time per minute depend on the number of buckets instead of the size of the window, at the cost of an error within 1%.
+	Like the mean, the other metrics are calculated over the minutes with deliveries, the value of each minute is the sum of its durations.
+	If the value is not a known metric the program will exit with an error.
+	The default value is "mean".
+
+	--agg
+	Same as --metric.
+
+	--average-mode
+	What the mean of the window is of, "per-minute" or "per-delivery".
+	"per-minute" is the mean of the minutes with deliveries, where the value of each minute is the sum of its durations,
+	like in the example of the challenge, with one delivery per minute.
+	"per-delivery" is the sum of the durations of the window divided by its number of deliveries, the mean duration of a delivery,
+	so a minute with more deliveries has more weight. It is the same as "per-minute" when every minute has one delivery.
+	It can only be used with --metric=mean. If the value is not valid the program will exit with an error.
+	The default value is "per-minute".
+
+	--trim
+	Fraction of the minutes with deliveries discarded from each end of the window by the trimmed mean.
+	With 0.1 and 20 minutes with deliveries, the 2 lowest and the 2 highest are discarded.
+	If the value is not in the [0, 0.5) range the program will exit with an error.
+	The default value is 0.1.
+
+	--percentile
+	Percentile of the window calculated by the "percentile" and "approx-percentile" metrics, like 95 or 99.9,
+	and of the window of --stats-window.
+	If the value is not in the (0, 100] range the program will exit with an error.
+	The default value is 95.
+
+	--approx-percentile
+	Calculates an approximate percentile of the window, like 95, the same as --metric=approx-percentile --percentile=95.
+	The default value is "", which doesn't change the metric.
+
+	--align
+	Alignment of the minutes the events are grouped in, "calendar" or "data".
+	With "calendar" the minutes start at whole clock minutes, an event at 18:11:50 is in the minute from 18:11:00 to 18:12:00.
+	With "data" the minutes start at the same second as the first event, so the windows are measured from the first event.
+	If the first event is at 18:11:50 the minutes go from 18:11:50 to 18:12:50 and so on, and so do the dates of the output.
+	It can't be used with --range-start or --range-end. If the value is not valid the program will exit with an error.
+	The default value is "calendar".
+
+	--bucket
+	Size of the buckets the events are grouped in, like "10s" or "1m", independent of the window size that is still in minutes.
+	With "10s" the events at 18:11:05 and 18:11:12 are in different buckets, and the output has one line every 10 seconds.
+	The window size in minutes must be a multiple of the bucket size, otherwise the program will exit with an error.
+	The default value is "1m", which groups the events by minute.
+
+	--round-timestamps-down
+	Labels each minute (or bucket) with its start, instead of with its end like the example of the challenge.
+	By default the timestamps are rounded up, an event at 18:11:08 is in the minute 18:12:00,
+	and the output starts at 18:11:00, one minute before the minute of the first event, with an average of 0.
+	Rounding them down an event at 18:11:08 is in the minute 18:11:00, and the output starts at that minute.
+	The default value is false.
+
+	--no-future-minutes
+	Skips the events with a timestamp after the current time in UTC, like the events of a producer with a clock skew,
+	that would extend the series into the future. They are counted as skipped lines in the statistics.
+	The default value is false, which uses every event.
+
+	--dedup-window
+	Drops the events that are identical to an event kept within this time, like "5s", for events that are sent more than once.
+	The events are identical if all their fields but the timestamp and the translation id are the same,
+	the client, languages, event name and duration. The window is measured from the first of the repeated events.
+	The dropped events are counted in the "duplicate_events" statistic of --stats-json.
+	The default value is 0, which keeps every event.
+
+	--dedupe-by
+	Name of the JSON key of the events that identifies the duplicates of --dedup-window, like "translation_id",
+	instead of comparing all the fields but the timestamp. The events with the same value of the key within the window are dropped,
+	whatever their other fields. The key is read from any event, it doesn't need to be in the schema, and it can't be nested.
+	The events without the key are never duplicates. It needs --dedup-window.
+	The default value is "", which compares the fields of the events.
+
+	--round-to-window
+	Starts the output at the previous minute that is a multiple of the window size since the Unix epoch,
+	instead of one minute before the first delivery. With a window size of 10 the output starts at 18:10 instead of 18:11.
+	Two files over the same period then have the same minutes in the same rows.
+	It is ignored with --range-start and can't be used with --align=data.
+	The default value is false.
+
+	--assume-sorted
+	Reads the input file as a stream, calculating the minutes as the events are read, for files that are sorted by timestamp.
+	Only the minute being read is kept in memory, instead of all the minutes of the file, so it uses less memory for big files.
+	If an event is in a minute before the minute being read the program will exit with an error,
+	after writing the minutes calculated so far. It can't be used with --round-to-window, --range-start or --range-end.
+	The default value is false.
+
+	--read-window-from-input
+	Reads the window size from a metadata first line of the input file, like {"window_size": 15}, for self-describing feeds.
+	The window size of the line replaces the default one, but not the one of --window_size, in the command line or the config file.
+	The metadata line is not an event, it is skipped before the events are read, and --skip-lines skips the lines after it.
+	Like the lines of --skip-lines, the first line of the baseline file is skipped too.
+	A first line without a window_size field is an event, and the default window size is used.
+	If the window size of the line is not an integer greater or equal to 0 the program will exit with an error.
+	The default value is false.
+
+	--business-hours
+	Daily hours of the events that are used, like "09:00-18:00", to exclude the noise of the overnight low traffic.
+	The events outside the hours are ignored, and the minutes outside them are not printed, by the start of their bucket,
+	so each day of the output has the minutes from 09:00 to 17:59. The window still slides over the hidden minutes.
+	The end is not included, and the hours can cross midnight, like "22:00-06:00". The hours are in the zone of --business-hours-tz.
+	If the value is not two different times the program will exit with an error.
+	The default value is "", which uses every event.
+
+	--business-hours-tz
+	Time zone of --business-hours, an IANA name like "Europe/Lisbon" or an offset like "+01:00".
+	If the zone is not known the program will exit with an error.
+	The default value is "UTC".
+
+	--require-sorted
+	Exits with an error if the timestamp of an event is before the timestamp of a previous event of the input file,
+	to check the contract of the producers that must write the events in order. The error has the line number of the first such event.
+	The events are checked as they are in the file, before the events of the same minute are sorted, and before --client is applied.
+	Unlike --assume-sorted, the events of the same minute must also be in order, and the output is not changed.
+	The events of the baseline file are checked too. The events of --listen-tcp and --kafka are not checked.
+	The default value is false.
+
+	--skip-lines
+	Number of lines at the start of the input file that are not events, like the header or banner of an export, that are ignored.
+	The lines are not parsed, so they are not skipped lines in the statistics and are not errors with --strict-schema,
+	but they are counted in the line numbers of the errors. The lines of the baseline file are skipped too,
+	and the validate subcommand doesn't check them. The events of --listen-tcp and --kafka are not skipped.
+	The default value is 0.
+
+	--skip-header-lines
+	Same as --skip-lines.
+
+	--max-events
+	Stops reading the input file after this number of valid events, to test or bound the processing of big files.
+	The time range and the output only have those events, the lines after the last one are not read, like the end of the file.
+	The events are counted before --client and the other filters, the lines that are not valid events are not counted.
+	Unlike --output-every, which reduces the printed minutes, the minutes are calculated from fewer events.
+	The baseline file is read with the same maximum. The events of --listen-tcp and --kafka are not limited.
+	If the value is negative the program will exit with an error.
+	The default value is 0, which reads every event.
+
+	--input-buffer-size
+	Size in bytes of the buffer the input file is read with, the number of bytes of each read of the file.
+	Bigger buffers make fewer reads, which can be faster for fast disks and network file systems. The lines are still read one by one,
+	so the size doesn't change the output, and the lines longer than the buffer are still valid.
+	If the value is less than 16 the program will exit with an error.
+	The default value is 65536.
+
+	--parse-workers
+	Number of goroutines that parse the lines of the input file in parallel, to use more CPUs to decode the JSON of big files.
+	The input file is read in a pipeline of three stages connected by channels: a goroutine reads the lines in batches,
+	the workers parse the batches, and the windowing, that is single-threaded, uses the parsed events in the order of the file,
+	so the output is the same with any number of workers. It doesn't change the reading of --listen-tcp and --kafka.
+	If the value is not a positive integer the program will exit with an error.
+	The default value is 1, which parses the lines in the windowing goroutine, without the pipeline.
+
+	--channel-buffer
+	Number of batches of lines that are read ahead of the windowing with --parse-workers, queued in a buffered channel.
+	A bigger buffer keeps the workers busy when the windowing is slow, at the cost of the memory of the queued lines.
+	If the value is negative the program will exit with an error.
+	The default value is 16.
+
+	--min-deliveries
+	Minimum number of deliveries in the window for a minute to be printed.
+	Averages over windows with only one or two deliveries are noisy, the minutes with fewer deliveries are skipped from the output.
+	The default value is 0, which prints every minute.
+
+	--emit-on-change
+	Prints a minute only when its average differs from the average of the last printed minute by more than --change-eps,
+	which compresses the steady periods of the output into a step function.
+	The consumers must forward-fill the missing minutes with the last printed average,
+	and the minutes after the last printed one, up to the end of the series, have its average too.
+	The default value is false, which prints every minute.
+
+	--change-eps
+	Difference between the averages that is not a change with --emit-on-change, like 0.5.
+	If the value is negative the program will exit with an error.
+	The default value is 0, where any difference is a change.
+
+	--with-window-span
+	Adds "window_start" and "window_end" fields to each output line with the oldest and newest minutes in the window.
+	At the beginning of the series the window is not full yet, so it spans fewer minutes than the window size.
+	The default value is false.
+
+	--output-every
+	Prints only every nth minute of the output, starting with the first one, to reduce the output of long series.
+	The averages are still calculated over the whole window, every minute is calculated but only every nth is printed.
+	The last minute is always printed, even if it is not one of them, so the output ends at the same minute.
+	The minutes skipped by the other flags, like --full-window-only, are not counted.
+	It can't be used with --listen-tcp or --kafka, where the last minute is not known.
+	If the value is not a positive integer the program will exit with an error.
+	The default value is 1, which prints every minute.
+
+	--sample-at
+	Prints only the minutes at the top of each "hour" or "day", in UTC, like 19:00:00, for the hourly and daily reports.
+	Each point is the moving average of the window that ends in it, the window is still of --window_size minutes,
+	so it samples the output instead of aggregating the hour. The minutes are their dates in the output,
+	so with --round-timestamps-down the point of 19:00 is the window that ends in the bucket from 19:00 to 19:01.
+	It can't be used with --output-every, --downsample, --raw or --align=data, and the bucket must divide the interval.
+	If the value is not valid the program will exit with an error.
+	The default value is "", which prints every minute.
+
+	--drop-first
+	Skips the first minute of the output, the padding one minute before the first delivery, which has an average of 0.
+	The rest of the output is not changed. With --range-start the first minute of the range is skipped.
+	It can't be used with --round-timestamps-down, where the output starts at the minute of the first delivery without the padding.
+	The default value is false.
+
+	--with-throughput
+	Adds a "throughput" field to each output line with the number of deliveries per minute in the window,
+	the deliveries of the window divided by the minutes of the window, including the minutes without deliveries.
+	At the beginning of the series the window is not full yet, so it is divided by fewer minutes.
+	The default value is false.
+
+	--stats-window
+	Adds a "percentile" field to each output line with the --percentile of the minutes of a window of this size, in minutes,
+	which is independent of --window_size, like the 99th percentile of the last 60 minutes next to the mean of the last 10.
+	Like the metrics, the percentile is calculated over the minutes with deliveries, the value of each minute is the sum of its durations.
+	The --metric, --average-mode and the filters of the output like --full-window-only and --min-deliveries use the window of --window_size.
+	The default value is 0, which doesn't add the field.
+
+	--fail-on-empty-window
+	Exits with an error after writing the output if a printed minute has no deliveries in its window,
+	which may be an outage, so the program can be used as a health check.
+	Only the minutes between the first and the last deliveries are checked, the padding before and after them is not.
+	The error has the number of minutes with an empty window and the first of them.
+	The default value is false.
+
+	--full-window-only
+	Skips the first minutes of the output, until the window has the number of minutes of the window size.
+	The averages of the first minutes are calculated over fewer minutes, so they can be considered warm-up values.
+	With a window size of 10 the output starts at the 10th minute.
+	The default value is false.
+
+	--explode
+	Debug mode that prints to stderr, for every printed minute, the duration of each delivery in that minute.
+	For example "2018-12-26 18:16:00 [31]". The durations of every event are kept in memory, so it is heavy for big files.
+	The default value is false.
+
+	--range-start
+	First minute of the output, in the same format as the timestamps of the events.
+	The output starts in this minute even if there are no deliveries before it, padded with averages of 0.
+	The deliveries before this minute are not part of any window.
+	It can also be relative to the current time in UTC, like "-1h" for one hour ago, or "now".
+	If the value is not valid or is after --range-end the program will exit with an error.
+	The default value is "", which starts the output one minute before the first delivery.
+
+	--range-end
+	Last minute of the output, in the same format as the timestamps of the events.
+	The output ends in this minute even if there are deliveries after it, or no deliveries up to it.
+	Like --range-start, it can be relative to the current time, like "-5m" or "now".
+	If the value is not valid the program will exit with an error.
+	The default value is "", which ends the output in the minute of the last delivery.
+
+	--serve
+	Address to serve the moving averages of the input file over HTTP, like ":8080", instead of printing them.
+	A GET request to /moving-average responds with a JSON array of the minutes, the same values as Compute,
+	with the flags of the calculation, and the file is read again in each request, so it can change while the program runs.
+	The "offset" and "limit" query parameters return a page of the minutes, like /moving-average?offset=100&limit=50,
+	and the "X-Total-Count" header has the number of minutes of the whole series. Without a limit every minute after the offset is returned.
+	The flags of the output are ignored, and it can't be used with --assume-sorted, --raw, --downsample, --listen-tcp, --kafka,
+	--split-output-dir, --group-by=stream_id, --compare, --nan-policy=null or several --input_file.
+	With --metrics-addr the requests are counted with their durations, and the counters are also served in the /metrics path of this address.
+	The program runs until it is interrupted.
+	The default value is "", which prints the output.
+
+	--listen-tcp
+	Address to listen for TCP connections, like ":9000", instead of reading the input file.
+	Each connection is an independent stream of events, with one JSON event per line ordered by timestamp.
+	The averages of a minute are printed as soon as the minute is complete, when an event of a later minute arrives,
+	and the last minute of a stream is printed when its connection is closed. Events older than the current minute are skipped.
+	The program runs until it is interrupted. It can't be used with --normalize.
+	The default value is "", which reads the input file.
+
+	--report
+	Report printed to stderr after the output, calculated from the printed minutes.
+	"top-slow:N" prints the N minutes with the highest moving average, from the highest to the lowest,
+	one per line like "1. 2018-12-26 18:41:00 100". The minutes with the same average are in chronological order.
+	N is optional and defaults to 10.
+	"gaps" prints the intervals of minutes without deliveries between the first and the last minute with deliveries,
+	for the audits of the quality of the data. Each gap is a line with the first and the last minute without deliveries,
+	both included, and the number of minutes of the gap, like "2018-12-26 18:13:00 2018-12-26 18:15:00 3".
+	The minutes are the dates of the output, and with --bucket the number is of buckets. If there are no gaps nothing is printed.
+	It can't be used with --assume-sorted.
+	If the value is not a known report the program will exit with an error.
+	The default value is "", which doesn't print a report.
+
+	--kafka
+	Kafka topic to consume the events from instead of the input file, as "brokers,topic,group".
+	Multiple brokers are separated by "+", like "kafka1:9092+kafka2:9092,events,go-challenge".
+	Each message has one event, and the averages are printed as the minutes complete, like with --listen-tcp.
+	The offsets of the messages are committed after their minute is complete, so the messages of an incomplete minute
+	are consumed again if the program stops. The program runs until it is interrupted. It can't be used with --normalize.
+	The Kafka client is only built with the kafka build tag (go build -tags kafka), otherwise the program exits with an error.
+	The default value is "", which reads the input file.
+
+	--client
+	Comma separated list of the clients whose events are used, the events of the other clients are ignored, like "airliberty,taxi-eats".
+	The flag can be repeated, and the clients are added to the ones of --clients-file.
+	The default value is "", which uses the events of every client.
+
+	--clients-file
+	Path to a file with the names of the clients whose events are used, one per line, like --client.
+	The whitespace around the names is trimmed and the blank lines are ignored.
+	If the file can't be read the program will exit with an error.
+	The default value is "", which doesn't read a file.
+
+	--top-n-clients
+	Prints to stderr, after the output, the N clients with the most deliveries and their share of the total deliveries.
+	The clients with the same number of deliveries are sorted by name. Each client is printed in a line like
+	"1. airliberty: 2 deliveries (50.00%)".
+	The default value is 0, which doesn't print the clients.
+
+	--sla
+	Maximum average delivery time within the SLA.
+	Adds a "within_sla" field to each output line, true if the average of the minute is at or below the SLA.
+	The percentage of printed minutes within the SLA is printed to stderr at the end.
+	The default value is 0, which disables the SLA check.
+
+	--baseline
+	Path to a file with the events of a baseline, like the events of the previous week, to compare the averages to.
+	The averages of the baseline are calculated with the same flags, and each minute of the output has a "vs_baseline" field
+	with the ratio or the difference (see --baseline-mode) to the average of the same minute in the baseline.
+	The minutes missing in the baseline have the --baseline-gap value. It can't be used with --listen-tcp or --kafka.
+	The default value is "", which doesn't compare to a baseline.
+
+	--baseline-mode
+	Comparison to the baseline, "ratio" of the average to the baseline's or "difference" of the average minus the baseline's.
+	The ratios to a baseline average of 0 have the --baseline-gap value.
+	If the value is not valid the program will exit with an error.
+	The default value is "ratio".
+
+	--baseline-gap
+	Value of the comparison for the minutes missing in the baseline.
+	The default value is 0.
+
+	--gap-value
+	Value written instead of the values of the output that are NaN or infinite, which are not valid in JSON,
+	like the throughput of a window of 0 minutes. The first one is reported as a warning in stderr, with its field and minute.
+	If the value is not a finite number the program will exit with an error.
+	With ffill, the minutes whose window has no deliveries are printed with the average of the last window with deliveries,
+	instead of 0, so a chart shows the gaps as a flat line. The windows before the first delivery are still 0,
+	and the values that are NaN or infinite are written as 0. The output of --raw and --downsample is not changed.
+	The default value is 0.
+
+	--nan-policy
+	How the values of the output that are NaN or infinite are written, "zero", "null" or "error".
+	"zero" writes them as the --gap-value, "null" writes them as null in JSON and as empty fields in CSV,
+	and "error" ends the output before the minute with the first one and exits with an error with its field and minute.
+	"error" can't be used with --listen-tcp or --kafka.
+	The output is valid JSON with every policy.
+	The default value is "zero".
+
+	--normalize
+	Adds a "normalized" field to each output line with the average scaled to the 0-1 range (min-max normalization).
+	The minimum and maximum are taken across the whole series, so the output is only printed after every minute is calculated.
+	If all the averages are equal the normalized value is 0 for every minute.
+	The default value is false.
+
+	--diff
+	Adds a "delta_prev" field to each output line with the difference between the average of the minute and the average of the previous minute.
+	The first minute has no previous minute, so its delta is 0.
+	The default value is false.
+
+	--delta
+	Adds a "delta" field to each output line with the difference between the average of the minute and the average of the previous minute,
+	the same value as the "delta_prev" field of --diff, for the consumers that expect this name. Both flags can be used together.
+	The first minute has no previous minute, so its delta is 0.
+	The default value is false.
+
+	--metrics-interval
+	Prints to stderr, every interval, the number of events read, the events per second and the rows emitted, like "10s".
+	It is meant for long runs, like the streams of --listen-tcp and --kafka, to follow their progress.
+	The default value is 0, which doesn't print them.
+
+	--metrics-addr
+	Address to serve the counters of the run over HTTP in the Prometheus text format, like ":9100", in the /metrics path.
+	The counters are the events read, the lines skipped because they are not valid events, the rows emitted,
+	the TCP connections closed and the HTTP requests of --serve, with the sums of their durations in seconds.
+	It can only be used with --listen-tcp, --kafka or --serve, the runs that don't end.
+	The default value is "", which doesn't serve them.
+
+	--stats-json
+	Path to a file where a JSON document with statistics about the run is written.
+	It has the number of events and skipped lines, the minimum, maximum and mean duration,
+	the minute with the highest average and the parameters used.
+	The default value is "", which doesn't write the statistics.
+
+	--dump-buckets
+	Path to a file where the minutes with deliveries are written as they were aggregated, before the averages are calculated,
+	to troubleshoot the aggregation. The file is an indented JSON array of the minutes in chronological order,
+	with the same fields as the --raw output, like {"date": "2018-12-26 18:12:00", "sum_duration": 20, "count": 1}.
+	The minutes are the ones of the events used, before --range-start, --range-end and the filters of the output.
+	It can't be used with --assume-sorted, --split-output-dir, --listen-tcp or --kafka.
+	The default value is "", which doesn't write the minutes.
+
+	--downsample
+	Prints one minute for each group of consecutive minutes of the window size, instead of the moving average of every minute,
+	which reduces the output by the window size. The groups don't overlap, they start at the first minute of the output,
+	and each one is printed with the date of its last minute and the metric (see --metric) of its minutes.
+	If the minutes don't divide in whole groups, the last group has the remaining minutes and is printed with the last minute.
+	With --round-to-window the groups are aligned to multiples of the window size since the Unix epoch.
+	The output only has the date and the average, the flags of the values calculated from the moving window are ignored.
+	It can't be used with --raw, --assume-sorted, --listen-tcp or --kafka.
+	The default value is false.
+
+	--coalesce-window
+	Same as --downsample.
+
+	--compact-zeros
+	Prints each run of two or more consecutive minutes with an average of 0 as one line, with the first and last minutes of the run,
+	like {"date":"2018-12-26 18:25:00","average_delivery_time":0,"from":"2018-12-26 18:25:00","to":"2018-12-26 19:40:00"},
+	to reduce the noise of the long gaps of sparse series. The minutes with deliveries are still printed one per line.
+	The line of a run only has the date, the average and the range, the other values of its minutes are not printed.
+	It is a pass over the printed minutes, after the other flags. It can't be used with --format=csv, --raw, --listen-tcp or --kafka.
+	The default value is false.
+
+	--merge-adjacent-zero-runs
+	Same as --compact-zeros.
+
+	--raw
+	Prints, instead of the moving average, the sum of the durations and the number of deliveries of each minute with deliveries,
+	like {"date": "2018-12-26 18:12:00", "sum_duration": 20, "count": 1}, to check the input without the window.
+	The flags of the window and the values calculated from it are ignored. It can't be used with --assume-sorted, --listen-tcp or --kafka.
+	The default value is false.
+
+	--format
+	Format of the output, "json" with one JSON object per line, "json-array" with the same objects in a JSON array,
+	or "csv" with a header line and one record per line.
+	The JSON array is written as the minutes are calculated, one object per line, so it doesn't keep the whole series in memory.
+	The CSV output has the same fields as the JSON output, in the same order.
+	The "json-array" format can't be used with --listen-tcp, where each connection is an independent series.
+	If the value is not a known format the program will exit with an error.
+	The default value is "json".
+
+	--color
+	Colors each line of the output in the console by its average, "auto", "always" or "never".
+	The lines are green up to the first threshold, yellow up to the second and red above it (see --thresholds).
+	With "auto" the output is colored when the console is a terminal.
+	Only the "json" format printed to the console is colored, the CSV and JSON array formats and the output files are not.
+	If the value is not valid the program will exit with an error.
+	The default value is "auto".
+
+	--thresholds
+	Two comma separated averages where the color of the lines changes from green to yellow and from yellow to red, like "30,60".
+	If the value is not two numbers in increasing order the program will exit with an error.
+	The default value is "30,60".
+
+	--json-numbers-as-strings
+	Writes the average of the JSON output as a string, like "average_delivery_time":"31.43",
+	for the consumers that lose precision with large numbers, like JavaScript.
+	The CSV output is not changed.
+	The default value is false.
+
+	--json-lines-strict
+	Guarantees that the output is NDJSON, exactly one compact JSON object per line, each one ending in a line break,
+	including the last one. The lines are not colored, and every line is checked before it is written,
+	if a line is not one valid JSON object without line breaks the output ends before it and the program exits with an error.
+	The strings of the objects never have line breaks, they are escaped like \n, and the values that are not finite are null or numbers.
+	It can only be used with --format=json and the default --line-separator, and not with --listen-tcp or --kafka.
+	The default value is false.
+
+	--date-format
+	Format of the date of the minutes, "timestamp" like "2018-12-26 18:12:00", or "offset",
+	the integer number of buckets since the first printed minute, like {"date": 3, ...} for the fourth minute with the default bucket.
+	The offsets compare the runs of different absolute times, like two load tests, in the same plot.
+	The from and to of the runs of zeros of --compact-zeros are offsets too.
+	The dates can also be written with a layout of the locale, "us" like "12/26/2018 18:12" or "eu" like "26/12/2018 18:12",
+	or with any Go layout of the time package, like "02.01.2006 15:04:05". The named layouts don't have the seconds,
+	and they are written in the dates of the lines, the window span and the runs of zeros, but not in the reports.
+	The layouts can't be used with --retain-input-tz or --compare.
+	If the value is not valid the program will exit with an error.
+	The default value is "timestamp".
+
+	--unix-ts
+	Writes the date of the minutes as the integer number of seconds since the Unix epoch, like {"date": 1545847920, ...},
+	for the time-series databases that prefer epoch seconds. The from and to of --compact-zeros are in seconds too,
+	the window_start and window_end of --with-window-span are not changed. With --format=csv the date column has the seconds.
+	It can't be used with --date-format.
+	The default value is false.
+
+	--line-separator
+	Separator between the JSON objects of the output, like " " or "\t", to write them in one line for the consumers that expect it.
+	The escapes of Go strings are accepted, like \t for a tab, so the separator can be written in a shell without quoting tricks.
+	With a separator other than a line break the output ends in a line break after the last object.
+	It is ignored with --format=json-array and --format=csv. It can't be used with --listen-tcp or --kafka, where each object is a line.
+	If the value is empty or not a valid string the program will exit with an error.
+	The default value is "\n".
+
+	--autoflush
+	Flushes the buffered output while the minutes are printed, so the consumers of a stream see them promptly.
+	"rows:N" flushes after every N rows, and "duration:D" flushes the rows at most D after they are written, like "duration:1s".
+	The rows are the lines of the output, or the objects of a JSON array. The output is always flushed at the end.
+	Without it the minutes of --listen-tcp and --kafka are flushed one by one, and the output of the input file only when it is full.
+	If the value is not valid the program will exit with an error.
+	The default value is "".
+
+	--replay-speed
+	Replays the input file as if it was received in real time, for demos, printing each minute when its time comes,
+	with the time between the minutes scaled by this factor, like 60 to replay an hour in a minute.
+	The output is flushed after every row, unless --autoflush is set. It can't be used with --assume-sorted, --normalize, --raw,
+	--downsample, --listen-tcp, --kafka, --split-output-dir, --group-by=stream_id or --serve.
+	If the value is negative the program will exit with an error.
+	The default value is 0, which prints the output as fast as possible.
+
+	--retain-input-tz
+	Writes the dates of the output in RFC 3339 with the offset of the timestamps of the input, like "2018-12-26T19:12:00+01:00",
+	instead of in UTC, for the reports that must match the local time of the source.
+	The offset is the one of the first event used, the timestamps without an offset are in UTC, like "2018-12-26T18:12:00Z".
+	The minutes are still calculated in UTC, only the dates of the output are converted, the dates of the reports in stderr are not.
+	It can't be used with --date-format=offset, --unix-ts, --listen-tcp or --kafka.
+	The default value is false.
+
+	--decimal-comma
+	Uses a comma as the decimal separator of the CSV output, like "25,5", for spreadsheets in locales that expect it.
+	The fields are then separated by semicolons instead of commas. It can only be used with --format=csv.
+	The default value is false.
+
+	--output_file
+	Path to the file where the output is written, instead of the console.
+	If the path ends in ".gz" the output is compressed with gzip.
+	The default value is "", which prints to the console.
+
+	--gzip-output
+	Compresses the output with gzip even if the output file doesn't end in ".gz".
+	The default value is false.
+
+	--checksum
+	Prints to stderr the SHA-256 of the output after it is written, like sha256sum does, to detect a truncated or corrupted output.
+	The line has the hash and the output file, or "-" for the console, like "9f86d0...  -", so it can be checked with sha256sum -c.
+	The hash is calculated as the output is written, of the bytes in the console or file, after the compression with gzip.
+	With --split-output-dir a line is printed for each file. It can't be used with --listen-tcp or --kafka.
+	The default value is false.
+
+	--split-output-dir
+	Path to a directory where the output of each language pair is written to its own file, like "out/en-fr.json",
+	instead of one output with all the events. Each file is an independent series of the events of its pair.
+	The files have the ".csv" extension with --format=csv, and end in ".gz" with --gzip-output.
+	The directory is created if it doesn't exist. It can't be used with --output_file, --stats-json, --listen-tcp or --kafka.
+	The default value is "", which writes one output.
+
+	--output-dir
+	Same as --split-output-dir.
+
+	--group-by
+	Group of the events of each file of --split-output-dir, "language_pair", "target_language" or "stream_id".
+	With "target_language" each file has the events of a target language, from every source language, like "out/fr.json".
+	With "stream_id" each file has the events of a stream of a multiplexed input, by the stream_id field of the events, like "out/a.json".
+	The languages and streams are used in the names of the files with the characters that can't be in a file name replaced by "_",
+	and the events without a language or a stream are in "unknown". The groups that get the same file name, like "a.b" and "a/b",
+	are not written to the same file, the first one by the order of the groups keeps the name and the next ones get a suffix, like "a_b-2",
+	with a warning to stderr.
+	Without --split-output-dir, "stream_id" calculates an independent series for each stream in one read of the input file,
+	and prints the series one after the other in one output, sorted by the id, with the id in the stream_id field of each minute,
+	like {"date":"2018-12-26 18:12:00","average_delivery_time":20,"stream_id":"a"}. Each stream has its own window,
+	from the minute before its first delivery to its last delivery. The events without a stream are a series without the field,
+	except in CSV, where every record has the stream_id column and it is empty for them.
+	The SLA compliance of --sla is not printed, and it can't be used with --normalize, --baseline, --report, --downsample, --raw,
+	--assume-sorted, --compact-zeros, --output-every, --explode, --top-n-clients, --fail-on-empty-window, --stats-json,
+	--dump-buckets, --compare, --listen-tcp or --kafka.
+	If the value is not valid, or "target_language" is used without --split-output-dir, the program will exit with an error.
+	The default value is "language_pair".
+
+	--compare
+	Path to an output of a previous run, JSON lines or a JSON array, to compare the series with instead of printing it,
+	like a golden file for the regression tests of a pipeline. The rows are matched by date, and the rows that differ
+	are printed, the saved row prefixed by "-" and the current one by "+", like
+	- {"date":"2018-12-26 18:24:00","average_delivery_time":42.5}
+	+ {"date":"2018-12-26 18:24:00","average_delivery_time":42}
+	The rows of the dates that are only in one of the series are printed too. If any row differs the program exits with an error.
+	The other flags are used to calculate the series, they must be the ones of the saved run.
+	It can't be used with --output_file, --split-output-dir, --raw, --format=csv, --date-format=offset, --unix-ts, --listen-tcp or --kafka.
+	The default value is "", which prints the series.
+
+	--compare-eps
+	Difference between the numbers of two rows that is not a difference with --compare, to ignore the rounding of other versions.
+	If the value is negative the program will exit with an error.
+	The default value is 0.
 */
 
 package main
 
 import (
-	"bufio"
+	"context"
+	"crypto/sha256"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"hash"
+	"io"
+	"math"
+	"net"
 	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -37,152 +714,1719 @@ import (
 // the file has more information, but since it is not needed it won't be loaded into memory
 // Timestamp: minute the translations were delivered
 // Duration: duration of the delivery
+// Client_name: client the translation was delivered to
+// Source_language, Target_language, Event_name: only used to find the duplicated events with the --dedup-window flag
+// Stream_id: stream of the event in a multiplexed input, used to calculate a series for each stream with --group-by=stream_id
+// Duration_unit: unit of the duration, optional, the duration is converted to the --duration_unit before it is used
+// the events without a duration are deliveries with a duration of 0, marked as missing to count them in the statistics
 type DeliveredTranslation struct {
-	Timestamp string `json:"timestamp"`
-	Duration  int    `json:"duration"`
+	Timestamp       string           `json:"timestamp"`
+	Duration        DeliveryDuration `json:"duration"`
+	Duration_unit   string           `json:"duration_unit"`
+	Client_name     string           `json:"client_name"`
+	Source_language string           `json:"source_language"`
+	Target_language string           `json:"target_language"`
+	Event_name      string           `json:"event_name"`
+	Stream_id       string           `json:"stream_id"`
+
+	// set when the event has no duration field, or a null duration
+	durationMissing bool
+
+	// set when the duration is longer than --max-duration and it was clamped
+	durationClamped bool
+
+	// value of the key of --dedupe-by, as it is in the line, empty if the event doesn't have the key
+	dedupKey string
+}
+
+// units of the durations, by the name used in the duration_unit field and the --duration_unit flag
+var durationUnits = map[string]time.Duration{"ms": time.Millisecond, "s": time.Second, "m": time.Minute}
+
+// function to convert the duration of an event to a unit, removing its unit
+// the durations without a unit are already in the unit, and nothing is converted without a unit to convert to
+func (deliveredTranslation *DeliveredTranslation) normalizeDuration(unit string) {
+	from, fromOk := durationUnits[deliveredTranslation.Duration_unit]
+	to, toOk := durationUnits[unit]
+	if fromOk && toOk && from != to {
+		deliveredTranslation.Duration = DeliveryDuration(math.Round(float64(deliveredTranslation.Duration) * float64(from) / float64(to)))
+	}
+
+	deliveredTranslation.Duration_unit = ""
+}
+
+// function to clamp the duration of an event to a maximum (--max-duration), 0 doesn't clamp it
+func (deliveredTranslation *DeliveredTranslation) clampDuration(maxDuration int) {
+	if maxDuration > 0 && int(deliveredTranslation.Duration) > maxDuration {
+		deliveredTranslation.Duration = DeliveryDuration(maxDuration)
+		deliveredTranslation.durationClamped = true
+	}
+}
+
+// type of the duration of a delivery
+// besides integers, the duration can be a float or a string with a number, like 42.0 or "42"
+// the numbers can be in scientific notation, like 2e1 or "4.2E1"
+// the floats are rounded to the nearest integer, with halves rounded away from zero (20.5 is 21)
+type DeliveryDuration int
+
+func (duration *DeliveryDuration) UnmarshalJSON(data []byte) error {
+	var value = string(data)
+
+	// like the other types, null is ignored
+	if value == "null" {
+		return nil
+	}
+
+	// numbers in strings are parsed like the numbers without quotes
+	if unquoted, err := strconv.Unquote(value); err == nil {
+		value = strings.TrimSpace(unquoted)
+	}
+
+	// the numbers in scientific notation are also parsed by ParseFloat
+	// the numbers that don't fit in an int are not valid, instead of overflowing
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(number) || math.Abs(number) >= math.MaxInt64 {
+		return fmt.Errorf("invalid duration %s", data)
+	}
+
+	*duration = DeliveryDuration(math.Round(number))
+	return nil
+}
+
+// struct with the deliveries of one minute
+// Duration: sum of the duration of the deliveries
+// Count: number of deliveries
+// Durations: duration of each delivery, only kept with the --explode flag
+type MinuteDeliveries struct {
+	Duration  int
+	Count     int
+	Durations []int
+}
+
+// function to add a delivery to the deliveries of the minute
+// the durations of each delivery are only kept if keepDurations is set
+func (minuteDeliveries *MinuteDeliveries) add(duration int, keepDurations bool) {
+	minuteDeliveries.Duration += duration
+	minuteDeliveries.Count++
+	if keepDurations {
+		minuteDeliveries.Durations = append(minuteDeliveries.Durations, duration)
+	}
 }
 
 // struct with the calculated values to print
 // CurrentMinute: minute in time to which we are making the calculations
 // AverageDuration: average time it took to deliver translations in this minute
+// Normalized: average scaled to the 0-1 range, only present with the --normalize flag
+// Delta_prev: difference to the average of the previous minute, only present with the --diff flag
+// Delta: the same difference as Delta_prev, only present with the --delta flag
+// Window_start, Window_end: oldest and newest minutes in the window, only present with the --with-window-span flag
+// Within_sla: whether the average is at or below the SLA, only present with the --sla flag
+// Throughput: number of deliveries per minute in the window, only present with the --with-throughput flag
+// Percentile: percentile of the window of --stats-window, only present with the --stats-window flag
+// Vs_baseline: ratio or difference to the average of the same minute in the baseline, only present with the --baseline flag
+// From, To: first and last minutes of a run of zero averages printed as one line, only present with the --compact-zeros flag
+// Stream_id: stream of the series of the minute, only present with the --group-by=stream_id flag without --split-output-dir
 type PrintableValues struct {
-	Date                  string  `json:"date"`
-	Average_delivery_time float64 `json:"average_delivery_time"`
+	Date                  string   `json:"date"`
+	Average_delivery_time float64  `json:"average_delivery_time"`
+	Normalized            *float64 `json:"normalized,omitempty"`
+	Delta_prev            *float64 `json:"delta_prev,omitempty"`
+	Delta                 *float64 `json:"delta,omitempty"`
+	Window_start          string   `json:"window_start,omitempty"`
+	Window_end            string   `json:"window_end,omitempty"`
+	Within_sla            *bool    `json:"within_sla,omitempty"`
+	Throughput            *float64 `json:"throughput,omitempty"`
+	Percentile            *float64 `json:"percentile,omitempty"`
+	Vs_baseline           *float64 `json:"vs_baseline,omitempty"`
+	From                  string   `json:"from,omitempty"`
+	To                    string   `json:"to,omitempty"`
+	Stream_id             string   `json:"stream_id,omitempty"`
+}
+
+// struct with the raw values of a minute, printed with the --raw flag
+// Date: minute in time of the deliveries
+// Sum_duration: sum of the duration of the deliveries of this minute, without any window
+// Count: number of deliveries of this minute
+type RawMinute struct {
+	Date         string `json:"date"`
+	Sum_duration int    `json:"sum_duration"`
+	Count        int    `json:"count"`
 }
 
 func main() {
+	// the context is canceled when the program is interrupted
+	// so the calculations stop and the output calculated so far is flushed
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	err := run(ctx, os.Args[1:], os.Stdout, os.Stderr)
+
+	// the partial output was already written, just let the user know
+	if errors.Is(err, context.Canceled) {
+		fmt.Fprintln(os.Stderr, "interrupted, the output contains the minutes calculated so far")
+		stop()
+		os.Exit(130)
+	}
+
+	// exit with error if anything went wrong
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		stop()
+		os.Exit(1)
+	}
+}
+
+// struct with the options of the program, set from the command line flags
+type options struct {
+	inputFilePath        string
+	inputFilePaths       []string
+	overlapPolicy        string
+	windowSize           uint
+	maxWindowSize        uint
+	normalize            bool
+	diff                 bool
+	delta                bool
+	outputFilePath       string
+	splitOutputDir       string
+	compareFilePath      string
+	compareEps           float64
+	groupBy              string
+	gzipOutput           bool
+	checksum             bool
+	statsFilePath        string
+	bucketsFilePath      string
+	inputFieldMap        fieldMap
+	durationUnit         string
+	maxDuration          int
+	strictSchema         bool
+	clients              clientSet
+	metric               string
+	averageMode          string
+	trim                 float64
+	percentile           float64
+	minDeliveries        int
+	emitOnChange         bool
+	changeEps            float64
+	withWindowSpan       bool
+	withThroughput       bool
+	statsWindow          uint
+	sla                  float64
+	fullWindowOnly       bool
+	failOnEmptyWindow    bool
+	dropFirst            bool
+	outputEvery          int
+	sampleAt             time.Duration
+	explode              bool
+	topNClients          int
+	rangeStart           time.Time
+	rangeEnd             time.Time
+	listenTCP            string
+	serve                string
+	kafka                string
+	format               string
+	decimalComma         bool
+	jsonNumbersAsStrings bool
+	jsonLinesStrict      bool
+	lineSeparator        string
+	autoFlush            autoFlush
+	replaySpeed          float64
+	dateFormat           string
+	unixTimestamps       bool
+	retainInputTz        bool
+	color                string
+	thresholds           [2]float64
+	raw                  bool
+	downsample           bool
+	compactZeros         bool
+	baselineFilePath     string
+	baselineMode         string
+	baselineGap          float64
+	gapValue             float64
+	nanPolicy            string
+	fillForward          bool
+	align                string
+	bucket               time.Duration
+	roundTimestampsDown  bool
+	dedupWindow          time.Duration
+	dedupeBy             string
+	noFutureMinutes      bool
+	roundToWindow        bool
+	assumeSorted         bool
+	requireSorted        bool
+	skipLines            int
+	maxEvents            int
+	readWindowFromInput  bool
+	inputBufferSize      int
+	parseWorkers         int
+	channelBuffer        int
+	report               string
+	reportSize           int
+	configFilePath       string
+
+	// counters of the run, only set with --metrics-interval
+	metricsInterval time.Duration
+	metricsAddr     string
+	metrics         *runMetrics
+
+	// zone of the dates of the output, only set with --retain-input-tz
+	inputZone *inputZone
+
+	// daily hours of the events and the printed minutes, only set with --business-hours
+	businessHours *businessHours
+
+	// group of the events used, only set for the files of --split-output-dir
+	// the language pair, or with --group-by the target language or the stream
+	group *eventGroup
+
+	// clock of the values relative to the current time, time.Now unless the hidden --now flag is used
+	// the current time is always read from it, so the features that depend on it can be tested
+	clock func() time.Time
+
+	// function to wait, used by --replay-speed with the clock, sleepContext unless a test replaces it
+	sleep func(ctx context.Context, duration time.Duration)
+}
+
+// function to parse the command line arguments into the options of the program
+func parseFlags(arguments []string) (options, error) {
+	var options = options{inputFieldMap: fieldMap{}, clients: clientSet{}, thresholds: [2]float64{30, 60}, clock: time.Now, sleep: sleepContext, lineSeparator: "\n"}
+
 	// define the flags and the default values
-	filePath := flag.String("input_file", "./events.json", "path to the input file")
-	windowSize := flag.Uint("window_size", 10, "window size used to calculate the moving average")
-	flag.Parse()
+	flags := flag.NewFlagSet("go-challenge", flag.ContinueOnError)
+	flags.StringVar(&options.configFilePath, "config", "", "path to a YAML or TOML file with the values of the flags")
+	flags.Func("input_file", "path to the input file, can be repeated to merge the events of several files", func(path string) error {
+		options.inputFilePaths = append(options.inputFilePaths, path)
+		return nil
+	})
+	flags.Lookup("input_file").DefValue = "./events.json"
+	flags.StringVar(&options.overlapPolicy, "overlap-policy", "sum", "how the minutes of several input files are merged, sum, first or last")
+	flags.UintVar(&options.windowSize, "window_size", 10, "window size used to calculate the moving average")
+	flags.UintVar(&options.maxWindowSize, "max-window-size", 10080, "maximum of --window_size and --stats-window, in minutes")
+	flags.StringVar(&options.metric, "metric", "mean", "metric calculated over the window, like mean, trimmed-mean or median")
+	flags.StringVar(&options.averageMode, "average-mode", "per-minute", "what the mean is of, per-minute, the sums of the minutes with deliveries, or per-delivery, the durations of the deliveries")
+	flags.StringVar(&options.metric, "agg", "mean", "same as --metric")
+	flags.Float64Var(&options.trim, "trim", 0.1, "fraction of the values discarded from each end of the window by the trimmed mean")
+	flags.Float64Var(&options.percentile, "percentile", 95, "percentile of the window calculated by the percentile metrics, like 95")
+	flags.Func("approx-percentile", "approximate percentile of the window, like 95, same as --metric=approx-percentile --percentile=95", func(value string) (err error) {
+		options.metric = "approx-percentile"
+		options.percentile, err = strconv.ParseFloat(value, 64)
+		return err
+	})
+	flags.StringVar(&options.align, "align", "calendar", "alignment of the minutes, calendar or data")
+	flags.DurationVar(&options.bucket, "bucket", time.Minute, "size of the buckets the events are grouped in, like 10s or 1m")
+	flags.BoolVar(&options.roundTimestampsDown, "round-timestamps-down", false, "label the minutes with their start instead of their end")
+	flags.DurationVar(&options.dedupWindow, "dedup-window", 0, "drop the events identical to an event within this time, like 5s")
+	flags.StringVar(&options.dedupeBy, "dedupe-by", "", "JSON key of the events that identifies the duplicates of --dedup-window, like translation_id")
+	flags.BoolVar(&options.noFutureMinutes, "no-future-minutes", false, "skip the events with a timestamp after the current time")
+	flags.BoolVar(&options.roundToWindow, "round-to-window", false, "start the output at a multiple of the window size since the Unix epoch")
+	flags.BoolVar(&options.assumeSorted, "assume-sorted", false, "read the input file as a stream, it must be sorted by timestamp")
+	flags.BoolVar(&options.requireSorted, "require-sorted", false, "exit with an error if an event is before a previous event of the input file")
+	flags.IntVar(&options.skipLines, "skip-lines", 0, "number of lines at the start of the input file that are not events, like a header")
+	flags.IntVar(&options.skipLines, "skip-header-lines", 0, "same as --skip-lines")
+	flags.IntVar(&options.maxEvents, "max-events", 0, "stop reading the input file after this number of valid events, 0 reads every event")
+	flags.BoolVar(&options.readWindowFromInput, "read-window-from-input", false, "read the window size from a metadata first line of the input file, like {\"window_size\":15}")
+	flags.IntVar(&options.inputBufferSize, "input-buffer-size", 64*1024, "size in bytes of the buffer the input file is read with")
+	flags.IntVar(&options.parseWorkers, "parse-workers", 1, "number of goroutines that parse the lines of the input file in parallel")
+	flags.IntVar(&options.channelBuffer, "channel-buffer", 16, "number of batches of parsed lines queued between the parsing and the windowing with --parse-workers")
+	flags.IntVar(&options.minDeliveries, "min-deliveries", 0, "minimum number of deliveries in the window for a minute to be printed")
+	flags.BoolVar(&options.emitOnChange, "emit-on-change", false, "print a minute only when its average changes from the last printed minute")
+	flags.Float64Var(&options.changeEps, "change-eps", 0, "difference between the averages that is not a change with --emit-on-change")
+	flags.BoolVar(&options.withWindowSpan, "with-window-span", false, "add the oldest and newest minutes in the window to the output")
+	flags.IntVar(&options.outputEvery, "output-every", 1, "print only every nth minute, and the last one")
+	flags.Func("sample-at", "print only the minutes at the top of each hour or day, in UTC", func(value string) error {
+		interval, ok := sampleIntervals[value]
+		if !ok {
+			return fmt.Errorf("invalid sample interval %q, expected hour or day", value)
+		}
+		options.sampleAt = interval
+		return nil
+	})
+	flags.BoolVar(&options.dropFirst, "drop-first", false, "skip the first minute of the output, the padding with an average of 0")
+	flags.BoolVar(&options.withThroughput, "with-throughput", false, "add the number of deliveries per minute in the window to the output")
+	flags.UintVar(&options.statsWindow, "stats-window", 0, "add the percentile of --percentile of a window of this size, in minutes, to the output")
+	flags.BoolVar(&options.failOnEmptyWindow, "fail-on-empty-window", false, "exit with an error if a printed minute has no deliveries in the window")
+	flags.BoolVar(&options.fullWindowOnly, "full-window-only", false, "skip the first minutes of the output, until the window is full")
+	flags.BoolVar(&options.explode, "explode", false, "print the duration of each delivery of every printed minute to stderr")
+	// the ranges can be relative to the current time, they are parsed after the --now flag
+	var rangeStart, rangeEnd string
+	flags.StringVar(&rangeStart, "range-start", "", "first minute of the output, in the format of the timestamps, or relative to now like -1h")
+	flags.StringVar(&rangeEnd, "range-end", "", "last minute of the output, in the format of the timestamps, or relative to now like -5m or now")
+	flags.StringVar(&options.listenTCP, "listen-tcp", "", "address to receive the events from TCP connections instead of the input file")
+	flags.StringVar(&options.serve, "serve", "", "address to serve the moving averages of the input file over HTTP in /moving-average")
+	flags.StringVar(&options.kafka, "kafka", "", "brokers,topic,group of a Kafka topic to consume the events from instead of the input file")
+	flags.Func("report", "report printed to stderr after the output, top-slow, top-slow:N or gaps", func(value string) error {
+		return parseReport(value, &options)
+	})
+	flags.Var(options.clients, "client", "comma separated list of clients whose events are used, can be repeated")
+	flags.Func("clients-file", "path to a file with one client whose events are used per line", func(path string) error {
+		return options.clients.readFile(path)
+	})
+	flags.IntVar(&options.topNClients, "top-n-clients", 0, "print the clients with the most deliveries to stderr")
+	flags.Float64Var(&options.sla, "sla", 0, "maximum average delivery time within the SLA, 0 disables the SLA check")
+	flags.StringVar(&options.baselineFilePath, "baseline", "", "path to a file with the events of a baseline to compare the averages to")
+	flags.StringVar(&options.baselineMode, "baseline-mode", "ratio", "comparison to the baseline, ratio or difference")
+	flags.Float64Var(&options.baselineGap, "baseline-gap", 0, "value of the comparison for the minutes missing in the baseline")
+	flags.StringVar(&options.nanPolicy, "nan-policy", "zero", "how the values of the output that are NaN or infinite are written, zero (the gap value), null or error")
+	flags.Func("gap-value", "value written instead of the values of the output that are NaN or infinite, or ffill to repeat the average of the last window with deliveries in the empty windows (default 0)", func(value string) (err error) {
+		if value == "ffill" {
+			options.fillForward = true
+			return nil
+		}
+		options.gapValue, err = strconv.ParseFloat(value, 64)
+		return err
+	})
+	flags.BoolVar(&options.normalize, "normalize", false, "add the average scaled to the 0-1 range to the output")
+	flags.BoolVar(&options.diff, "diff", false, "add the difference to the previous minute's average to the output")
+	flags.BoolVar(&options.delta, "delta", false, "add the difference to the previous minute's average to the output, in the delta field")
+	flags.BoolVar(&options.downsample, "downsample", false, "print one minute for each window of input minutes, with windows that don't overlap")
+	flags.BoolVar(&options.compactZeros, "compact-zeros", false, "print the runs of minutes with an average of 0 as one line with their range")
+	flags.BoolVar(&options.compactZeros, "merge-adjacent-zero-runs", false, "same as --compact-zeros")
+	flags.BoolVar(&options.downsample, "coalesce-window", false, "same as --downsample")
+	flags.BoolVar(&options.raw, "raw", false, "print the sum of the durations and the number of deliveries of each minute, without the window")
+	flags.StringVar(&options.format, "format", "json", "format of the output, json, json-array or csv")
+	flags.StringVar(&options.color, "color", "auto", "color the lines of the output in the console by the average, auto, always or never")
+	flags.Func("thresholds", "averages where the color changes from green to yellow and from yellow to red (default \"30,60\")", func(value string) error {
+		return parseThresholds(value, &options.thresholds)
+	})
+	flags.BoolVar(&options.jsonNumbersAsStrings, "json-numbers-as-strings", false, "write the average of the JSON output as a string")
+	flags.BoolVar(&options.jsonLinesStrict, "json-lines-strict", false, "check that every line of the output is one JSON object, without colors")
+	flags.Func("line-separator", "separator between the JSON objects of the output, with escapes like \\t (default \"\\n\")", func(value string) error {
+		return parseLineSeparator(value, &options.lineSeparator)
+	})
+	flags.StringVar(&options.dateFormat, "date-format", "timestamp", "format of the date of the minutes, timestamp, offset, the number of buckets since the first minute, us, eu or a Go layout")
+	flags.Func("autoflush", "flush the output after every N rows or D duration, like rows:100 or duration:1s", func(value string) (err error) {
+		options.autoFlush, err = parseAutoFlush(value)
+		return err
+	})
+	flags.Float64Var(&options.replaySpeed, "replay-speed", 0, "print each minute of the input file when its time comes, with the time scaled by this factor, like 60")
+	flags.BoolVar(&options.unixTimestamps, "unix-ts", false, "write the date of the minutes as the number of seconds since the Unix epoch")
+	flags.BoolVar(&options.retainInputTz, "retain-input-tz", false, "write the dates of the output in RFC 3339 with the offset of the timestamps of the input")
+	flags.BoolVar(&options.decimalComma, "decimal-comma", false, "use a comma as the decimal separator and a semicolon as the delimiter of the CSV output")
+	flags.StringVar(&options.outputFilePath, "output_file", "", "path to the output file, the console is used if empty")
+	flags.BoolVar(&options.gzipOutput, "gzip-output", false, "compress the output with gzip")
+	flags.BoolVar(&options.checksum, "checksum", false, "print the SHA-256 of the output to stderr")
+	flags.DurationVar(&options.metricsInterval, "metrics-interval", 0, "print the number of events and rows to stderr every interval, like 10s")
+	flags.StringVar(&options.metricsAddr, "metrics-addr", "", "address to serve the counters of the run in the Prometheus format in /metrics, like :9100")
+	flags.StringVar(&options.statsFilePath, "stats-json", "", "path to a file where the statistics of the run are written")
+	flags.StringVar(&options.bucketsFilePath, "dump-buckets", "", "path to a file where the sum and count of the deliveries of each minute are written")
+	flags.StringVar(&options.splitOutputDir, "split-output-dir", "", "path to a directory where the output of each language pair is written to its own file")
+	flags.StringVar(&options.splitOutputDir, "output-dir", "", "same as --split-output-dir")
+	flags.StringVar(&options.groupBy, "group-by", "language_pair", "group of the events of each file of --split-output-dir, language_pair, target_language or stream_id")
+	flags.StringVar(&options.compareFilePath, "compare", "", "path to an output of a previous run to compare the series with, printing the rows that differ")
+	flags.Float64Var(&options.compareEps, "compare-eps", 0, "difference between the numbers of the rows that is not a difference with --compare")
+	flags.Var(options.inputFieldMap, "input-field-map", "comma separated list of field=name pairs to read the fields from other JSON names")
+	flags.BoolVar(&options.strictSchema, "strict-schema", false, "reject the events with fields that are not in the schema of the events")
+	flags.Func("timestamp-field", "name of the JSON key with the timestamp (default \"timestamp\")", func(name string) error {
+		return options.inputFieldMap.Set("timestamp=" + name)
+	})
+	flags.Func("duration-field", "name of the JSON key with the duration (default \"duration\")", func(name string) error {
+		return options.inputFieldMap.Set("duration=" + name)
+	})
+	flags.StringVar(&options.durationUnit, "duration_unit", "s", "unit of the durations, ms, s or m, the events with a duration_unit field are converted to it")
+	flags.IntVar(&options.maxDuration, "max-duration", 0, "maximum duration of a delivery, the longer durations are clamped to it, 0 doesn't clamp them")
+	flags.Func("value-field", "name of the numeric JSON key that is averaged (default \"duration\")", func(name string) error {
+		return options.inputFieldMap.Set("duration=" + name)
+	})
 
-	// call the function that will read the file and return the data from the file ready to perform the calculations
-	translationsDeliveriesData, firstMinute, lastMinute := readTranslationsFileAndProcessData(*filePath)
+	var businessHoursZone string
+	flags.Func("business-hours", "daily hours of the events that are used and the minutes that are printed, like 09:00-18:00", func(value string) (err error) {
+		options.businessHours, err = parseBusinessHours(value)
+		return err
+	})
+	flags.StringVar(&businessHoursZone, "business-hours-tz", "UTC", "time zone of --business-hours, a name like Europe/Lisbon or an offset like +01:00")
 
-	// this array will work as a FIFO/Queue to store the values of the moving window
-	var movingAverageQueue []int
+	// hidden flag for the tests of the values relative to the current time, it is not in the usage
+	flags.Func("now", "", func(value string) error {
+		now, err := parseNow(value)
+		options.clock = func() time.Time { return now }
+		return err
+	})
+	flags.Usage = func() { printUsage(flags, "now") }
 
-	// iterating from the first minute a delivery occurred to the last minute a delivery ocurred
-	// using time.Time to progress in time
-	for currentMinute := firstMinute; !currentMinute.After(lastMinute); currentMinute = currentMinute.Add(time.Minute) {
-		var currentAverage float64
+	if err := flags.Parse(arguments); err != nil {
+		return options, err
+	}
 
-		// getting the duration of the deliveries for this minute in time
-		// need to convert to string to use as a key in the map
-		var currentMinuteData = translationsDeliveriesData[currentMinute.Format("2006-01-02 15:04:05")]
+	// the values in the config file are only used for the flags that are not in the command line
+	if options.configFilePath != "" {
+		if err := applyConfigFile(flags, options.configFilePath); err != nil {
+			return options, err
+		}
+	}
 
-		// update the elements in the queue
-		// if we don't have data for the current minute in the map, it defaults to 0
-		movingAverageQueue = updateMovingWindowQueue(movingAverageQueue, *windowSize, currentMinuteData)
+	// the first input file is the input of the flags that read one file
+	if len(options.inputFilePaths) == 0 {
+		options.inputFilePaths = []string{"./events.json"}
+	}
+	options.inputFilePath = options.inputFilePaths[0]
 
-		// calculating the moving average
-		currentAverage = calculateMovingAverage(movingAverageQueue)
+	// the metadata line is not an event, it is skipped even if the window size is set by the flags
+	if options.readWindowFromInput {
+		windowSize, found, err := readInputMetadata(options.inputFilePath)
+		if err != nil {
+			return options, err
+		}
 
-		// create the object with the data to print
-		printableValues, _ := json.Marshal(PrintableValues{
-			Date:                  currentMinute.Format("2006-01-02 15:04:05"),
-			Average_delivery_time: currentAverage,
+		var windowSizeSet bool
+		flags.Visit(func(setFlag *flag.Flag) {
+			windowSizeSet = windowSizeSet || setFlag.Name == "window_size"
 		})
 
-		// print the values to the console
-		// the challenge mentions an output file, but not a name for the file
-		// I'm also assuming some automated tests will be ran and the output will be read from the console
-		fmt.Println(string(printableValues))
+		if found {
+			options.skipLines++
+			if !windowSizeSet {
+				options.windowSize = windowSize
+			}
+		}
+	}
+
+	// the zone of the business hours can be before or after them in the command line
+	if options.businessHours != nil {
+		location, err := parseLocation(businessHoursZone)
+		if err != nil {
+			return options, err
+		}
+		options.businessHours.location = location
+	}
+
+	// the relative ranges are resolved with the clock, only known after all the flags are parsed
+	var err error
+	if options.rangeStart, err = parseRangeValue(rangeStart, options.clock); err != nil {
+		return options, fmt.Errorf("invalid value %q for flag -range-start: %w", rangeStart, err)
+	}
+	if options.rangeEnd, err = parseRangeValue(rangeEnd, options.clock); err != nil {
+		return options, fmt.Errorf("invalid value %q for flag -range-end: %w", rangeEnd, err)
+	}
+
+	// --unix-ts conflicts with a --date-format that is set, even with its default value
+	var dateFormatSet bool
+	flags.Visit(func(setFlag *flag.Flag) {
+		dateFormatSet = dateFormatSet || setFlag.Name == "date-format"
+	})
+	if options.unixTimestamps && dateFormatSet {
+		return options, errors.New("--unix-ts can't be used with --date-format")
 	}
+
+	return validateOptions(options)
 }
 
-// function to update the moving average queue
-// encapsulates the logic to add and remove elements to/from the queue
-func updateMovingWindowQueue(movingAverageQueue []int, windowSize uint, currentMinuteData int) []int {
-	// add the current minute data to the FIFO
-	movingAverageQueue = append(movingAverageQueue, currentMinuteData)
+// function to validate the options of the flags, also used by Compute with the options set by its functions
+// the range is also truncated to whole buckets
+func validateOptions(options options) (options, error) {
+	if options.averageMode != "per-minute" && options.averageMode != "per-delivery" {
+		return options, fmt.Errorf("invalid average mode %q, expected per-minute or per-delivery", options.averageMode)
+	}
+	if options.averageMode == "per-delivery" && options.metric != "mean" {
+		return options, errors.New("--average-mode=per-delivery can only be used with --metric=mean")
+	}
+	if _, ok := aggregators[options.metric]; !ok {
+		return options, fmt.Errorf("invalid metric %q, expected one of %s", options.metric, aggregatorNames())
+	}
+	if _, ok := durationUnits[options.durationUnit]; !ok {
+		return options, fmt.Errorf("invalid duration unit %q, expected ms, s or m", options.durationUnit)
+	}
+	if options.format != "json" && options.format != "json-array" && options.format != "csv" {
+		return options, fmt.Errorf("invalid format %q, expected json, json-array or csv", options.format)
+	}
+	if options.format == "json-array" && options.listenTCP != "" {
+		return options, errors.New("--format=json-array can't be used with --listen-tcp")
+	}
+	if options.jsonLinesStrict && (options.format != "json" || options.lineSeparator != "\n" || options.listenTCP != "" || options.kafka != "") {
+		return options, errors.New("--json-lines-strict can only be used with --format=json and the default --line-separator, and not with --listen-tcp or --kafka")
+	}
+	if options.dateFormat != "timestamp" && options.dateFormat != "offset" && !isDateLayout(dateLayoutOf(options.dateFormat)) {
+		return options, fmt.Errorf("invalid date format %q, expected timestamp, offset, us, eu or a Go layout like \"02.01.2006 15:04\"", options.dateFormat)
+	}
+	if options.lineSeparator != "\n" && (options.listenTCP != "" || options.kafka != "") {
+		return options, errors.New("--line-separator can't be used with --listen-tcp or --kafka")
+	}
+	if options.retainInputTz && (options.dateFormat != "timestamp" || options.unixTimestamps || options.listenTCP != "" || options.kafka != "") {
+		return options, errors.New("--retain-input-tz can't be used with --date-format other than timestamp, --unix-ts, --listen-tcp or --kafka")
+	}
+	if options.report == "gaps" && options.assumeSorted {
+		return options, errors.New("--report=gaps can't be used with --assume-sorted")
+	}
+	if options.decimalComma && options.format != "csv" {
+		return options, errors.New("--decimal-comma can only be used with --format=csv")
+	}
+	if options.color != "auto" && options.color != "always" && options.color != "never" {
+		return options, fmt.Errorf("invalid color %q, expected auto, always or never", options.color)
+	}
+	if options.align != "calendar" && options.align != "data" {
+		return options, fmt.Errorf("invalid alignment %q, expected calendar or data", options.align)
+	}
+	if options.align == "data" && (!options.rangeStart.IsZero() || !options.rangeEnd.IsZero()) {
+		return options, errors.New("--range-start and --range-end can only be used with --align=calendar")
+	}
+	if options.align == "data" && options.roundToWindow {
+		return options, errors.New("--round-to-window can only be used with --align=calendar")
+	}
+	// the windows are checked before they are converted to durations, which would overflow with the biggest sizes
+	if options.maxWindowSize == 0 {
+		return options, errors.New("invalid max window size 0, expected a positive integer")
+	}
+	if options.windowSize > options.maxWindowSize {
+		return options, fmt.Errorf("invalid window size %d, expected a value up to the max window size %d (--max-window-size)", options.windowSize, options.maxWindowSize)
+	}
+	if options.statsWindow > options.maxWindowSize {
+		return options, fmt.Errorf("invalid stats window %d, expected a value up to the max window size %d (--max-window-size)", options.statsWindow, options.maxWindowSize)
+	}
+	if options.bucket <= 0 || (time.Duration(options.windowSize)*time.Minute)%options.bucket != 0 {
+		return options, fmt.Errorf("invalid bucket %v, expected a positive size that divides the window of %d minutes", options.bucket, options.windowSize)
+	}
+	if options.assumeSorted && (options.roundToWindow || !options.rangeStart.IsZero() || !options.rangeEnd.IsZero()) {
+		return options, errors.New("--round-to-window, --range-start and --range-end can't be used with --assume-sorted")
+	}
+	if options.downsample && (options.raw || options.assumeSorted || options.listenTCP != "" || options.kafka != "") {
+		return options, errors.New("--downsample can't be used with --raw, --assume-sorted, --listen-tcp or --kafka")
+	}
+	if options.downsample && options.windowSize == 0 {
+		return options, errors.New("--downsample needs a window size greater than 0")
+	}
+	if options.raw && (options.assumeSorted || options.listenTCP != "" || options.kafka != "") {
+		return options, errors.New("--raw can't be used with --assume-sorted, --listen-tcp or --kafka")
+	}
+	if options.dedupWindow < 0 {
+		return options, fmt.Errorf("invalid dedup window %v, expected a positive duration", options.dedupWindow)
+	}
+	if options.dedupeBy != "" && options.dedupWindow == 0 {
+		return options, errors.New("--dedupe-by needs --dedup-window")
+	}
+	if options.dropFirst && options.roundTimestampsDown {
+		return options, errors.New("--drop-first can't be used with --round-timestamps-down, the output has no padding minute to skip")
+	}
+	if options.metricsInterval < 0 {
+		return options, fmt.Errorf("invalid metrics interval %v, expected a positive duration", options.metricsInterval)
+	}
+	if options.metricsAddr != "" && options.listenTCP == "" && options.kafka == "" && options.serve == "" {
+		return options, errors.New("--metrics-addr can only be used with --listen-tcp, --kafka or --serve")
+	}
+	if options.baselineMode != "ratio" && options.baselineMode != "difference" {
+		return options, fmt.Errorf("invalid baseline mode %q, expected ratio or difference", options.baselineMode)
+	}
+	if options.baselineFilePath != "" && (options.listenTCP != "" || options.kafka != "") {
+		return options, errors.New("--baseline can't be used with --listen-tcp or --kafka")
+	}
+	if options.outputEvery < 1 {
+		return options, fmt.Errorf("invalid output every %d, expected a positive integer", options.outputEvery)
+	}
+	if options.outputEvery > 1 && (options.listenTCP != "" || options.kafka != "") {
+		return options, errors.New("--output-every can't be used with --listen-tcp or --kafka")
+	}
+	if options.bucketsFilePath != "" && (options.assumeSorted || options.splitOutputDir != "" || options.listenTCP != "" || options.kafka != "") {
+		return options, errors.New("--dump-buckets can't be used with --assume-sorted, --split-output-dir, --listen-tcp or --kafka")
+	}
+	if options.splitOutputDir != "" && (options.outputFilePath != "" || options.statsFilePath != "" || options.listenTCP != "" || options.kafka != "") {
+		return options, errors.New("--split-output-dir can't be used with --output_file, --stats-json, --listen-tcp or --kafka")
+	}
+	if options.overlapPolicy != "sum" && options.overlapPolicy != "first" && options.overlapPolicy != "last" {
+		return options, fmt.Errorf("invalid overlap policy %q, expected sum, first or last", options.overlapPolicy)
+	}
+	// the other readers of the input read one file
+	if len(options.inputFilePaths) > 1 && (options.assumeSorted || options.splitOutputDir != "" || options.groupBy == "stream_id" || options.readWindowFromInput || options.align == "data" || options.serve != "") {
+		return options, errors.New("several --input_file can't be used with --assume-sorted, --split-output-dir, --group-by=stream_id, --read-window-from-input, --align=data or --serve")
+	}
+	if options.sampleAt > 0 && (options.outputEvery > 1 || options.downsample || options.raw || options.align == "data") {
+		return options, errors.New("--sample-at can't be used with --output-every, --downsample, --raw or --align=data")
+	}
+	if options.sampleAt > 0 && options.sampleAt%options.bucket != 0 {
+		return options, fmt.Errorf("invalid bucket %v, expected a size that divides the interval of --sample-at", options.bucket)
+	}
+	if options.replaySpeed < 0 {
+		return options, fmt.Errorf("invalid replay speed %v, expected a value greater or equal to 0", options.replaySpeed)
+	}
+	if options.replaySpeed > 0 && (options.assumeSorted || options.normalize || options.raw || options.downsample || options.listenTCP != "" || options.kafka != "" ||
+		options.splitOutputDir != "" || options.groupBy == "stream_id" || options.serve != "") {
+		return options, errors.New("--replay-speed can't be used with --assume-sorted, --normalize, --raw, --downsample, --listen-tcp, --kafka, --split-output-dir, --group-by=stream_id or --serve")
+	}
+	// the replayed rows are flushed one by one, like the streams
+	if options.replaySpeed > 0 && !options.autoFlush.enabled() {
+		options.autoFlush = autoFlush{rows: 1}
+	}
+	if options.serve != "" && (options.assumeSorted || options.raw || options.downsample || options.listenTCP != "" || options.kafka != "" || options.splitOutputDir != "" ||
+		options.groupBy == "stream_id" || options.compareFilePath != "" || options.nanPolicy == "null") {
+		return options, errors.New("--serve can't be used with --assume-sorted, --raw, --downsample, --listen-tcp, --kafka, --split-output-dir, --group-by=stream_id, --compare or --nan-policy=null")
+	}
+	if options.groupBy != "language_pair" && options.groupBy != "target_language" && options.groupBy != "stream_id" {
+		return options, fmt.Errorf("invalid group by %q, expected language_pair, target_language or stream_id", options.groupBy)
+	}
+	if options.groupBy == "target_language" && options.splitOutputDir == "" {
+		return options, errors.New("--group-by=target_language needs --split-output-dir")
+	}
+	// the series of the streams are calculated in one read, without the flags that need the whole series of the file
+	if options.groupBy == "stream_id" && options.splitOutputDir == "" && (options.normalize || options.baselineFilePath != "" || options.report != "" ||
+		options.downsample || options.raw || options.assumeSorted || options.compactZeros || options.outputEvery > 1 || options.explode || options.topNClients > 0 ||
+		options.failOnEmptyWindow || options.statsFilePath != "" || options.bucketsFilePath != "" || options.compareFilePath != "" || options.listenTCP != "" || options.kafka != "") {
+		return options, errors.New("--group-by=stream_id without --split-output-dir can't be used with --normalize, --baseline, --report, --downsample, --raw, --assume-sorted, " +
+			"--compact-zeros, --output-every, --explode, --top-n-clients, --fail-on-empty-window, --stats-json, --dump-buckets, --compare, --listen-tcp or --kafka")
+	}
+	if options.compareFilePath != "" && (options.outputFilePath != "" || options.splitOutputDir != "" || options.raw || options.format == "csv" || options.dateFormat != "timestamp" || options.unixTimestamps || options.listenTCP != "" || options.kafka != "") {
+		return options, errors.New("--compare can't be used with --output_file, --split-output-dir, --raw, --format=csv, --date-format=offset, --unix-ts, --listen-tcp or --kafka")
+	}
+	if options.compareEps < 0 {
+		return options, fmt.Errorf("invalid compare eps %v, expected a value greater or equal to 0", options.compareEps)
+	}
+	if options.changeEps < 0 {
+		return options, fmt.Errorf("invalid change eps %v, expected a value greater or equal to 0", options.changeEps)
+	}
+	if options.percentile <= 0 || options.percentile > 100 {
+		return options, fmt.Errorf("invalid percentile %v, expected a value in the (0, 100] range", options.percentile)
+	}
+	if options.checksum && (options.listenTCP != "" || options.kafka != "") {
+		return options, errors.New("--checksum can't be used with --listen-tcp or --kafka")
+	}
+	if options.nanPolicy != "zero" && options.nanPolicy != "null" && options.nanPolicy != "error" {
+		return options, fmt.Errorf("invalid nan policy %q, expected zero, null or error", options.nanPolicy)
+	}
+	if options.nanPolicy == "error" && (options.listenTCP != "" || options.kafka != "") {
+		return options, errors.New("--nan-policy=error can't be used with --listen-tcp or --kafka")
+	}
+	if options.compactZeros && (options.format == "csv" || options.raw || options.listenTCP != "" || options.kafka != "") {
+		return options, errors.New("--compact-zeros can't be used with --format=csv, --raw, --listen-tcp or --kafka")
+	}
+	if options.maxEvents < 0 {
+		return options, fmt.Errorf("invalid maximum number of events %d, expected a number greater or equal to 0", options.maxEvents)
+	}
+	if options.skipLines < 0 {
+		return options, fmt.Errorf("invalid skip lines %d, expected a value greater or equal to 0", options.skipLines)
+	}
+	if options.inputBufferSize < 16 {
+		return options, fmt.Errorf("invalid input buffer size %d, expected at least 16 bytes", options.inputBufferSize)
+	}
+	if options.parseWorkers < 1 {
+		return options, fmt.Errorf("invalid parse workers %d, expected a positive integer", options.parseWorkers)
+	}
+	if options.maxDuration < 0 {
+		return options, fmt.Errorf("invalid max duration %d, expected a value greater or equal to 0", options.maxDuration)
+	}
+	if options.channelBuffer < 0 {
+		return options, fmt.Errorf("invalid channel buffer %d, expected a value greater or equal to 0", options.channelBuffer)
+	}
+	if math.IsNaN(options.gapValue) || math.IsInf(options.gapValue, 0) {
+		return options, fmt.Errorf("invalid gap value %v, expected a finite number", options.gapValue)
+	}
+	if options.trim < 0 || options.trim >= 0.5 {
+		return options, fmt.Errorf("invalid trim %v, expected a value in the [0, 0.5) range", options.trim)
+	}
+	if (options.listenTCP != "" || options.kafka != "") && options.normalize {
+		return options, errors.New("--normalize needs the whole series and can't be used with --listen-tcp or --kafka")
+	}
 
-	// if the FIFO has more elements than the "windowSize" we remove the first element
-	if int64(len(movingAverageQueue)) > int64(windowSize) {
-		movingAverageQueue = movingAverageQueue[1:]
+	// the range starts and ends in whole buckets
+	options.rangeStart = options.rangeStart.Truncate(options.bucket)
+	options.rangeEnd = options.rangeEnd.Truncate(options.bucket)
+	if !options.rangeStart.IsZero() && !options.rangeEnd.IsZero() && options.rangeStart.After(options.rangeEnd) {
+		return options, fmt.Errorf("invalid range, the start %s is after the end %s", options.rangeStart.Format("2006-01-02 15:04:05"), options.rangeEnd.Format("2006-01-02 15:04:05"))
 	}
 
-	return movingAverageQueue
+	return options, nil
 }
 
-// function to calculate the moving average for the current window
-func calculateMovingAverage(movingAverageQueue []int) float64 {
-	var sum int
-	var numberMinutesWithDeliveries = 0
+// function to print the usage of the flags, like the default usage but without the hidden flags
+func printUsage(flags *flag.FlagSet, hidden ...string) {
+	var visible = flag.NewFlagSet(flags.Name(), flag.ContinueOnError)
+	visible.SetOutput(flags.Output())
 
-	// cycle through the queue that holds the values for the current and past minutes within the window size interval
-	for i := 0; i < len(movingAverageQueue); i++ {
-		// this condition is necessary to be compliant with the example given that excludes minutes with no deliveries from the calculations
-		if movingAverageQueue[i] > 0 {
-			// calculate the sum for all values bigger than 0
-			// calculate how many values bigger than 0 there are in que queue
-			sum += movingAverageQueue[i]
-			numberMinutesWithDeliveries++
+	flags.VisitAll(func(f *flag.Flag) {
+		if slices.Contains(hidden, f.Name) {
+			return
 		}
 
-	}
+		// the default value is copied, the value may have changed while parsing
+		visible.Var(f.Value, f.Name, f.Usage)
+		visible.Lookup(f.Name).DefValue = f.DefValue
+	})
 
-	// guarding against the case that the file has in interval larger than the window size
-	// in that case the default value is 0
-	// else we divide the sum per the number of minutes with deliveries
-	if numberMinutesWithDeliveries == 0 {
-		return 0
-	} else {
-		return float64(sum) / float64(numberMinutesWithDeliveries)
-	}
+	fmt.Fprintf(flags.Output(), "Usage of %s:\n", flags.Name())
+	visible.PrintDefaults()
 }
 
-// function
-// a map that for which minute in which translations were delivered has the sum of the duration of the deliveries
-// the first minute a translation delivery occurred
-// the last minute a translation delivery occurred
-func readTranslationsFileAndProcessData(filePath string) (map[string]int, time.Time, time.Time) {
+// function with the logic of the program
+// receives the command line arguments and the writers for the console so it can be called from the tests
+// stderr is used for messages that are not part of the output
+// if the context is canceled it stops calculating, flushes the output and returns the context error
+func run(ctx context.Context, arguments []string, stdout io.Writer, stderr io.Writer) error {
+	// the validate subcommand has the same flags, but only checks the input file
+	var validate = len(arguments) > 0 && arguments[0] == "validate"
+	if validate {
+		arguments = arguments[1:]
+	}
 
-	// open the file using the path received in the command line flag
-	file, error := os.Open(filePath)
+	options, err := parseFlags(arguments)
+	if err != nil {
+		return err
+	}
 
-	// exit with error if unable to open the file
-	if error != nil {
-		panic(error)
+	if validate {
+		return runValidate(options, stdout)
 	}
 
-	// defer the close of the file at the return of this function
-	defer file.Close()
+	// the automatic color depends on whether the console is a terminal, that is only known here
+	if options.color == "auto" {
+		options.color = "never"
+		if isTerminal(stdout) {
+			options.color = "always"
+		}
+	}
 
-	var scanner = bufio.NewScanner(file)
-	var firstMinute time.Time
-	var deliveredTranslation DeliveredTranslation
-	var numberTranslationsPerMinuteUTC = make(map[string]int)
+	// the zone of the output is the offset of the first event, it is set when the events are read
+	if options.retainInputTz {
+		options.inputZone = &inputZone{}
+	}
 
-	// read the file line by line
-	for scanner.Scan() {
+	// the counters are printed periodically while the program runs, or served over HTTP
+	if options.metricsInterval > 0 || options.metricsAddr != "" {
+		options.metrics = &runMetrics{}
+	}
+	if options.metricsInterval > 0 {
+		stopMetrics := startMetricsReporter(options.metrics, options.metricsInterval, stderr)
+		defer stopMetrics()
+	}
+	if options.metricsAddr != "" {
+		listener, err := net.Listen("tcp", options.metricsAddr)
+		if err != nil {
+			return err
+		}
 
-		// read the file and map the content to a DeliveredTranslation struct
-		json.Unmarshal([]byte(scanner.Text()), &deliveredTranslation)
+		fmt.Fprintln(stderr, "serving metrics on", listener.Addr())
+		stopServer := serveMetrics(listener, options.metrics)
+		defer stopServer()
+	}
 
-		// parsing the string timestamp to a time.Time object
-		// truncating it to the minute - to have simpler keys in the map
-		// adding one minute to the event - to make it coherent with the example
-		// converting it back to a string
-		currentMinute, _ := time.Parse("2006-01-02 15:04:05", deliveredTranslation.Timestamp)
-		currentMinute = currentMinute.Truncate(time.Minute).Add(time.Minute)
-		deliveredTranslation.Timestamp = currentMinute.Format("2006-01-02 15:04:05")
+	// the moving averages of the input file are served over HTTP instead of printed
+	if options.serve != "" {
+		return listenAndServeHTTP(ctx, options, stderr)
+	}
 
-		// for each minute we had a delivery we calculate how long the deliveries for that minute took
-		// and store them in a map whose key is the truncated timestamp - just the minute
-		numberTranslationsPerMinuteUTC[deliveredTranslation.Timestamp] = numberTranslationsPerMinuteUTC[deliveredTranslation.Timestamp] + deliveredTranslation.Duration
+	// the events are received from TCP connections and the output is printed as they arrive
+	if options.listenTCP != "" {
+		return listenAndStreamTCP(ctx, options, stdout, stderr)
+	}
 
-		// since the information is stored in a map and not ordered
-		// as the file is read the minute of the first event is stored
-		if firstMinute.IsZero() {
-			firstMinute, _ = time.Parse("2006-01-02 15:04:05", deliveredTranslation.Timestamp)
-			firstMinute = firstMinute.Add(-time.Minute)
-		}
+	// the events are consumed from a Kafka topic and the output is printed as they arrive
+	if options.kafka != "" {
+		return consumeKafkaAndStream(ctx, options, stdout)
 	}
 
-	// the last minute when a delivery ocurred is also stored
-	lastMinute, _ := time.Parse("2006-01-02 15:04:05", deliveredTranslation.Timestamp)
+	// the events of each language pair are written to their own file
+	if options.splitOutputDir != "" {
+		return splitByLanguagePair(ctx, options, stdout, stderr)
+	}
+
+	// the series of each stream of a multiplexed input are printed in one output
+	if options.groupBy == "stream_id" {
+		return runStreamsSeries(ctx, options, stdout, stderr)
+	}
+
+	// the series is compared with a saved output instead of being printed
+	if options.compareFilePath != "" {
+		return runCompare(ctx, options, stdout, stderr)
+	}
+
+	return runSeries(ctx, options, stdout, stderr)
+}
+
+// function to calculate and print the series of the events of the input file
+// the options are already parsed, so it can be called for each language pair with --split-output-dir
+func runSeries(ctx context.Context, options options, stdout io.Writer, stderr io.Writer) error {
+	var err error
+	var translationsDeliveriesData map[string]MinuteDeliveries
+	var firstMinute, lastMinute time.Time
+	var eventsStatistics EventsStatistics
+	var sortedFile *os.File
+
+	// the minutes of the first and the last delivery, before the range of the output is changed (--report=gaps)
+	var firstDataMinute, lastDataMinute time.Time
+
+	if options.assumeSorted {
+		// the sorted file is read as the minutes are calculated, it is only opened here
+		sortedFile, err = os.Open(options.inputFilePath)
+		if err != nil {
+			return err
+		}
+		defer sortedFile.Close()
+	} else {
+		// call the function that will read the file and return the data from the file ready to perform the calculations
+		translationsDeliveriesData, firstMinute, lastMinute, eventsStatistics, err = readInputFilesAndProcessData(options)
+		if err != nil {
+			return err
+		}
+		firstDataMinute, lastDataMinute = firstMinute.Add(labelOffset(options)), lastMinute
+
+		// the minutes are written as they were aggregated, for the inspection of the averages
+		if options.bucketsFilePath != "" {
+			if err := writeBuckets(options.bucketsFilePath, translationsDeliveriesData); err != nil {
+				return err
+			}
+		}
+
+		// the start is moved back to a window boundary, so the rows of different files are comparable
+		if options.roundToWindow {
+			firstMinute = roundDownToWindow(firstMinute, options.windowSize)
+		}
+
+		// a fixed range replaces the minutes of the data
+		if !options.rangeStart.IsZero() {
+			firstMinute = options.rangeStart
+		}
+		if !options.rangeEnd.IsZero() {
+			lastMinute = options.rangeEnd
+		}
+	}
+
+	// the averages of the baseline are calculated before the output is created, to compare each minute to them
+	var baselineAverages map[string]float64
+	if options.baselineFilePath != "" {
+		baselineAverages, err = calculateBaselineAverages(options)
+		if err != nil {
+			return err
+		}
+	}
+
+	// with --checksum a copy of the output is hashed as it is written
+	var checksum hash.Hash
+	if options.checksum {
+		checksum = sha256.New()
+	}
+
+	// get where the output will be written
+	// the close function must be called at the end, otherwise a gzipped file is not valid
+	output, closeOutput, err := createOutputWriter(stdout, options.outputFilePath, options.gzipOutput, checksum)
+	if err != nil {
+		return err
+	}
+
+	// with --autoflush the buffered output is flushed while the minutes are printed, not only at the end
+	var rowsOutput io.Writer = output
+	var flusher *autoFlushWriter
+	if options.autoFlush.enabled() {
+		flusher = newAutoFlushWriter(output, options.autoFlush)
+		rowsOutput = flusher
+	}
+
+	var printer = newValuesPrinter(rowsOutput, options)
+	printer.warnings = stderr
+
+	// the minutes are printed with this function, with --compact-zeros the runs of zeros are kept until they end
+	var printValues = printer.print
+	var compactor *zeroRunsCompactor
+	if options.compactZeros {
+		compactor = &zeroRunsCompactor{print: printer.print}
+		printValues = compactor.add
+	}
+
+	// function to end the output, the checksum is printed once every byte is written
+	var finishOutput = func() error {
+		if compactor != nil {
+			compactor.flush()
+		}
+		printer.finish()
+		if flusher != nil {
+			flusher.stop()
+		}
+		if err := closeOutput(); err != nil {
+			return err
+		}
+
+		if checksum != nil {
+			printChecksum(stderr, checksum, options.outputFilePath)
+		}
+
+		// with --nan-policy=error the output ends before the first value that is not finite
+		return printer.err
+	}
+
+	// the raw values of the minutes with deliveries are printed as they are in the map, without calculating the window
+	if options.raw {
+		for currentMinute := firstMinute; !currentMinute.After(lastMinute) && ctx.Err() == nil; currentMinute = currentMinute.Add(options.bucket) {
+			var date = currentMinute.Format("2006-01-02 15:04:05")
+			if currentMinuteData, ok := translationsDeliveriesData[date]; ok {
+				printer.printRaw(RawMinute{Date: date, Sum_duration: currentMinuteData.Duration, Count: currentMinuteData.Count})
+			}
+		}
+
+		if err := finishOutput(); err != nil {
+			return err
+		}
+		return ctx.Err()
+	}
+
+	// the minutes are grouped in windows that don't overlap, and each group is printed as one minute
+	if options.downsample {
+		downsampleMinutes(ctx, translationsDeliveriesData, firstMinute, lastMinute, options, printValues)
+
+		if err := finishOutput(); err != nil {
+			return err
+		}
+		return ctx.Err()
+	}
+
+	// the state of the moving window as the minutes are calculated
+	var window = &movingWindow{options: options}
+
+	// the normalization needs the minimum and maximum of the whole series
+	// so when it is enabled the values are kept here and only printed after the loop
+	var series []PrintableValues
+
+	// the reports also need the whole series, but they only use the date and the average
+	var reportSeries []PrintableValues
+
+	// function to handle the values of a minute that must be printed
+	var handleMinute = func(printableValues PrintableValues, currentMinuteData MinuteDeliveries) {
+		if baselineAverages != nil {
+			vsBaseline := compareToBaseline(printableValues.Date, printableValues.Average_delivery_time, baselineAverages, options)
+			printableValues.Vs_baseline = &vsBaseline
+		}
+
+		if options.explode {
+			fmt.Fprintln(stderr, printableValues.Date, currentMinuteData.Durations)
+		}
+
+		if options.report != "" {
+			reportSeries = append(reportSeries, PrintableValues{Date: printableValues.Date, Average_delivery_time: printableValues.Average_delivery_time})
+		}
+
+		if options.normalize {
+			series = append(series, printableValues)
+			return
+		}
+
+		printValues(printableValues)
+	}
+
+	// with --output-every only every nth minute is handled
+	// the skipped minute is kept until the next one, so the last minute of the series can be handled after the loop
+	var outputIndex int
+	var skippedMinute *PrintableValues
+	var skippedMinuteData MinuteDeliveries
+	var printMinute = func(printableValues PrintableValues, currentMinuteData MinuteDeliveries) {
+		outputIndex++
+		if options.outputEvery > 1 && (outputIndex-1)%options.outputEvery != 0 {
+			skippedMinute, skippedMinuteData = &printableValues, currentMinuteData
+			return
+		}
+
+		skippedMinute = nil
+		handleMinute(printableValues, currentMinuteData)
+	}
+
+	if options.assumeSorted {
+		// the minutes are calculated as the events are read, the stream has its own window
+		// the stream emits a minute before moving to the next one, so its data is still the data of the emitted minute
+		var stream = newEventsStream(options, nil)
+		stream.emit = func(printableValues PrintableValues) {
+			printMinute(printableValues, stream.currentMinuteData)
+		}
+		window = &stream.window
+
+		eventsStatistics, err = readSortedEvents(ctx, sortedFile, stream)
+	} else {
+		// with --replay-speed each minute waits for its time, relative to the first minute
+		var pacer = newReplayPacer(options, firstMinute)
+
+		// iterating from the first minute a delivery occurred to the last minute a delivery ocurred
+		// using time.Time to progress in time
+		// the map is only accessed by key and never iterated, so the order of the output doesn't depend on the map order
+		for currentMinute := firstMinute; !currentMinute.After(lastMinute); currentMinute = currentMinute.Add(options.bucket) {
+			pacer.wait(ctx, currentMinute)
+
+			// stop calculating if the program was interrupted
+			if ctx.Err() != nil {
+				break
+			}
+
+			// getting the duration of the deliveries for this minute in time
+			// need to convert to string to use as a key in the map
+			var currentMinuteData = translationsDeliveriesData[currentMinute.Format("2006-01-02 15:04:05")]
+
+			// calculate the values of the minute, some minutes are calculated but not printed
+			if printableValues, printable := window.next(currentMinute, currentMinuteData); printable {
+				printMinute(printableValues, currentMinuteData)
+			}
+		}
+	}
+
+	// the last minute is always handled, even if it is not in the stride of --output-every
+	if skippedMinute != nil && err == nil && ctx.Err() == nil {
+		handleMinute(*skippedMinute, skippedMinuteData)
+	}
+
+	// second pass over the buffered series, only used when normalizing
+	if options.normalize {
+		normalizeAverages(series)
+
+		for _, printableValues := range series {
+			printValues(printableValues)
+		}
+	}
+
+	if err := finishOutput(); err != nil {
+		return err
+	}
+
+	// the minutes calculated before an unsorted event are written, but it is still an error
+	if err != nil {
+		return err
+	}
+
+	if options.report == "top-slow" {
+		printTopSlowReport(stderr, reportSeries, options.reportSize)
+	}
+	if options.report == "gaps" && !firstDataMinute.IsZero() {
+		printGapsReport(stderr, findGaps(translationsDeliveriesData, firstDataMinute, lastDataMinute, options.bucket))
+	}
+
+	if options.topNClients > 0 {
+		printTopClients(stderr, topClients(eventsStatistics.clientDeliveries, options.topNClients), eventsStatistics.Total_events)
+	}
+
+	if options.sla > 0 && window.printedMinutes > 0 {
+		fmt.Fprintf(stderr, "SLA compliance: %.2f%% of %d minutes\n", float64(window.minutesWithinSla)*100/float64(window.printedMinutes), window.printedMinutes)
+	}
+
+	if options.statsFilePath != "" {
+		// statistics of the run, with the peak minute found while calculating the averages
+		var runStatistics = RunStatistics{
+			EventsStatistics:           eventsStatistics,
+			Peak_minute:                window.peakMinute,
+			Peak_average_delivery_time: window.peakAverage,
+			Input_file:                 options.inputFilePath,
+			Window_size:                options.windowSize,
+		}
+
+		if err := writeRunStatistics(options.statsFilePath, runStatistics); err != nil {
+			return err
+		}
+	}
+
+	// the output is complete, but the exit code tells the empty windows to the monitoring
+	if options.failOnEmptyWindow && window.emptyWindows > 0 && ctx.Err() == nil {
+		return fmt.Errorf("%d minutes have an empty window, the first is %s", window.emptyWindows, window.firstEmptyWindow)
+	}
+
+	return ctx.Err()
+}
+
+// struct with the state of the moving window calculations of a series
+// the minutes are added in order, one at a time, so it works both for files and streams of events
+type movingWindow struct {
+	options options
+
+	// this array will work as a FIFO/Queue to store the values of the moving window
+	movingAverageQueue []int
+
+	// same as the above, but with the number of deliveries of each minute
+	deliveriesCountQueue []int
+
+	// same as the first one, but with the size of --stats-window, and the aggregator of its percentile
+	statsQueue      []int
+	statsAggregator Aggregator
+
+	// function that aggregates the values of the window (--agg)
+	aggregator Aggregator
+
+	// number of minutes calculated so far
+	calculatedMinutes int
+
+	// average of the previous minute, used to calculate the delta with the --diff flag
+	previousAverage float64
+
+	// average of the last printed minute, used to find the changes with the --emit-on-change flag
+	lastPrintedAverage float64
+
+	// number of printed minutes and how many of them were within the SLA, used for the compliance percentage
+	printedMinutes, minutesWithinSla int
+
+	// average of the last window with deliveries, printed in the empty windows with --gap-value=ffill
+	lastFilledAverage float64
+
+	// the first minute with the highest average, used in the statistics of the run
+	peakMinute  string
+	peakAverage float64
+
+	// printed minutes with an empty window between deliveries, and the first of them, used with --fail-on-empty-window
+	// the empty windows after a delivery are pending until the next delivery, so the padding at the end is not counted
+	deliveriesSeen                    bool
+	emptyWindows, pendingEmptyWindows int
+	firstEmptyWindow, firstPending    string
+}
+
+// function to add the next minute to the window and calculate its values
+// returns false as the second value if the minute must not be printed
+func (window *movingWindow) next(currentMinute time.Time, currentMinuteData MinuteDeliveries) (PrintableValues, bool) {
+	var options = window.options
+	var currentAverage float64
+
+	// a delivery confirms the empty windows since the previous one
+	if currentMinuteData.Count > 0 {
+		if window.emptyWindows == 0 && window.pendingEmptyWindows > 0 {
+			window.firstEmptyWindow = window.firstPending
+		}
+		window.emptyWindows += window.pendingEmptyWindows
+		window.pendingEmptyWindows = 0
+		window.deliveriesSeen = true
+	}
+
+	// update the elements in the queues
+	// if we don't have data for the current minute in the map, it defaults to 0
+	// the window size is in minutes, but the queues have one element per bucket
+	var windowBuckets = uint(time.Duration(options.windowSize) * time.Minute / options.bucket)
+
+	// the oldest minute leaves the window when it is full, it is removed from the sliding aggregators
+	var removed int
+	var hasRemoved = windowBuckets > 0 && uint(len(window.movingAverageQueue)) >= windowBuckets
+	if hasRemoved {
+		removed = window.movingAverageQueue[0]
+	}
+
+	window.movingAverageQueue = updateMovingWindowQueue(window.movingAverageQueue, windowBuckets, currentMinuteData.Duration)
+	window.deliveriesCountQueue = updateMovingWindowQueue(window.deliveriesCountQueue, windowBuckets, currentMinuteData.Count)
+
+	// calculating the moving average
+	// the aggregator is created with the first minute and reused for the next ones
+	// the sliding aggregators are updated with the minutes that enter and leave the window, the others aggregate the whole window
+	if window.aggregator == nil {
+		window.aggregator = newAggregator(options)
+	}
+	// with --average-mode=per-delivery the mean is of the deliveries of the window instead of its minutes
+	if options.averageMode == "per-delivery" {
+		currentAverage = deliveriesMean(window.movingAverageQueue, window.deliveriesCountQueue)
+	} else if sliding, ok := window.aggregator.(SlidingAggregator); ok && windowBuckets > 0 {
+		currentAverage = slideWindow(sliding, currentMinuteData.Duration, removed, hasRemoved)
+	} else {
+		currentAverage = aggregateWindow(window.aggregator, window.movingAverageQueue)
+	}
+
+	// with --gap-value=ffill the empty windows repeat the average of the last window with deliveries, 0 before the first one
+	if options.fillForward {
+		if sumQueue(window.deliveriesCountQueue) > 0 {
+			window.lastFilledAverage = currentAverage
+		} else {
+			currentAverage = window.lastFilledAverage
+		}
+	}
+
+	// create the object with the data to print
+	printableValues := PrintableValues{
+		Date:                  currentMinute.Format("2006-01-02 15:04:05"),
+		Average_delivery_time: currentAverage,
+	}
+
+	// the window ends in the current minute and has one element in the queue per bucket
+	if options.withWindowSpan {
+		printableValues.Window_start = currentMinute.Add(-time.Duration(len(window.movingAverageQueue)-1) * options.bucket).Format("2006-01-02 15:04:05")
+		printableValues.Window_end = printableValues.Date
+	}
+
+	// the throughput is calculated from the counts of the window, in parallel with the average
+	if options.withThroughput {
+		throughput := float64(sumQueue(window.deliveriesCountQueue)) / (float64(len(window.deliveriesCountQueue)) * options.bucket.Minutes())
+		printableValues.Throughput = &throughput
+	}
+
+	// the percentile of --stats-window has its own queue, of a different size than the window of the average
+	if options.statsWindow > 0 {
+		var statsBuckets = uint(time.Duration(options.statsWindow) * time.Minute / options.bucket)
+		window.statsQueue = updateMovingWindowQueue(window.statsQueue, statsBuckets, currentMinuteData.Duration)
+
+		if window.statsAggregator == nil {
+			window.statsAggregator = aggregators["percentile"](options)
+		}
+		percentile := aggregateWindow(window.statsAggregator, window.statsQueue)
+		printableValues.Percentile = &percentile
+	}
+
+	// the peak is the first minute with the highest average
+	if window.calculatedMinutes == 0 || currentAverage > window.peakAverage {
+		window.peakMinute = printableValues.Date
+		window.peakAverage = currentAverage
+	}
+
+	// the first minute has no previous minute, so its delta is 0
+	if options.diff || options.delta {
+		if window.calculatedMinutes == 0 {
+			window.previousAverage = currentAverage
+		}
+
+		delta := currentAverage - window.previousAverage
+		if options.diff {
+			printableValues.Delta_prev = &delta
+		}
+		if options.delta {
+			printableValues.Delta = &delta
+		}
+		window.previousAverage = currentAverage
+	}
+
+	window.calculatedMinutes++
+
+	// the first minute is the padding before the first delivery, it is not printed with --drop-first
+	if options.dropFirst && window.calculatedMinutes == 1 {
+		return printableValues, false
+	}
+
+	// the warm-up minutes before the window is full are not printed
+	if options.fullWindowOnly && uint(len(window.movingAverageQueue)) < windowBuckets {
+		return printableValues, false
+	}
+
+	// the minutes outside the business hours are not printed, by the start of their bucket
+	if options.businessHours != nil && !options.businessHours.contains(currentMinute.Add(-labelOffset(options))) {
+		return printableValues, false
+	}
+
+	// only the minutes at the top of the interval of --sample-at are printed
+	if options.sampleAt > 0 && !currentMinute.Truncate(options.sampleAt).Equal(currentMinute) {
+		return printableValues, false
+	}
+
+	// windows with few deliveries are not printed
+	if options.minDeliveries > 0 && sumQueue(window.deliveriesCountQueue) < options.minDeliveries {
+		return printableValues, false
+	}
+
+	// the minutes that don't change the average of the last printed minute are not printed
+	if options.emitOnChange {
+		if window.printedMinutes > 0 && math.Abs(currentAverage-window.lastPrintedAverage) <= options.changeEps {
+			return printableValues, false
+		}
+		window.lastPrintedAverage = currentAverage
+	}
+
+	if options.sla > 0 {
+		withinSla := currentAverage <= options.sla
+		printableValues.Within_sla = &withinSla
+
+		if withinSla {
+			window.minutesWithinSla++
+		}
+	}
+	window.printedMinutes++
+
+	// the windows before the first delivery are the padding at the beginning, they are not counted
+	if window.deliveriesSeen && sumQueue(window.deliveriesCountQueue) == 0 {
+		if window.pendingEmptyWindows == 0 {
+			window.firstPending = printableValues.Date
+		}
+		window.pendingEmptyWindows++
+	}
+
+	return printableValues, true
+}
+
+// intervals of the --sample-at flag, by their name
+var sampleIntervals = map[string]time.Duration{"hour": time.Hour, "day": 24 * time.Hour}
+
+// struct with the statistics of the run written with the --stats-json flag
+// has the statistics of the events, the minute with the highest average and the parameters used
+type RunStatistics struct {
+	EventsStatistics
+	Peak_minute                string  `json:"peak_minute"`
+	Peak_average_delivery_time float64 `json:"peak_average_delivery_time"`
+	Input_file                 string  `json:"input_file"`
+	Window_size                uint    `json:"window_size"`
+}
+
+// function to write the statistics of the run to a file as an indented JSON document
+func writeRunStatistics(statsFilePath string, runStatistics RunStatistics) error {
+	document, err := json.MarshalIndent(runStatistics, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(statsFilePath, append(document, '\n'), 0644)
+}
+
+// function to scale the averages of the series to the 0-1 range
+// the minimum average of the series maps to 0 and the maximum to 1
+func normalizeAverages(series []PrintableValues) {
+	if len(series) == 0 {
+		return
+	}
+
+	// find the minimum and maximum averages of the whole series
+	minimum, maximum := series[0].Average_delivery_time, series[0].Average_delivery_time
+	for _, printableValues := range series {
+		minimum = math.Min(minimum, printableValues.Average_delivery_time)
+		maximum = math.Max(maximum, printableValues.Average_delivery_time)
+	}
+
+	for i := range series {
+		var normalized float64
+
+		// guarding against a division by zero when all the averages are equal
+		// in that case every minute is normalized to 0
+		if maximum > minimum {
+			normalized = (series[i].Average_delivery_time - minimum) / (maximum - minimum)
+		}
+
+		series[i].Normalized = &normalized
+	}
+}
+
+// function to update the moving average queue
+// encapsulates the logic to add and remove elements to/from the queue
+func updateMovingWindowQueue(movingAverageQueue []int, windowSize uint, currentMinuteData int) []int {
+	// add the current minute data to the FIFO
+	movingAverageQueue = append(movingAverageQueue, currentMinuteData)
+
+	// if the FIFO has more elements than the "windowSize" we remove the first element
+	if int64(len(movingAverageQueue)) > int64(windowSize) {
+		movingAverageQueue = movingAverageQueue[1:]
+	}
+
+	return movingAverageQueue
+}
+
+// function to round a minute down to a multiple of the window size since the Unix epoch
+func roundDownToWindow(minute time.Time, windowSize uint) time.Time {
+	if windowSize == 0 {
+		return minute
+	}
+
+	window := int64(windowSize) * int64(time.Minute/time.Second)
+	seconds := minute.Unix()
+
+	// rounding down also for the minutes before the epoch
+	remainder := seconds % window
+	if remainder < 0 {
+		remainder += window
+	}
+
+	return time.Unix(seconds-remainder, 0).In(minute.Location())
+}
+
+// function to sum the values of a queue
+func sumQueue(queue []int) int {
+	var sum int
+	for _, value := range queue {
+		sum += value
+	}
+	return sum
+}
+
+// type of the --input-field-map flag
+// maps the name of a field in the DeliveredTranslation struct to the JSON key it is read from
+type fieldMap map[string]string
+
+// names of the fields that can be mapped with the --input-field-map flag
+var mappableFields = []string{"timestamp", "duration"}
+
+func (fieldMap fieldMap) String() string {
+	var pairs []string
+	for _, field := range mappableFields {
+		if name, ok := fieldMap[field]; ok {
+			pairs = append(pairs, field+"="+name)
+		}
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (fieldMap fieldMap) Set(value string) error {
+	for _, pair := range strings.Split(value, ",") {
+		field, name, found := strings.Cut(pair, "=")
+		if !found || name == "" || !slices.Contains(mappableFields, field) {
+			return fmt.Errorf("invalid field mapping %q, expected field=name with field one of %v", pair, mappableFields)
+		}
+		fieldMap[field] = name
+	}
+	return nil
+}
+
+// function to check if a key is the name a field is read from
+func (fieldMap fieldMap) isMappedName(key string) bool {
+	for _, name := range fieldMap {
+		if name == key {
+			return true
+		}
+	}
+	return false
+}
+
+// function to parse one line of the file into a DeliveredTranslation struct
+// if there is a field map, the line is read into a generic map first
+// and the mapped keys are renamed to the names of the struct before parsing it
+func parseDeliveredTranslation(line []byte, fieldMap fieldMap) (DeliveredTranslation, error) {
+	var deliveredTranslation DeliveredTranslation
+
+	line, err := mapFields(line, fieldMap)
+	if err != nil {
+		return deliveredTranslation, err
+	}
+
+	// the duration is also read into a pointer, to know if it is absent or an explicit 0
+	var event struct {
+		DeliveredTranslation
+		Duration *DeliveryDuration `json:"duration"`
+	}
+	if err = json.Unmarshal(line, &event); err != nil {
+		return deliveredTranslation, err
+	}
+
+	deliveredTranslation = event.DeliveredTranslation
+	if event.Duration != nil {
+		deliveredTranslation.Duration = *event.Duration
+	} else {
+		deliveredTranslation.durationMissing = true
+	}
+
+	// the durations in an unknown unit can't be converted
+	if _, ok := durationUnits[deliveredTranslation.Duration_unit]; deliveredTranslation.Duration_unit != "" && !ok {
+		return deliveredTranslation, fmt.Errorf("invalid duration unit %q, expected ms, s or m", deliveredTranslation.Duration_unit)
+	}
+
+	return deliveredTranslation, nil
+}
+
+// function to rename the mapped keys of a line to the names of the struct
+// the line is returned as it is if there is no field map
+func mapFields(line []byte, fieldMap fieldMap) ([]byte, error) {
+	if len(fieldMap) == 0 {
+		return line, nil
+	}
+
+	var rawFields map[string]json.RawMessage
+	if err := json.Unmarshal(line, &rawFields); err != nil {
+		return nil, err
+	}
+
+	// the mapped keys are moved to the names of the struct, the other keys are kept
+	var mappedFields = make(map[string]json.RawMessage, len(rawFields))
+	for key, value := range rawFields {
+		if !fieldMap.isMappedName(key) {
+			mappedFields[key] = value
+		}
+	}
+
+	// a key with the default name is replaced by the mapped one, or removed if the mapped one is missing
+	for field, name := range fieldMap {
+		value, ok := rawFields[name]
+		delete(mappedFields, field)
+		if ok {
+			mappedFields[field] = value
+		}
+	}
+
+	return json.Marshal(mappedFields)
+}
+
+// layouts of the timestamps of the events, tried in order
+// the fractional seconds are accepted by the first layout, the second is a fallback for timestamps without seconds
+var timestampLayouts = []string{"2006-01-02 15:04:05", "2006-01-02 15:04", time.RFC3339Nano}
+
+// function to parse the timestamp of an event
+// the timestamps in RFC 3339 are converted to UTC, like the ones without an offset
+// returns the error of the first layout if none of them match
+func parseTimestamp(timestamp string) (time.Time, error) {
+	var firstError error
+
+	for _, layout := range timestampLayouts {
+		parsed, err := time.Parse(layout, timestamp)
+		if err == nil {
+			return parsed.UTC(), nil
+		}
+
+		if firstError == nil {
+			firstError = err
+		}
+	}
+
+	return time.Time{}, firstError
+}
+
+// function to parse the separator of the --line-separator flag, with the escapes of Go strings like \t
+func parseLineSeparator(value string, separator *string) error {
+	unquoted, err := strconv.Unquote(`"` + value + `"`)
+	if err != nil || unquoted == "" {
+		return fmt.Errorf("invalid line separator %q, expected a non empty string", value)
+	}
+
+	*separator = unquoted
+	return nil
+}
+
+// function to parse the time of the --now flag, in RFC 3339 like "2018-12-26T18:20:00Z" or in the format of the timestamps
+// the times with an offset are converted to UTC, like the timestamps of the events
+func parseNow(value string) (time.Time, error) {
+	if now, err := time.Parse(time.RFC3339, value); err == nil {
+		return now.UTC(), nil
+	}
+
+	return parseTimestamp(value)
+}
+
+// function to parse a value of --range-start or --range-end
+// the value is a timestamp, "now", or a negative duration relative to now like "-1h"
+// an empty value is the zero time, which doesn't set the range
+func parseRangeValue(value string, clock func() time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if value == "now" {
+		return clock().UTC(), nil
+	}
+	if strings.HasPrefix(value, "-") {
+		if duration, err := time.ParseDuration(value); err == nil {
+			return clock().UTC().Add(duration), nil
+		}
+	}
+
+	return parseTimestamp(value)
+}
+
+// function to get the minute of an event from its time
+// truncating it to the bucket (one minute by default) - all the deliveries of the same bucket are grouped together
+// the buckets start at whole clock minutes, or at the same second as the origin if it is set (--align=data)
+// adding the label offset to the event - to make it coherent with the example
+func eventMinute(eventTime time.Time, origin time.Time, options options) time.Time {
+	var bucket = options.bucket
+
+	if origin.IsZero() {
+		return eventTime.Truncate(bucket).Add(labelOffset(options))
+	}
+
+	// the number of whole buckets since the origin, rounded down for the events before it
+	buckets := eventTime.Sub(origin) / bucket
+	if eventTime.Before(origin.Add(buckets * bucket)) {
+		buckets--
+	}
+
+	return origin.Add(buckets * bucket).Add(labelOffset(options))
+}
+
+// function to get the offset from the start of a bucket to the minute it is labeled with
+// the example of the challenge rounds the events up, each bucket is labeled with its end
+// so an event at 18:11:08 is in the minute 18:12:00, and the output starts one bucket before the first event with an average of 0
+// with --round-timestamps-down each bucket is labeled with its start
+// so an event at 18:11:08 is in the minute 18:11:00, and the output starts at the bucket of the first event
+// the first minute of the output is the minute of the first event minus this offset, in both conventions
+func labelOffset(options options) time.Duration {
+	if options.roundTimestampsDown {
+		return 0
+	}
+	return options.bucket
+}
+
+// struct with statistics about the events read from the file
+// Total_events: number of events read and used in the calculations
+// Skipped_lines: number of lines that couldn't be parsed and were ignored
+// Duplicate_events: number of events dropped by the --dedup-window flag
+// Missing_durations: number of events used in the calculations without a duration, counted as a duration of 0
+// Clamped_durations: number of events used in the calculations with a duration clamped to --max-duration
+// Min_duration, Max_duration, Mean_duration: statistics about the duration of the events
+type EventsStatistics struct {
+	Total_events      int     `json:"total_events"`
+	Skipped_lines     int     `json:"skipped_lines"`
+	Duplicate_events  int     `json:"duplicate_events"`
+	Missing_durations int     `json:"missing_durations"`
+	Clamped_durations int     `json:"clamped_durations"`
+	Min_duration      int     `json:"min_duration"`
+	Max_duration      int     `json:"max_duration"`
+	Mean_duration     float64 `json:"mean_duration"`
+
+	// number of deliveries of each client, not part of the statistics file
+	clientDeliveries map[string]int
+
+	// sum of the durations of the events, used to calculate the mean
+	sumDurations int
+}
+
+// function to update the statistics with an event
+func (statistics *EventsStatistics) add(duration int, clientName string) {
+	if statistics.Total_events == 0 || duration < statistics.Min_duration {
+		statistics.Min_duration = duration
+	}
+	if statistics.Total_events == 0 || duration > statistics.Max_duration {
+		statistics.Max_duration = duration
+	}
+	statistics.sumDurations += duration
+	statistics.Total_events++
+	statistics.Mean_duration = float64(statistics.sumDurations) / float64(statistics.Total_events)
+	statistics.clientDeliveries[clientName]++
+}
+
+// function
+// a map that for which minute in which translations were delivered has the sum of the duration of the deliveries
+// the first minute a translation delivery occurred
+// the last minute a translation delivery occurred
+// statistics about the events in the file
+func readTranslationsFileAndProcessData(filePath string, options options) (map[string]MinuteDeliveries, time.Time, time.Time, EventsStatistics, error) {
+
+	// open the file using the path received in the command line flag
+	file, err := os.Open(filePath)
+
+	// exit with error if unable to open the file
+	if err != nil {
+		return nil, time.Time{}, time.Time{}, EventsStatistics{}, err
+	}
+
+	// defer the close of the file at the return of this function
+	defer file.Close()
+
+	return readTranslationsAndProcessData(file, options)
+}
+
+// function to read the events of a reader and return the same values as readTranslationsFileAndProcessData
+// the reader can be the concatenation of several sources, see ConcatReaders
+func readTranslationsAndProcessData(reader io.Reader, options options) (map[string]MinuteDeliveries, time.Time, time.Time, EventsStatistics, error) {
+	var statistics = EventsStatistics{clientDeliveries: make(map[string]int)}
+	var series = newSeriesMinutes()
+
+	// read the valid events of the file, the events of the same minute in a deterministic order
+	err := readEvents(context.Background(), reader, options, &statistics, func(event inputEvent) error {
+		duration := series.add(event, options)
+
+		// update the statistics of the events
+		statistics.add(duration, event.deliveredTranslation.Client_name)
+		return nil
+	})
+	if err != nil {
+		return nil, time.Time{}, time.Time{}, statistics, err
+	}
 
 	// return the values
-	return numberTranslationsPerMinuteUTC, firstMinute, lastMinute
+	return series.numberTranslationsPerMinuteUTC, series.firstMinute, series.lastMinute, statistics, nil
+}
+
+// struct with the deliveries of the minutes of a series, added as the events are read
+// firstMinute is the minute before the first minute with deliveries, where the output starts, and lastMinute the last one with deliveries
+type seriesMinutes struct {
+	numberTranslationsPerMinuteUTC map[string]MinuteDeliveries
+	firstMinute, lastMinute        time.Time
+
+	// time of the first event, the origin of the minutes with --align=data
+	origin time.Time
+}
+
+// function to create the minutes of a series without deliveries
+func newSeriesMinutes() *seriesMinutes {
+	return &seriesMinutes{numberTranslationsPerMinuteUTC: make(map[string]MinuteDeliveries)}
+}
+
+// function to add the delivery of an event to its minute, returns the duration of the delivery
+func (series *seriesMinutes) add(event inputEvent, options options) int {
+	if options.align == "data" && series.origin.IsZero() {
+		series.origin = event.eventTime
+	}
+
+	// parsing the string timestamp to the minute of the event
+	// converting it back to a string - to have simpler keys in the map
+	currentMinute := eventMinute(event.eventTime, series.origin, options)
+	minuteKey := currentMinute.Format("2006-01-02 15:04:05")
+
+	// for each minute we had a delivery we calculate how long the deliveries for that minute took and how many there were
+	// and store them in a map whose key is the truncated timestamp - just the minute
+	duration := int(event.deliveredTranslation.Duration)
+	minuteDeliveries := series.numberTranslationsPerMinuteUTC[minuteKey]
+	minuteDeliveries.add(duration, options.explode)
+	series.numberTranslationsPerMinuteUTC[minuteKey] = minuteDeliveries
+
+	// since the information is stored in a map and not ordered
+	// as the file is read the minute of the first event is stored
+	if series.firstMinute.IsZero() {
+		series.firstMinute = currentMinute.Add(-labelOffset(options))
+	}
+
+	// the last minute when a delivery ocurred is also stored
+	series.lastMinute = currentMinute
+
+	return duration
 }
@@ -1,10 +1,17 @@
 package main
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
@@ -12,7 +19,7 @@ import (
 
 func Test_main_TemplateFile(t *testing.T) {
 
-	data := getContentFromConsole()
+	data := getContentFromConsole(t, "--input_file=./events-template.json", "--window_size=10")
 
 	var expectedAverageDurationFirstEntry = 0.0
 	var expectedAverageDurationLastEntry = 100.0
@@ -43,36 +50,1291 @@ func Test_main_TemplateFile(t *testing.T) {
 	}
 }
 
-func getContentFromConsole() []PrintableValues {
+func getContentFromConsole(t testing.TB, arguments ...string) []PrintableValues {
+	t.Helper()
 
-	os.Args = append(os.Args, "--input_file=./events-template.json")
-	os.Args = append(os.Args, "--window_size=10")
+	var console bytes.Buffer
 
-	getStdout := os.Stdout
-	r, w, _ := os.Pipe()
-	os.Stdout = w
+	if err := run(context.Background(), arguments, &console, io.Discard); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	return parseConsoleContent(t, console.Bytes())
+}
 
-	main()
+// function to parse the output of the program, a JSON object in each line
+// the output ends with a line break, so the last element after splitting it is empty
+func parseConsoleContent(t testing.TB, consoleContentRaw []byte) []PrintableValues {
+	t.Helper()
 
-	w.Close()
-	consoleContentRaw, _ := io.ReadAll(r)
-	os.Stdout = getStdout
+	lines := strings.Split(string(consoleContentRaw), "\n")
+	if lines[len(lines)-1] != "" {
+		t.Fatalf("Expected the output to end with a line break, got %q", consoleContentRaw)
+	}
 
 	var deliveredTranslation []PrintableValues
-	// the content we get from the console is in byte and is a series of json objects, not an array of json objects
-	// 1 - convert it to a string
-	// 2 - replace the line breaks with commas, thus separating the objects
-	// 3 - trim the last comma since it makes the array not valid
-	// 4 - encapsulate the entire string in brackets making it a valid array of PrintableValues objects
-	consoleContent := strings.ReplaceAll(string(consoleContentRaw), "\n", ",")
-	consoleContent = "[" + strings.TrimSuffix(consoleContent, ",") + "]"
+	for i, line := range lines[:len(lines)-1] {
+		var values PrintableValues
+		if err := json.Unmarshal([]byte(line), &values); err != nil {
+			t.Fatalf("Expected a JSON object in the line %d of the output, got %q: %v", i+1, line, err)
+		}
+		deliveredTranslation = append(deliveredTranslation, values)
+	}
+
+	return deliveredTranslation
+}
+
+func Test_normalizeAverages(t *testing.T) {
+
+	series := []PrintableValues{
+		{Date: "2018-12-26 18:11:00", Average_delivery_time: 20},
+		{Date: "2018-12-26 18:12:00", Average_delivery_time: 40},
+		{Date: "2018-12-26 18:13:00", Average_delivery_time: 30},
+	}
+
+	normalizeAverages(series)
+
+	var expectedNormalized = []float64{0, 1, 0.5}
+
+	for i, printableValues := range series {
+		if printableValues.Normalized == nil || *printableValues.Normalized != expectedNormalized[i] {
+			t.Errorf("Expected normalized value for minute %d to be %f, got %v", i, expectedNormalized[i], printableValues.Normalized)
+		}
+	}
+}
+
+func Test_normalizeAverages_AllEqual(t *testing.T) {
+
+	series := []PrintableValues{
+		{Date: "2018-12-26 18:11:00", Average_delivery_time: 25},
+		{Date: "2018-12-26 18:12:00", Average_delivery_time: 25},
+	}
+
+	normalizeAverages(series)
+
+	for i, printableValues := range series {
+		if printableValues.Normalized == nil || *printableValues.Normalized != 0 {
+			t.Errorf("Expected normalized value for minute %d to be 0, got %v", i, printableValues.Normalized)
+		}
+	}
+}
+
+func Test_main_GzipOutput(t *testing.T) {
+
+	outputFilePath := filepath.Join(t.TempDir(), "output.json.gz")
 
-	// with the above treatment, the
-	err := json.Unmarshal([]byte(consoleContent), &deliveredTranslation)
+	if err := run(context.Background(), []string{"--input_file=./events-template.json", "--output_file=" + outputFilePath}, io.Discard, io.Discard); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
 
+	file, err := os.Open(outputFilePath)
 	if err != nil {
-		fmt.Println(err)
+		t.Fatalf("Expected output file to exist, got %v", err)
 	}
+	defer file.Close()
 
-	return deliveredTranslation
+	gzipReader, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatalf("Expected a valid gzip file, got %v", err)
+	}
+
+	content, err := io.ReadAll(gzipReader)
+	if err != nil {
+		t.Fatalf("Expected to read the whole gzip file, got %v", err)
+	}
+
+	expected := getContentFromConsole(t, "--input_file=./events-template.json")
+	data := parseConsoleContent(t, content)
+
+	if len(data) != len(expected) {
+		t.Fatalf("Expected %d minutes in the gzipped output, got %d", len(expected), len(data))
+	}
+
+	for i := range data {
+		if data[i] != expected[i] {
+			t.Errorf("Expected minute %d to be %v, got %v", i, expected[i], data[i])
+		}
+	}
+
+	// with --gzip-output the file is compressed without the extension, the same bytes as the file with it
+	compressed, err := os.ReadFile(outputFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gzipFlagPath := filepath.Join(t.TempDir(), "output.json")
+	if err := run(context.Background(), []string{"--input_file=./events-template.json", "--output_file=" + gzipFlagPath, "--gzip-output"}, io.Discard, io.Discard); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if content, err := os.ReadFile(gzipFlagPath); err != nil || !bytes.Equal(content, compressed) {
+		t.Errorf("Expected the same compressed output with --gzip-output, got %d bytes and %v", len(content), err)
+	}
+
+	// the output is closed when the run fails after the first minutes, so the file is still a complete gzip stream
+	events, err := os.ReadFile("./events-template.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.SplitAfter(string(events), "\n")
+	unsortedPath := filepath.Join(t.TempDir(), "unsorted.json")
+	if err := os.WriteFile(unsortedPath, []byte(lines[1]+lines[0]), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := run(context.Background(), []string{"--input_file=" + unsortedPath, "--output_file=" + outputFilePath, "--assume-sorted"}, io.Discard, io.Discard); err == nil {
+		t.Fatal("Expected an error with an unsorted input")
+	}
+	file, err = os.Open(outputFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if gzipReader, err = gzip.NewReader(file); err != nil {
+		t.Fatalf("Expected a valid gzip file after the error, got %v", err)
+	}
+	if _, err := io.ReadAll(gzipReader); err != nil {
+		t.Errorf("Expected to read the whole gzip file after the error, got %v", err)
+	}
+}
+
+func Test_main_Diff(t *testing.T) {
+
+	data := getContentFromConsole(t, "--input_file=./events-template.json", "--diff")
+
+	for i := range data {
+		var expectedDelta = 0.0
+		if i > 0 {
+			expectedDelta = data[i].Average_delivery_time - data[i-1].Average_delivery_time
+		}
+
+		if data[i].Delta_prev == nil || *data[i].Delta_prev != expectedDelta {
+			t.Errorf("Expected delta for minute %d to be %f, got %v", i, expectedDelta, data[i].Delta_prev)
+		}
+	}
+
+	// the template file has a delivery of 31 that moves the average from 20 to 25.5 at 18:16
+	if *data[5].Delta_prev != 5.5 {
+		t.Errorf("Expected delta for minute 18:16 to be 5.5, got %f", *data[5].Delta_prev)
+	}
+}
+
+// writer that cancels a context on the first write it receives
+// used to interrupt the program in the middle of the calculations
+type cancelOnWriteWriter struct {
+	bytes.Buffer
+	cancel context.CancelFunc
+}
+
+func (w *cancelOnWriteWriter) Write(p []byte) (int, error) {
+	w.cancel()
+	return w.Buffer.Write(p)
+}
+
+func Test_main_Interrupted(t *testing.T) {
+
+	// two deliveries ten days apart produce enough minutes to fill the output buffer more than once
+	inputFilePath := filepath.Join(t.TempDir(), "events.json")
+	events := `{"timestamp": "2018-12-16 18:11:08.509654","duration": 20}
+{"timestamp": "2018-12-26 18:11:08.509654","duration": 30}
+`
+	if err := os.WriteFile(inputFilePath, []byte(events), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	console := &cancelOnWriteWriter{cancel: cancel}
+
+	err := run(ctx, []string{"--input_file=" + inputFilePath}, console, io.Discard)
+
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected the run to be canceled, got %v", err)
+	}
+
+	data := parseConsoleContent(t, console.Bytes())
+	expected := getContentFromConsole(t, "--input_file="+inputFilePath)
+
+	if len(data) == 0 || len(data) >= len(expected) {
+		t.Fatalf("Expected a partial output, got %d of %d minutes", len(data), len(expected))
+	}
+
+	for i := range data {
+		if data[i] != expected[i] {
+			t.Errorf("Expected minute %d to be %v, got %v", i, expected[i], data[i])
+		}
+	}
+}
+
+func Test_main_Deterministic(t *testing.T) {
+
+	arguments := []string{"--input_file=./events-template.json", "--normalize", "--diff"}
+
+	var firstRun, secondRun bytes.Buffer
+
+	if err := run(context.Background(), arguments, &firstRun, io.Discard); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if err := run(context.Background(), arguments, &secondRun, io.Discard); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if !bytes.Equal(firstRun.Bytes(), secondRun.Bytes()) {
+		t.Errorf("Expected both runs to produce the same output, got:\n%s\nand:\n%s", firstRun.String(), secondRun.String())
+	}
+}
+
+func Test_main_StatsJson(t *testing.T) {
+
+	statsFilePath := filepath.Join(t.TempDir(), "stats.json")
+
+	getContentFromConsole(t, "--input_file=./events-template.json", "--stats-json="+statsFilePath)
+
+	content, err := os.ReadFile(statsFilePath)
+	if err != nil {
+		t.Fatalf("Expected the stats file to exist, got %v", err)
+	}
+
+	var statistics RunStatistics
+	if err := json.Unmarshal(content, &statistics); err != nil {
+		t.Fatalf("Expected the stats file to be valid JSON, got %v", err)
+	}
+
+	expected := RunStatistics{
+		EventsStatistics: EventsStatistics{
+			Total_events:  4,
+			Skipped_lines: 0,
+			Min_duration:  20,
+			Max_duration:  100,
+			Mean_duration: 51.25,
+		},
+		Peak_minute:                "2018-12-26 18:41:00",
+		Peak_average_delivery_time: 100,
+		Input_file:                 "./events-template.json",
+		Window_size:                10,
+	}
+
+	if !reflect.DeepEqual(statistics, expected) {
+		t.Errorf("Expected statistics %+v, got %+v", expected, statistics)
+	}
+}
+
+func Test_readTranslationsFileAndProcessData_SkippedLines(t *testing.T) {
+
+	inputFilePath := filepath.Join(t.TempDir(), "events.json")
+	events := `{"timestamp": "2018-12-26 18:11:08.509654","duration": 20}
+not an event
+{"timestamp": "yesterday","duration": 40}
+{"timestamp": "2018-12-26 18:15:19.903159","duration": 31}
+`
+	if err := os.WriteFile(inputFilePath, []byte(events), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	data, _, lastMinute, statistics, err := readTranslationsFileAndProcessData(inputFilePath, options{bucket: time.Minute})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if statistics.Total_events != 2 || statistics.Skipped_lines != 2 {
+		t.Errorf("Expected 2 events and 2 skipped lines, got %d and %d", statistics.Total_events, statistics.Skipped_lines)
+	}
+
+	if len(data) != 2 {
+		t.Errorf("Expected 2 minutes with deliveries, got %d", len(data))
+	}
+
+	if lastMinute.Format("2006-01-02 15:04:05") != "2018-12-26 18:16:00" {
+		t.Errorf("Expected the last minute to be 2018-12-26 18:16:00, got %s", lastMinute)
+	}
+}
+
+func Test_main_InputFieldMap(t *testing.T) {
+
+	inputFilePath := filepath.Join(t.TempDir(), "events.json")
+	events := `{"ts": "2018-12-26 18:11:08.509654","dur_ms": 20}
+{"ts": "2018-12-26 18:15:19.903159","dur_ms": 31}
+{"ts": "2018-12-26 18:23:19.903159","dur_ms": 54}
+`
+	if err := os.WriteFile(inputFilePath, []byte(events), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	data := getContentFromConsole(t, "--input_file="+inputFilePath, "--input-field-map=timestamp=ts,duration=dur_ms")
+	expected := getContentFromConsole(t, "--input_file=./events.json")
+
+	if len(data) != len(expected) {
+		t.Fatalf("Expected %d minutes, got %d", len(expected), len(data))
+	}
+
+	for i := range data {
+		if data[i] != expected[i] {
+			t.Errorf("Expected minute %d to be %v, got %v", i, expected[i], data[i])
+		}
+	}
+}
+
+func Test_parseDeliveredTranslation_FieldMap(t *testing.T) {
+
+	var mapping = fieldMap{}
+	if err := mapping.Set("timestamp=ts,duration=dur_ms"); err != nil {
+		t.Fatalf("Expected a valid mapping, got %v", err)
+	}
+
+	// the default names are ignored when the fields are mapped
+	deliveredTranslation, err := parseDeliveredTranslation([]byte(`{"timestamp": "ignored", "ts": "2018-12-26 18:11:08", "duration": 1}`), mapping)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if deliveredTranslation.Timestamp != "2018-12-26 18:11:08" || deliveredTranslation.Duration != 0 {
+		t.Errorf("Expected timestamp from ts and no duration, got %+v", deliveredTranslation)
+	}
+
+	if err := mapping.Set("client=name"); err == nil {
+		t.Errorf("Expected an error mapping an unknown field")
+	}
+}
+
+func Test_trimmedMeanAggregator(t *testing.T) {
+
+	// ten minutes with deliveries with an outlier at each end, the empty minutes are ignored
+	window := []int{1, 20, 0, 21, 19, 20, 0, 500, 20, 22, 18, 20}
+
+	if average := aggregateWindow(&trimmedMeanAggregator{trim: 0.1}, window); average != 20 {
+		t.Errorf("Expected the outliers to be excluded and the average to be 20, got %f", average)
+	}
+
+	if average := aggregateWindow(&trimmedMeanAggregator{}, window); average != aggregateWindow(&meanAggregator{}, window) {
+		t.Errorf("Expected no trim to be the moving average %f, got %f", aggregateWindow(&meanAggregator{}, window), average)
+	}
+
+	if average := aggregateWindow(&trimmedMeanAggregator{trim: 0.1}, []int{0, 0}); average != 0 {
+		t.Errorf("Expected an empty window to be 0, got %f", average)
+	}
+}
+
+func Test_main_InvalidTrim(t *testing.T) {
+
+	for _, trim := range []string{"-0.1", "0.5"} {
+		err := run(context.Background(), []string{"--input_file=./events-template.json", "--metric=trimmed-mean", "--trim=" + trim}, io.Discard, io.Discard)
+
+		if err == nil {
+			t.Errorf("Expected an error for trim %s", trim)
+		}
+	}
+}
+
+func Test_main_TimestampAndDurationFields(t *testing.T) {
+
+	inputFilePath := filepath.Join(t.TempDir(), "events.json")
+	events := `{"ts": "2018-12-26 18:11:08.509654","latency": 20}
+{"ts": "2018-12-26 18:15:19.903159","latency": 31}
+{"ts": "2018-12-26 18:23:19.903159","latency": 54}
+`
+	if err := os.WriteFile(inputFilePath, []byte(events), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	data := getContentFromConsole(t, "--input_file="+inputFilePath, "--timestamp-field=ts", "--duration-field=latency")
+	expected := getContentFromConsole(t, "--input_file=./events.json")
+
+	if len(data) != len(expected) {
+		t.Fatalf("Expected %d minutes, got %d", len(expected), len(data))
+	}
+
+	for i := range data {
+		if data[i] != expected[i] {
+			t.Errorf("Expected minute %d to be %v, got %v", i, expected[i], data[i])
+		}
+	}
+}
+
+func Test_main_EmitOnChange(t *testing.T) {
+
+	// one delivery of 20 every minute from 18:11 to 18:15, and one of 40 at 18:16
+	inputFilePath := filepath.Join(t.TempDir(), "events.json")
+	var events strings.Builder
+	for minute := 11; minute <= 15; minute++ {
+		fmt.Fprintf(&events, `{"timestamp": "2018-12-26 18:%d:08","duration": 20}`+"\n", minute)
+	}
+	events.WriteString(`{"timestamp": "2018-12-26 18:16:08","duration": 40}` + "\n")
+	if err := os.WriteFile(inputFilePath, []byte(events.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		arguments     []string
+		expectedDates []string
+	}{
+		// the flat series from 18:12 to 18:16 is only printed at its first minute
+		{[]string{"--emit-on-change"}, []string{"2018-12-26 18:11:00", "2018-12-26 18:12:00", "2018-12-26 18:17:00"}},
+		// the change from 0 to 20 is within the eps, the change from 0 to 40 is not
+		{[]string{"--emit-on-change", "--change-eps=25"}, []string{"2018-12-26 18:11:00", "2018-12-26 18:17:00"}},
+		// without the flag every minute is printed
+		{[]string{"--change-eps=25"}, []string{"2018-12-26 18:11:00", "2018-12-26 18:12:00", "2018-12-26 18:13:00", "2018-12-26 18:14:00", "2018-12-26 18:15:00", "2018-12-26 18:16:00", "2018-12-26 18:17:00"}},
+	}
+
+	for _, test := range tests {
+		data := getContentFromConsole(t, append(test.arguments, "--input_file="+inputFilePath, "--window_size=1")...)
+
+		var dates []string
+		for _, printableValues := range data {
+			dates = append(dates, printableValues.Date)
+		}
+		if !reflect.DeepEqual(dates, test.expectedDates) {
+			t.Errorf("Expected the minutes %v with %v, got %v", test.expectedDates, test.arguments, dates)
+		}
+	}
+
+	if err := run(context.Background(), []string{"--emit-on-change", "--change-eps=-1"}, io.Discard, io.Discard); err == nil {
+		t.Errorf("Expected an error with a negative --change-eps")
+	}
+}
+
+func Test_main_MinDeliveries(t *testing.T) {
+
+	all := getContentFromConsole(t, "--input_file=./events-template.json")
+	data := getContentFromConsole(t, "--input_file=./events-template.json", "--min-deliveries=2")
+
+	// with a 10 minute window, only the minutes from 18:16 to 18:21 have the deliveries of 18:12 and 18:16
+	// and the minutes from 18:24 to 18:25 have the deliveries of 18:16 and 18:24
+	var expectedDates = []string{
+		"2018-12-26 18:16:00", "2018-12-26 18:17:00", "2018-12-26 18:18:00",
+		"2018-12-26 18:19:00", "2018-12-26 18:20:00", "2018-12-26 18:21:00",
+		"2018-12-26 18:24:00", "2018-12-26 18:25:00",
+	}
+
+	if len(data) != len(expectedDates) {
+		t.Fatalf("Expected %d minutes with at least 2 deliveries, got %d", len(expectedDates), len(data))
+	}
+
+	for i := range data {
+		if data[i].Date != expectedDates[i] {
+			t.Errorf("Expected minute %d to be %s, got %s", i, expectedDates[i], data[i].Date)
+		}
+	}
+
+	// the values of the printed minutes are not changed
+	for _, printableValues := range all {
+		if printableValues.Date == data[0].Date && printableValues != data[0] {
+			t.Errorf("Expected %v, got %v", printableValues, data[0])
+		}
+	}
+}
+
+func Test_main_WithWindowSpan(t *testing.T) {
+
+	data := getContentFromConsole(t, "--input_file=./events-template.json", "--window_size=3", "--with-window-span")
+
+	// the window is partially filled in the first two minutes
+	var expectedSpans = [][2]string{
+		{"2018-12-26 18:11:00", "2018-12-26 18:11:00"},
+		{"2018-12-26 18:11:00", "2018-12-26 18:12:00"},
+		{"2018-12-26 18:11:00", "2018-12-26 18:13:00"},
+		{"2018-12-26 18:12:00", "2018-12-26 18:14:00"},
+	}
+
+	for i, expectedSpan := range expectedSpans {
+		if data[i].Window_start != expectedSpan[0] || data[i].Window_end != expectedSpan[1] {
+			t.Errorf("Expected window of minute %d to be %v, got [%s %s]", i, expectedSpan, data[i].Window_start, data[i].Window_end)
+		}
+	}
+}
+
+func Test_main_Sla(t *testing.T) {
+
+	var console, errorConsole bytes.Buffer
+
+	if err := run(context.Background(), []string{"--input_file=./events-template.json", "--sla=25.5"}, &console, &errorConsole); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	data := parseConsoleContent(t, console.Bytes())
+
+	// the average goes from 20 to 25.5 at 18:16 and to 31 at 18:22
+	var withinSla = map[string]bool{
+		"2018-12-26 18:15:00": true,
+		"2018-12-26 18:16:00": true,
+		"2018-12-26 18:21:00": true,
+		"2018-12-26 18:22:00": false,
+	}
+
+	var minutesWithinSla int
+	for _, printableValues := range data {
+		if printableValues.Within_sla == nil {
+			t.Fatalf("Expected within_sla in minute %s", printableValues.Date)
+		}
+
+		if expected, ok := withinSla[printableValues.Date]; ok && *printableValues.Within_sla != expected {
+			t.Errorf("Expected within_sla of minute %s to be %t, got %t", printableValues.Date, expected, *printableValues.Within_sla)
+		}
+
+		if *printableValues.Within_sla {
+			minutesWithinSla++
+		}
+	}
+
+	expectedCompliance := fmt.Sprintf("SLA compliance: %.2f%% of %d minutes\n", float64(minutesWithinSla)*100/float64(len(data)), len(data))
+	if errorConsole.String() != expectedCompliance {
+		t.Errorf("Expected %q in the error console, got %q", expectedCompliance, errorConsole.String())
+	}
+}
+
+func Test_main_FullWindowOnly(t *testing.T) {
+
+	all := getContentFromConsole(t, "--input_file=./events-template.json", "--window_size=10")
+	data := getContentFromConsole(t, "--input_file=./events-template.json", "--window_size=10", "--full-window-only")
+
+	// the first 9 minutes are suppressed and the rest are not changed
+	if len(data) != len(all)-9 {
+		t.Fatalf("Expected %d minutes, got %d", len(all)-9, len(data))
+	}
+
+	for i := range data {
+		if data[i] != all[i+9] {
+			t.Errorf("Expected minute %d to be %v, got %v", i, all[i+9], data[i])
+		}
+	}
+}
+
+func Test_main_Delta(t *testing.T) {
+
+	inputFilePath := filepath.Join(t.TempDir(), "events.json")
+	events := `{"timestamp": "2018-12-26 18:11:08","duration": 20}
+{"timestamp": "2018-12-26 18:12:08","duration": 40}
+{"timestamp": "2018-12-26 18:13:08","duration": 90}
+`
+	if err := os.WriteFile(inputFilePath, []byte(events), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	data := getContentFromConsole(t, "--input_file="+inputFilePath, "--window_size=2", "--delta")
+
+	// averages 0, 20, 30, 65 and deltas 0, 20, 10, 35
+	var expectedDeltas = []float64{0, 20, 10, 35}
+
+	if len(data) != len(expectedDeltas) {
+		t.Fatalf("Expected %d minutes, got %d", len(expectedDeltas), len(data))
+	}
+
+	for i := range data {
+		if data[i].Delta == nil || *data[i].Delta != expectedDeltas[i] {
+			t.Errorf("Expected delta for minute %d to be %f, got %v", i, expectedDeltas[i], data[i].Delta)
+		}
+		if data[i].Delta_prev != nil {
+			t.Errorf("Expected no delta_prev for minute %d without --diff, got %v", i, *data[i].Delta_prev)
+		}
+	}
+
+	// with both flags the two fields have the same value
+	var console bytes.Buffer
+	if err := run(context.Background(), []string{"--input_file=" + inputFilePath, "--window_size=2", "--delta", "--diff"}, &console, io.Discard); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(console.String(), `{"date":"2018-12-26 18:14:00","average_delivery_time":65,"delta_prev":35,"delta":35}`) {
+		t.Errorf("Expected the delta_prev and delta fields, got %q", console.String())
+	}
+}
+
+func Test_main_Explode(t *testing.T) {
+
+	inputFilePath := filepath.Join(t.TempDir(), "events.json")
+	events := `{"timestamp": "2018-12-26 18:11:08","duration": 20}
+{"timestamp": "2018-12-26 18:11:38","duration": 40}
+{"timestamp": "2018-12-26 18:13:08","duration": 90}
+`
+	if err := os.WriteFile(inputFilePath, []byte(events), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var console, errorConsole bytes.Buffer
+
+	if err := run(context.Background(), []string{"--input_file=" + inputFilePath, "--explode"}, &console, &errorConsole); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := `2018-12-26 18:11:00 []
+2018-12-26 18:12:00 [20 40]
+2018-12-26 18:13:00 []
+2018-12-26 18:14:00 [90]
+`
+	if errorConsole.String() != expected {
+		t.Errorf("Expected %q in the error console, got %q", expected, errorConsole.String())
+	}
+
+	// the output is not changed
+	if console.String() != getConsoleOutput(t, "--input_file="+inputFilePath) {
+		t.Errorf("Expected the output to not change with --explode, got %s", console.String())
+	}
+}
+
+func getConsoleOutput(t *testing.T, arguments ...string) string {
+
+	var console bytes.Buffer
+
+	if err := run(context.Background(), arguments, &console, io.Discard); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	return console.String()
+}
+
+func Test_main_TimestampsWithoutSeconds(t *testing.T) {
+
+	inputFilePath := filepath.Join(t.TempDir(), "events.json")
+	events := `{"timestamp": "2018-12-26 18:11","duration": 20}
+{"timestamp": "2018-12-26 18:15","duration": 31}
+{"timestamp": "2018-12-26 18:23","duration": 54}
+`
+	if err := os.WriteFile(inputFilePath, []byte(events), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// the events are in the same minutes as the ones in events.json, so they have the same output
+	data := getContentFromConsole(t, "--input_file="+inputFilePath)
+	expected := getContentFromConsole(t, "--input_file=./events.json")
+
+	if len(data) != len(expected) {
+		t.Fatalf("Expected %d minutes, got %d", len(expected), len(data))
+	}
+
+	for i := range data {
+		if data[i] != expected[i] {
+			t.Errorf("Expected minute %d to be %v, got %v", i, expected[i], data[i])
+		}
+	}
+}
+
+func Test_main_FixedRange(t *testing.T) {
+
+	all := getContentFromConsole(t, "--input_file=./events.json")
+	data := getContentFromConsole(t, "--input_file=./events.json", "--range-start=2018-12-26 18:05:00", "--range-end=2018-12-26 18:30")
+
+	if len(data) != 26 {
+		t.Fatalf("Expected 26 minutes from 18:05 to 18:30, got %d", len(data))
+	}
+
+	if data[0].Date != "2018-12-26 18:05:00" || data[len(data)-1].Date != "2018-12-26 18:30:00" {
+		t.Errorf("Expected the output from 18:05 to 18:30, got from %s to %s", data[0].Date, data[len(data)-1].Date)
+	}
+
+	// the minutes before the data are padded with zeros, and the minutes of the data are not changed
+	for i := 0; i < 6; i++ {
+		if data[i].Average_delivery_time != 0 {
+			t.Errorf("Expected minute %s to be 0, got %f", data[i].Date, data[i].Average_delivery_time)
+		}
+	}
+	for i := range all {
+		if data[i+6] != all[i] {
+			t.Errorf("Expected minute %d to be %v, got %v", i+6, all[i], data[i+6])
+		}
+	}
+
+	// the window still includes the last deliveries after the data ends
+	if data[len(data)-1].Average_delivery_time != 54 {
+		t.Errorf("Expected the last minute to have the average of the last window, got %f", data[len(data)-1].Average_delivery_time)
+	}
+}
+
+func Test_main_InvalidRange(t *testing.T) {
+
+	err := run(context.Background(), []string{"--input_file=./events.json", "--range-start=2018-12-26 18:30:00", "--range-end=2018-12-26 18:05:00"}, io.Discard, io.Discard)
+
+	if err == nil {
+		t.Errorf("Expected an error with the start of the range after the end")
+	}
+}
+
+func Test_main_RelativeRange(t *testing.T) {
+
+	// the range is relative to the time of --now, instead of the current time
+	data := getContentFromConsole(t, "--input_file=./events.json", "--now=2018-12-26 18:30:40", "--range-start=-25m", "--range-end=now")
+	expected := getContentFromConsole(t, "--input_file=./events.json", "--range-start=2018-12-26 18:05:00", "--range-end=2018-12-26 18:30")
+
+	if len(data) != 26 || !reflect.DeepEqual(data, expected) {
+		t.Errorf("Expected the output from 18:05 to 18:30, got %v", data)
+	}
+
+	// the current time is used without --now
+	options, err := parseFlags([]string{"--range-start=-1h"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if since := time.Since(options.rangeStart); since < time.Hour || since > time.Hour+2*time.Minute {
+		t.Errorf("Expected the range to start one hour ago, got %s", options.rangeStart)
+	}
+
+	// the hidden flag is not in the usage, and the defaults are the ones before parsing
+	var usage bytes.Buffer
+	flags := flag.NewFlagSet("go-challenge", flag.ContinueOnError)
+	flags.SetOutput(&usage)
+	flags.String("range-start", "", "first minute of the output")
+	flags.Uint("window_size", 10, "window size")
+	flags.String("now", "", "")
+	flags.Parse([]string{"--window_size=3"})
+	printUsage(flags, "now")
+	if strings.Contains(usage.String(), "-now") || !strings.Contains(usage.String(), "-range-start") || !strings.Contains(usage.String(), "(default 10)") {
+		t.Errorf("Expected the usage without --now, got %q", usage.String())
+	}
+}
+
+func Test_main_NoFutureMinutes(t *testing.T) {
+
+	// the last event is after the current time of --now
+	inputFilePath := filepath.Join(t.TempDir(), "events.json")
+	events := `{"timestamp": "2018-12-26 18:11:08","duration": 20}
+{"timestamp": "2018-12-26 18:15:19","duration": 31}
+{"timestamp": "2018-12-26 18:23:19","duration": 54}
+`
+	if err := os.WriteFile(inputFilePath, []byte(events), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	now := func() time.Time { return time.Date(2018, 12, 26, 18, 20, 0, 0, time.UTC) }
+	data, _, lastMinute, statistics, err := readTranslationsFileAndProcessData(inputFilePath, options{bucket: time.Minute, noFutureMinutes: true, clock: now})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if statistics.Total_events != 2 || statistics.Skipped_lines != 1 || len(data) != 2 || lastMinute.Format("15:04") != "18:16" {
+		t.Errorf("Expected the future event to be skipped, got %d events, %d skipped lines and the last minute %s", statistics.Total_events, statistics.Skipped_lines, lastMinute)
+	}
+
+	// the output ends at the last event before now, the same with --assume-sorted
+	for _, arguments := range [][]string{{}, {"--assume-sorted"}} {
+		data := getContentFromConsole(t, append(arguments, "--input_file="+inputFilePath, "--no-future-minutes", "--now=2018-12-26 18:20:00")...)
+		if len(data) == 0 || data[len(data)-1].Date != "2018-12-26 18:16:00" {
+			t.Errorf("Expected the output to end at 18:16 with %v, got %v", arguments, data)
+		}
+	}
+
+	// without the flag the event is used
+	if data := getContentFromConsole(t, "--input_file="+inputFilePath, "--now=2018-12-26 18:20:00"); data[len(data)-1].Date != "2018-12-26 18:24:00" {
+		t.Errorf("Expected the output to end at 18:24 without --no-future-minutes, got %v", data)
+	}
+}
+
+func Test_parseNow(t *testing.T) {
+
+	// the times with an offset are converted to UTC
+	for _, value := range []string{"2018-12-26T19:20:00+01:00", "2018-12-26T18:20:00Z", "2018-12-26 18:20:00", "2018-12-26 18:20"} {
+		now, err := parseNow(value)
+		if err != nil || !now.Equal(time.Date(2018, 12, 26, 18, 20, 0, 0, time.UTC)) || now.Location() != time.UTC {
+			t.Errorf("Expected %s to be 18:20 UTC, got %s and %v", value, now, err)
+		}
+	}
+
+	if _, err := parseNow("yesterday"); err == nil {
+		t.Errorf("Expected an error for a time that is not valid")
+	}
+}
+
+func Test_main_FutureEventsWithNow(t *testing.T) {
+
+	// the events after 18:20 UTC are in the future, the same with an offset
+	for _, now := range []string{"2018-12-26T18:20:00Z", "2018-12-26T19:20:00+01:00"} {
+		data := getContentFromConsole(t, "--input_file=./events.json", "--no-future-minutes", "--now="+now)
+		if len(data) == 0 || data[len(data)-1].Date != "2018-12-26 18:16:00" {
+			t.Errorf("Expected the event of 18:23 to be in the future of %s, got %v", now, data)
+		}
+	}
+
+	// a clock before the events skips all of them, and a clock after them skips none
+	for _, printableValues := range getContentFromConsole(t, "--input_file=./events.json", "--no-future-minutes", "--now=2018-12-26T18:00:00Z") {
+		if printableValues.Average_delivery_time != 0 {
+			t.Errorf("Expected no deliveries with every event in the future, got %v", printableValues)
+		}
+	}
+	if data := getContentFromConsole(t, "--input_file=./events.json", "--no-future-minutes", "--now=2018-12-27T00:00:00Z"); !reflect.DeepEqual(data, getContentFromConsole(t, "--input_file=./events.json")) {
+		t.Errorf("Expected every event with the clock after them, got %v", data)
+	}
+
+	// the streams use the same clock
+	options, err := parseFlags([]string{"--no-future-minutes", "--now=2018-12-26T18:20:00Z"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var emitted []PrintableValues
+	stream := newEventsStream(options, func(printableValues PrintableValues) { emitted = append(emitted, printableValues) })
+	stream.addLine([]byte(`{"timestamp": "2018-12-26 18:15:19","duration": 31}`))
+	stream.addLine([]byte(`{"timestamp": "2018-12-26 18:23:19","duration": 54}`))
+	stream.close()
+	if len(emitted) == 0 || emitted[len(emitted)-1].Date != "2018-12-26 18:16:00" {
+		t.Errorf("Expected the stream to skip the event in the future, got %v", emitted)
+	}
+}
+
+func Test_main_DurationUnit(t *testing.T) {
+
+	// the same deliveries, some of them in milliseconds
+	inputFilePath := filepath.Join(t.TempDir(), "events.json")
+	events := `{"timestamp": "2018-12-26 18:11:08","duration": 20}
+{"timestamp": "2018-12-26 18:15:19","duration": 31000,"duration_unit": "ms"}
+{"timestamp": "2018-12-26 18:23:19","duration": 54,"duration_unit": "s"}
+`
+	if err := os.WriteFile(inputFilePath, []byte(events), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// the averages are the same as the events in seconds
+	if data, expected := getContentFromConsole(t, "--input_file="+inputFilePath), getContentFromConsole(t, "--input_file=./events.json"); !reflect.DeepEqual(data, expected) {
+		t.Errorf("Expected %v, got %v", expected, data)
+	}
+
+	// in milliseconds the events without a unit are in milliseconds, the ones in seconds are converted
+	data := getContentFromConsole(t, "--input_file="+inputFilePath, "--duration_unit=ms")
+	if last := data[len(data)-1]; last.Average_delivery_time != (31000+54000)/2 {
+		t.Errorf("Expected the average of 31000 and 54000 in the last minute, got %v", last)
+	}
+
+	// an event in an unknown unit is skipped, and an unknown unit is an error
+	if _, err := parseDeliveredTranslation([]byte(`{"timestamp": "2018-12-26 18:11:08","duration": 20,"duration_unit": "h"}`), nil); err == nil {
+		t.Errorf("Expected an error for an unknown unit in an event")
+	}
+	if err := run(context.Background(), []string{"--input_file=" + inputFilePath, "--duration_unit=h"}, io.Discard, io.Discard); err == nil {
+		t.Errorf("Expected an error for an unknown --duration_unit")
+	}
+}
+
+func Test_DeliveryDuration_Representations(t *testing.T) {
+
+	var tests = []struct {
+		json     string
+		expected DeliveryDuration
+	}{
+		{`{"duration": 42}`, 42},
+		{`{"duration": 42.0}`, 42},
+		{`{"duration": 41.6}`, 42},
+		{`{"duration": 41.5}`, 42},
+		{`{"duration": 41.4}`, 41},
+		{`{"duration": "42"}`, 42},
+		{`{"duration": " 42.5 "}`, 43},
+		{`{"duration": 2e1}`, 20},
+		{`{"duration": 2E+1}`, 20},
+		{`{"duration": 2.05e1}`, 21},
+		{`{"duration": 4.2e-1}`, 0},
+		{`{"duration": "2e1"}`, 20},
+		{`{"duration": null}`, 0},
+		{`{}`, 0},
+	}
+
+	for _, test := range tests {
+		var deliveredTranslation DeliveredTranslation
+
+		if err := json.Unmarshal([]byte(test.json), &deliveredTranslation); err != nil {
+			t.Errorf("Expected no error for %s, got %v", test.json, err)
+		}
+
+		if deliveredTranslation.Duration != test.expected {
+			t.Errorf("Expected duration %d for %s, got %d", test.expected, test.json, deliveredTranslation.Duration)
+		}
+	}
+
+	for _, invalid := range []string{`{"duration": "fast"}`, `{"duration": true}`, `{"duration": "NaN"}`, `{"duration": 1e300}`, `{"duration": "-Inf"}`} {
+		var deliveredTranslation DeliveredTranslation
+
+		if err := json.Unmarshal([]byte(invalid), &deliveredTranslation); err == nil {
+			t.Errorf("Expected an error for %s", invalid)
+		}
+	}
+}
+
+func Test_main_Align(t *testing.T) {
+
+	inputFilePath := filepath.Join(t.TempDir(), "events.json")
+	events := `{"timestamp": "2018-12-26 18:11:50","duration": 20}
+{"timestamp": "2018-12-26 18:12:20","duration": 40}
+`
+	if err := os.WriteFile(inputFilePath, []byte(events), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// with calendar minutes the events are in different minutes
+	calendar := getContentFromConsole(t, "--input_file="+inputFilePath, "--align=calendar")
+	expectedCalendar := []PrintableValues{
+		{Date: "2018-12-26 18:11:00", Average_delivery_time: 0},
+		{Date: "2018-12-26 18:12:00", Average_delivery_time: 20},
+		{Date: "2018-12-26 18:13:00", Average_delivery_time: 30},
+	}
+
+	// with minutes starting at the first event, both events are in the same minute
+	data := getContentFromConsole(t, "--input_file="+inputFilePath, "--align=data")
+	expectedData := []PrintableValues{
+		{Date: "2018-12-26 18:11:50", Average_delivery_time: 0},
+		{Date: "2018-12-26 18:12:50", Average_delivery_time: 60},
+	}
+
+	if !reflect.DeepEqual(calendar, expectedCalendar) {
+		t.Errorf("Expected calendar minutes %v, got %v", expectedCalendar, calendar)
+	}
+
+	if !reflect.DeepEqual(data, expectedData) {
+		t.Errorf("Expected data minutes %v, got %v", expectedData, data)
+	}
+}
+
+func Test_eventMinute_BeforeOrigin(t *testing.T) {
+
+	origin, _ := parseTimestamp("2018-12-26 18:11:50")
+	eventTime, _ := parseTimestamp("2018-12-26 18:11:20")
+
+	if minute := eventMinute(eventTime, origin, options{bucket: time.Minute}); minute.Format("2006-01-02 15:04:05") != "2018-12-26 18:11:50" {
+		t.Errorf("Expected an event before the origin to be in the minute ending at the origin, got %s", minute)
+	}
+}
+
+func Test_main_RoundToWindow(t *testing.T) {
+
+	all := getContentFromConsole(t, "--input_file=./events.json", "--window_size=10")
+	data := getContentFromConsole(t, "--input_file=./events.json", "--window_size=10", "--round-to-window")
+
+	// the output starts at 18:10 instead of 18:11, and the rest of the minutes are not changed
+	if data[0].Date != "2018-12-26 18:10:00" || data[0].Average_delivery_time != 0 {
+		t.Errorf("Expected the first minute to be 18:10 with 0, got %v", data[0])
+	}
+
+	if len(data) != len(all)+1 {
+		t.Fatalf("Expected %d minutes, got %d", len(all)+1, len(data))
+	}
+	for i := range all {
+		if data[i+1] != all[i] {
+			t.Errorf("Expected minute %d to be %v, got %v", i+1, all[i], data[i+1])
+		}
+	}
+}
+
+func Test_roundDownToWindow(t *testing.T) {
+
+	var tests = []struct {
+		minute     string
+		windowSize uint
+		expected   string
+	}{
+		{"2018-12-26 18:11:00", 10, "2018-12-26 18:10:00"},
+		{"2018-12-26 18:10:00", 10, "2018-12-26 18:10:00"},
+		{"2018-12-26 18:11:00", 7, "2018-12-26 18:10:00"},
+		{"2018-12-26 18:11:00", 60, "2018-12-26 18:00:00"},
+		{"1969-12-31 23:55:00", 10, "1969-12-31 23:50:00"},
+	}
+
+	for _, test := range tests {
+		minute, _ := time.Parse("2006-01-02 15:04:05", test.minute)
+
+		if rounded := roundDownToWindow(minute, test.windowSize).Format("2006-01-02 15:04:05"); rounded != test.expected {
+			t.Errorf("Expected %s rounded to %d minutes to be %s, got %s", test.minute, test.windowSize, test.expected, rounded)
+		}
+	}
+}
+
+func Test_main_ValueField(t *testing.T) {
+
+	data := getContentFromConsole(t, "--input_file=./events.json", "--value-field=nr_words")
+
+	// the events have 30, 30 and 100 words at 18:12, 18:16 and 18:24
+	var expectedAverages = map[string]float64{
+		"2018-12-26 18:11:00": 0,
+		"2018-12-26 18:12:00": 30,
+		"2018-12-26 18:16:00": 30,
+		"2018-12-26 18:22:00": 30,
+		"2018-12-26 18:24:00": 65,
+	}
+
+	for _, printableValues := range data {
+		if expected, ok := expectedAverages[printableValues.Date]; ok && printableValues.Average_delivery_time != expected {
+			t.Errorf("Expected average of words at %s to be %f, got %f", printableValues.Date, expected, printableValues.Average_delivery_time)
+		}
+	}
+}
+
+func Test_main_Bucket(t *testing.T) {
+
+	inputFilePath := filepath.Join(t.TempDir(), "events.json")
+	events := `{"timestamp": "2018-12-26 18:11:05","duration": 10}
+{"timestamp": "2018-12-26 18:11:08","duration": 20}
+{"timestamp": "2018-12-26 18:11:31","duration": 50}
+`
+	if err := os.WriteFile(inputFilePath, []byte(events), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// the first two events are in the same 10 second bucket, the window of 1 minute has 6 buckets
+	data := getContentFromConsole(t, "--input_file="+inputFilePath, "--window_size=1", "--bucket=10s")
+	expected := []PrintableValues{
+		{Date: "2018-12-26 18:11:00", Average_delivery_time: 0},
+		{Date: "2018-12-26 18:11:10", Average_delivery_time: 30},
+		{Date: "2018-12-26 18:11:20", Average_delivery_time: 30},
+		{Date: "2018-12-26 18:11:30", Average_delivery_time: 30},
+		{Date: "2018-12-26 18:11:40", Average_delivery_time: 40},
+	}
+
+	if !reflect.DeepEqual(data, expected) {
+		t.Errorf("Expected 10 second buckets %v, got %v", expected, data)
+	}
+
+	// a bucket that doesn't divide the window is not valid
+	for _, invalid := range []string{"--bucket=7s", "--bucket=0s", "--bucket=-1m"} {
+		if err := run(context.Background(), []string{"--input_file=" + inputFilePath, invalid}, io.Discard, io.Discard); err == nil {
+			t.Errorf("Expected an error for %s", invalid)
+		}
+	}
+}
+
+func Test_main_Raw(t *testing.T) {
+
+	inputFilePath := filepath.Join(t.TempDir(), "events.json")
+	events := `{"timestamp": "2018-12-26 18:11:08","duration": 20}
+{"timestamp": "2018-12-26 18:11:59","duration": 7}
+{"timestamp": "2018-12-26 18:11:40","duration": 31}
+{"timestamp": "2018-12-26 18:15:19","duration": 54}
+`
+	if err := os.WriteFile(inputFilePath, []byte(events), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	output := getConsoleOutput(t, "--input_file="+inputFilePath, "--raw", "--window_size=2")
+
+	// the sums by hand, only the minutes with deliveries are printed
+	expected := []RawMinute{
+		{Date: "2018-12-26 18:12:00", Sum_duration: 20 + 31 + 7, Count: 3},
+		{Date: "2018-12-26 18:16:00", Sum_duration: 54, Count: 1},
+	}
+
+	var rawMinutes []RawMinute
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		var rawMinute RawMinute
+		if err := json.Unmarshal([]byte(line), &rawMinute); err != nil {
+			t.Fatalf("Expected a JSON line, got %q: %v", line, err)
+		}
+		rawMinutes = append(rawMinutes, rawMinute)
+	}
+
+	if !reflect.DeepEqual(rawMinutes, expected) {
+		t.Errorf("Expected raw minutes %v, got %v", expected, rawMinutes)
+	}
+
+	if csv := getConsoleOutput(t, "--input_file="+inputFilePath, "--raw", "--format=csv"); csv != "date,sum_duration,count\n2018-12-26 18:12:00,58,3\n2018-12-26 18:16:00,54,1\n" {
+		t.Errorf("Expected the raw minutes in CSV, got %q", csv)
+	}
+}
+
+func Test_eventMinute_RoundTimestampsDown(t *testing.T) {
+
+	// an event at the boundary of two minutes, and one just before it
+	for _, test := range []struct {
+		timestamp string
+		roundDown bool
+		bucket    time.Duration
+		expected  string
+	}{
+		{"2018-12-26 18:12:00", false, time.Minute, "2018-12-26 18:13:00"},
+		{"2018-12-26 18:12:00", true, time.Minute, "2018-12-26 18:12:00"},
+		{"2018-12-26 18:11:59.999999", false, time.Minute, "2018-12-26 18:12:00"},
+		{"2018-12-26 18:11:59.999999", true, time.Minute, "2018-12-26 18:11:00"},
+		{"2018-12-26 18:11:50", false, 10 * time.Second, "2018-12-26 18:12:00"},
+		{"2018-12-26 18:11:50", true, 10 * time.Second, "2018-12-26 18:11:50"},
+	} {
+		eventTime, _ := parseTimestamp(test.timestamp)
+		minute := eventMinute(eventTime, time.Time{}, options{bucket: test.bucket, roundTimestampsDown: test.roundDown})
+
+		if minute.Format("2006-01-02 15:04:05") != test.expected {
+			t.Errorf("Expected the event at %s rounded down %t to be in %s, got %s", test.timestamp, test.roundDown, test.expected, minute)
+		}
+	}
+}
+
+func Test_main_RoundTimestampsDown(t *testing.T) {
+
+	// the output starts at the minute of the first event, and every minute is one minute earlier than in the example
+	example := getContentFromConsole(t, "--input_file=./events.json")
+	roundedDown := getContentFromConsole(t, "--input_file=./events.json", "--round-timestamps-down")
+
+	if len(roundedDown) != len(example)-1 {
+		t.Fatalf("Expected one minute less, got %d minutes instead of %d", len(roundedDown), len(example))
+	}
+
+	for i, printableValues := range roundedDown {
+		minute, _ := parseTimestamp(example[i+1].Date)
+		if printableValues.Date != minute.Add(-time.Minute).Format("2006-01-02 15:04:05") || printableValues.Average_delivery_time != example[i+1].Average_delivery_time {
+			t.Errorf("Expected %s to have the average of %s in the example, got %v", printableValues.Date, example[i+1].Date, printableValues)
+		}
+	}
+
+	// the stream of sorted events follows the same convention
+	if sorted := getContentFromConsole(t, "--input_file=./events.json", "--round-timestamps-down", "--assume-sorted"); !reflect.DeepEqual(sorted, roundedDown) {
+		t.Errorf("Expected the same minutes with --assume-sorted, got %v", sorted)
+	}
+}
+
+func Test_main_DropFirst(t *testing.T) {
+
+	data := getContentFromConsole(t, "--input_file=./events.json")
+	dropped := getContentFromConsole(t, "--input_file=./events.json", "--drop-first")
+
+	// the example has 14 minutes, the first is the padding at 18:11
+	if len(data) != 14 || len(dropped) != 13 {
+		t.Fatalf("Expected 14 minutes and 13 without the first, got %d and %d", len(data), len(dropped))
+	}
+	if !reflect.DeepEqual(dropped, data[1:]) {
+		t.Errorf("Expected the rest of the minutes to be unchanged, got %v", dropped)
+	}
+
+	// the streams of events also drop the first minute
+	if sorted := getContentFromConsole(t, "--input_file=./events.json", "--drop-first", "--assume-sorted"); !reflect.DeepEqual(sorted, dropped) {
+		t.Errorf("Expected the same minutes with --assume-sorted, got %v", sorted)
+	}
+
+	// rounding the timestamps down the first minute is the one of the first delivery, at 18:11 with 20, it would drop real data
+	if roundedDown := getContentFromConsole(t, "--input_file=./events.json", "--round-timestamps-down"); roundedDown[0].Average_delivery_time != 20 {
+		t.Errorf("Expected the first minute rounding down to have the first delivery, got %+v", roundedDown[0])
+	}
+	if _, err := parseFlags([]string{"--drop-first", "--round-timestamps-down"}); err == nil {
+		t.Errorf("Expected an error with --drop-first and --round-timestamps-down")
+	}
+}
+
+func Test_main_OutputEvery(t *testing.T) {
+
+	data := getContentFromConsole(t, "--input_file=./events.json")
+
+	// the 14 minutes of the example, every 5th minute from the first and the last one
+	strided := getContentFromConsole(t, "--input_file=./events.json", "--output-every=5")
+	expected := []PrintableValues{data[0], data[5], data[10], data[13]}
+	if !reflect.DeepEqual(strided, expected) {
+		t.Errorf("Expected the minutes %v, got %v", expected, strided)
+	}
+
+	// the last minute is not repeated when it is in the stride
+	strided = getContentFromConsole(t, "--input_file=./events.json", "--output-every=13", "--assume-sorted")
+	expected = []PrintableValues{data[0], data[13]}
+	if !reflect.DeepEqual(strided, expected) {
+		t.Errorf("Expected the minutes %v, got %v", expected, strided)
+	}
+
+	if err := run(context.Background(), []string{"--input_file=./events.json", "--output-every=0"}, io.Discard, io.Discard); err == nil {
+		t.Errorf("Expected an error for --output-every=0")
+	}
+}
+
+func Test_main_SampleAt(t *testing.T) {
+
+	// an event every 7 minutes from 18:05 to 21:20, the output is from 18:05 to 21:21
+	var events strings.Builder
+	for minute := 5; minute <= 200; minute += 7 {
+		fmt.Fprintf(&events, `{"timestamp": "2018-12-26 %02d:%02d:30","duration": %d}`+"\n", 18+minute/60, minute%60, 10+minute%13)
+	}
+	inputFilePath := filepath.Join(t.TempDir(), "events.json")
+	if err := os.WriteFile(inputFilePath, []byte(events.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var averages = make(map[string]float64)
+	for _, printableValues := range getContentFromConsole(t, "--input_file="+inputFilePath) {
+		averages[printableValues.Date] = printableValues.Average_delivery_time
+	}
+
+	// one point per hour, with the average of the window of 10 minutes that ends in it
+	for _, arguments := range [][]string{{"--sample-at=hour"}, {"--sample-at=hour", "--assume-sorted"}} {
+		data := getContentFromConsole(t, append(arguments, "--input_file="+inputFilePath)...)
+
+		var dates []string
+		for _, printableValues := range data {
+			dates = append(dates, printableValues.Date)
+			if printableValues.Average_delivery_time != averages[printableValues.Date] {
+				t.Errorf("Expected the average %v of the window in %s with %v, got %v", averages[printableValues.Date], printableValues.Date, arguments, printableValues.Average_delivery_time)
+			}
+		}
+		if expected := []string{"2018-12-26 19:00:00", "2018-12-26 20:00:00", "2018-12-26 21:00:00"}; !reflect.DeepEqual(dates, expected) {
+			t.Errorf("Expected one point per hour with %v, got %v", arguments, dates)
+		}
+	}
+
+	// the series of the example has no top of the hour
+	if data := getContentFromConsole(t, "--input_file=./events.json", "--sample-at=hour"); len(data) != 0 {
+		t.Errorf("Expected no points in the example, got %v", data)
+	}
+
+	for _, arguments := range [][]string{{"--sample-at=week"}, {"--sample-at=hour", "--output-every=2"}, {"--sample-at=hour", "--bucket=7s", "--window_size=7"}} {
+		if err := run(context.Background(), append(arguments, "--input_file="+inputFilePath), io.Discard, io.Discard); err == nil {
+			t.Errorf("Expected an error with %v", arguments)
+		}
+	}
+}
+
+func Test_main_WithThroughput(t *testing.T) {
+
+	data := getContentFromConsole(t, "--input_file=./events-template.json", "--with-throughput")
+
+	// the deliveries are at 18:12, 18:16, 18:24 and 18:41, the window has up to 10 minutes
+	var expectedThroughputs = map[string]float64{
+		"2018-12-26 18:11:00": 0,
+		"2018-12-26 18:12:00": 0.5,
+		"2018-12-26 18:16:00": 2.0 / 6,
+		"2018-12-26 18:21:00": 0.2,
+		"2018-12-26 18:24:00": 0.2,
+		"2018-12-26 18:30:00": 0.1,
+		"2018-12-26 18:35:00": 0,
+		"2018-12-26 18:41:00": 0.1,
+	}
+
+	for _, printableValues := range data {
+		if printableValues.Throughput == nil {
+			t.Fatalf("Expected the throughput at %s", printableValues.Date)
+		}
+		if expected, ok := expectedThroughputs[printableValues.Date]; ok && *printableValues.Throughput != expected {
+			t.Errorf("Expected a throughput of %f at %s, got %f", expected, printableValues.Date, *printableValues.Throughput)
+		}
+	}
+
+	// with buckets of 30 seconds each delivery counts twice per minute
+	data = getContentFromConsole(t, "--input_file=./events-template.json", "--with-throughput", "--bucket=30s", "--window_size=1")
+	if throughput := data[len(data)-1].Throughput; throughput == nil || *throughput != 1 {
+		t.Errorf("Expected a throughput of 1 in the last minute, got %v", throughput)
+	}
+}
+
+func Test_main_FailOnEmptyWindow(t *testing.T) {
+
+	// the example has no gap longer than the window of 10 minutes
+	if err := run(context.Background(), []string{"--input_file=./events.json", "--fail-on-empty-window", "--range-end=2018-12-26 19:00:00"}, io.Discard, io.Discard); err != nil {
+		t.Errorf("Expected no error without gaps, got %v", err)
+	}
+
+	// with a window of 3 minutes the deliveries at 18:12, 18:16 and 18:24 leave empty windows at 18:15 and from 18:19 to 18:23
+	for _, arguments := range [][]string{{"--window_size=3"}, {"--window_size=3", "--assume-sorted"}} {
+		var console bytes.Buffer
+		err := run(context.Background(), append(arguments, "--input_file=./events.json", "--fail-on-empty-window"), &console, io.Discard)
+
+		if err == nil || err.Error() != "6 minutes have an empty window, the first is 2018-12-26 18:15:00" {
+			t.Errorf("Expected an error for the empty windows with %v, got %v", arguments, err)
+		}
+
+		// the output is still written
+		if data := parseConsoleContent(t, console.Bytes()); len(data) != 14 {
+			t.Errorf("Expected the 14 minutes of the output, got %d", len(data))
+		}
+	}
+
+	// without the flag the gap is not an error
+	if err := run(context.Background(), []string{"--input_file=./events.json", "--window_size=3"}, io.Discard, io.Discard); err != nil {
+		t.Errorf("Expected no error without the flag, got %v", err)
+	}
+}
+
+func Test_main_MaxWindowSize(t *testing.T) {
+
+	// the window of 4 billion minutes is rejected before it is used
+	err := run(context.Background(), []string{"--input_file=./events.json", "--window_size=4000000000"}, io.Discard, io.Discard)
+	if err == nil || err.Error() != "invalid window size 4000000000, expected a value up to the max window size 10080 (--max-window-size)" {
+		t.Errorf("Expected an error for the oversized window, got %v", err)
+	}
+
+	for _, arguments := range [][]string{{"--window_size=20", "--max-window-size=10"}, {"--stats-window=20", "--max-window-size=10"}, {"--max-window-size=0"}} {
+		if err := run(context.Background(), append(arguments, "--input_file=./events.json"), io.Discard, io.Discard); err == nil {
+			t.Errorf("Expected an error with %v", arguments)
+		}
+	}
+
+	// the limit can be raised, and a window up to it is used
+	expected := getConsoleOutput(t, "--input_file=./events.json", "--window_size=20000", "--max-window-size=20000")
+	if output := getConsoleOutput(t, "--input_file=./events.json", "--window_size=10080"); output != expected {
+		t.Errorf("Expected the same output with the windows longer than the series, got %q and %q", output, expected)
+	}
 }
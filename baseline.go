@@ -0,0 +1,44 @@
+package main
+
+import "fmt"
+
+// function to calculate the averages of every minute of the baseline file (--baseline)
+// the baseline is calculated with the same options as the input, every minute is kept even if it wouldn't be printed
+// returns a map with the average of each minute, by its date
+func calculateBaselineAverages(options options) (map[string]float64, error) {
+	translationsDeliveriesData, firstMinute, lastMinute, _, err := readTranslationsFileAndProcessData(options.baselineFilePath, options)
+	if err != nil {
+		return nil, fmt.Errorf("baseline: %w", err)
+	}
+
+	var window = movingWindow{options: options}
+	var averages = make(map[string]float64)
+
+	for currentMinute := firstMinute; !currentMinute.After(lastMinute); currentMinute = currentMinute.Add(options.bucket) {
+		var date = currentMinute.Format("2006-01-02 15:04:05")
+
+		printableValues, _ := window.next(currentMinute, translationsDeliveriesData[date])
+		averages[date] = printableValues.Average_delivery_time
+	}
+
+	return averages, nil
+}
+
+// function to compare the average of a minute to the average of the same minute in the baseline
+// the comparison is the ratio or the difference to the baseline, depending on the mode
+// the minutes missing in the baseline, and the ratios to a baseline of 0, are the gap value
+func compareToBaseline(date string, average float64, baselineAverages map[string]float64, options options) float64 {
+	baselineAverage, ok := baselineAverages[date]
+	if !ok {
+		return options.baselineGap
+	}
+
+	if options.baselineMode == "difference" {
+		return average - baselineAverage
+	}
+
+	if baselineAverage == 0 {
+		return options.baselineGap
+	}
+	return average / baselineAverage
+}
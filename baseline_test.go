@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"io"
+	"testing"
+)
+
+func Test_main_Baseline(t *testing.T) {
+
+	// the template has the events of the example and one more event at 18:40
+	// so the averages are the same as the baseline's up to 18:24, and the later minutes are missing in the baseline
+	ratios := getContentFromConsole(t, "--input_file=./events-template.json", "--baseline=./events.json", "--baseline-gap=-1")
+	differences := getContentFromConsole(t, "--input_file=./events-template.json", "--baseline=./events.json", "--baseline-mode=difference", "--baseline-gap=-1")
+
+	if len(ratios) != 31 || len(differences) != 31 {
+		t.Fatalf("Expected 31 minutes, got %d and %d", len(ratios), len(differences))
+	}
+
+	for i := range ratios {
+		var expectedRatio, expectedDifference float64
+		switch {
+		case ratios[i].Date == "2018-12-26 18:11:00":
+			// the ratio to a baseline of 0 is the gap
+			expectedRatio, expectedDifference = -1, 0
+		case ratios[i].Date <= "2018-12-26 18:24:00":
+			expectedRatio, expectedDifference = 1, 0
+		default:
+			expectedRatio, expectedDifference = -1, -1
+		}
+
+		if ratios[i].Vs_baseline == nil || *ratios[i].Vs_baseline != expectedRatio {
+			t.Errorf("Expected a ratio of %f at %s, got %v", expectedRatio, ratios[i].Date, ratios[i].Vs_baseline)
+		}
+		if differences[i].Vs_baseline == nil || *differences[i].Vs_baseline != expectedDifference {
+			t.Errorf("Expected a difference of %f at %s, got %v", expectedDifference, differences[i].Date, differences[i].Vs_baseline)
+		}
+	}
+
+	// the baseline compared to the template, with a smaller window
+	data := getContentFromConsole(t, "--input_file=./events.json", "--baseline=./events-template.json", "--baseline-mode=difference", "--window_size=2")
+	if vsBaseline := data[len(data)-1].Vs_baseline; vsBaseline == nil || *vsBaseline != 0 {
+		t.Errorf("Expected the same average as the baseline at 18:24, got %v", vsBaseline)
+	}
+
+	for _, invalid := range []string{"--baseline-mode=percent", "--baseline=./missing.json"} {
+		if err := run(context.Background(), []string{"--input_file=./events.json", invalid}, io.Discard, io.Discard); err == nil {
+			t.Errorf("Expected an error for %s", invalid)
+		}
+	}
+}
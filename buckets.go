@@ -0,0 +1,31 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"slices"
+)
+
+// function to write the minutes with deliveries to a file, before the averages are calculated (--dump-buckets)
+// the file is an indented JSON array of the minutes in chronological order, with the same fields as the --raw output
+func writeBuckets(bucketsFilePath string, translationsDeliveriesData map[string]MinuteDeliveries) error {
+	var dates = make([]string, 0, len(translationsDeliveriesData))
+	for date := range translationsDeliveriesData {
+		dates = append(dates, date)
+	}
+	// the dates have the same format, so their order is the chronological order
+	slices.Sort(dates)
+
+	var buckets = make([]RawMinute, 0, len(dates))
+	for _, date := range dates {
+		minuteDeliveries := translationsDeliveriesData[date]
+		buckets = append(buckets, RawMinute{Date: date, Sum_duration: minuteDeliveries.Duration, Count: minuteDeliveries.Count})
+	}
+
+	document, err := json.MarshalIndent(buckets, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(bucketsFilePath, append(document, '\n'), 0644)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_main_DumpBuckets(t *testing.T) {
+
+	bucketsFilePath := filepath.Join(t.TempDir(), "buckets.json")
+	console, _ := getConsoleAndStderr(t, "--input_file=./events.json", "--dump-buckets="+bucketsFilePath)
+
+	// the output is not changed
+	if console != getConsoleOutput(t, "--input_file=./events.json") {
+		t.Errorf("Expected the same output with --dump-buckets, got %q", console)
+	}
+
+	content, err := os.ReadFile(bucketsFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buckets []RawMinute
+	if err := json.Unmarshal(content, &buckets); err != nil {
+		t.Fatal(err)
+	}
+
+	// the events of the sample file are at 18:11:08, 18:15:19 and 18:23:19, the minutes are labeled with their end
+	expected := []RawMinute{
+		{Date: "2018-12-26 18:12:00", Sum_duration: 20, Count: 1},
+		{Date: "2018-12-26 18:16:00", Sum_duration: 31, Count: 1},
+		{Date: "2018-12-26 18:24:00", Sum_duration: 54, Count: 1},
+	}
+	if !reflect.DeepEqual(buckets, expected) {
+		t.Errorf("Expected the buckets %+v, got %+v", expected, buckets)
+	}
+
+	// the buckets are the same as the minutes of the --raw output
+	var rawMinutes []RawMinute
+	for _, line := range strings.Split(strings.TrimSpace(getConsoleOutput(t, "--input_file=./events.json", "--raw")), "\n") {
+		var rawMinute RawMinute
+		if err := json.Unmarshal([]byte(line), &rawMinute); err != nil {
+			t.Fatal(err)
+		}
+		rawMinutes = append(rawMinutes, rawMinute)
+	}
+	if !reflect.DeepEqual(rawMinutes, buckets) {
+		t.Errorf("Expected the buckets of the --raw output %+v, got %+v", rawMinutes, buckets)
+	}
+
+	if err := run(context.Background(), []string{"--input_file=./events.json", "--dump-buckets=" + bucketsFilePath, "--assume-sorted"}, io.Discard, io.Discard); err == nil {
+		t.Errorf("Expected an error with --assume-sorted")
+	}
+}
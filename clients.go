@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+)
+
+// struct with the number of deliveries of a client
+type clientDeliveries struct {
+	name       string
+	deliveries int
+}
+
+// function to get the n clients with the most deliveries
+// the clients with the same number of deliveries are sorted by name, so the ranking is stable
+func topClients(deliveriesPerClient map[string]int, n int) []clientDeliveries {
+	var clients []clientDeliveries
+	for name, deliveries := range deliveriesPerClient {
+		clients = append(clients, clientDeliveries{name: name, deliveries: deliveries})
+	}
+
+	slices.SortFunc(clients, func(a, b clientDeliveries) int {
+		if a.deliveries != b.deliveries {
+			return b.deliveries - a.deliveries
+		}
+		return strings.Compare(a.name, b.name)
+	})
+
+	return clients[:min(n, len(clients))]
+}
+
+// function to print the ranking of the clients and their share of the total deliveries
+func printTopClients(output io.Writer, clients []clientDeliveries, totalDeliveries int) {
+	for i, client := range clients {
+		fmt.Fprintf(output, "%d. %s: %d deliveries (%.2f%%)\n", i+1, client.name, client.deliveries, float64(client.deliveries)*100/float64(totalDeliveries))
+	}
+}
+
+// type of the --client flag, the set of clients whose events are used
+// an empty set uses the events of every client
+type clientSet map[string]bool
+
+func (clients clientSet) String() string {
+	var names []string
+	for name := range clients {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	return strings.Join(names, ",")
+}
+
+// function to add a comma separated list of clients to the set, the flag can be repeated
+func (clients clientSet) Set(value string) error {
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			clients[name] = true
+		}
+	}
+	return nil
+}
+
+// function to add the clients of a file to the set (--clients-file)
+// the file has one client per line, the whitespace around the names is trimmed and the blank lines are ignored
+func (clients clientSet) readFile(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	for _, line := range strings.Split(string(content), "\n") {
+		if name := strings.TrimSpace(line); name != "" {
+			clients[name] = true
+		}
+	}
+	return nil
+}
+
+// function to check if the events of a client are used
+func (clients clientSet) includes(name string) bool {
+	return len(clients) == 0 || clients[name]
+}
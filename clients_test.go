@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_main_TopNClients(t *testing.T) {
+
+	inputFilePath := filepath.Join(t.TempDir(), "events.json")
+	events := `{"timestamp": "2018-12-26 18:11:08","client_name": "taxi-eats","duration": 20}
+{"timestamp": "2018-12-26 18:12:08","client_name": "airliberty","duration": 40}
+{"timestamp": "2018-12-26 18:13:08","client_name": "taxi-eats","duration": 90}
+{"timestamp": "2018-12-26 18:14:08","client_name": "booksy","duration": 10}
+{"timestamp": "2018-12-26 18:15:08","client_name": "airliberty","duration": 30}
+{"timestamp": "2018-12-26 18:16:08","client_name": "zoomcar","duration": 10}
+{"timestamp": "2018-12-26 18:17:08","client_name": "airliberty","duration": 30}
+{"timestamp": "2018-12-26 18:18:08","client_name": "booksy","duration": 10}
+`
+	if err := os.WriteFile(inputFilePath, []byte(events), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var console, errorConsole bytes.Buffer
+
+	if err := run(context.Background(), []string{"--input_file=" + inputFilePath, "--top-n-clients=3"}, &console, &errorConsole); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// booksy and taxi-eats have the same number of deliveries and are sorted by name
+	expected := `1. airliberty: 3 deliveries (37.50%)
+2. booksy: 2 deliveries (25.00%)
+3. taxi-eats: 2 deliveries (25.00%)
+`
+	if errorConsole.String() != expected {
+		t.Errorf("Expected %q in the error console, got %q", expected, errorConsole.String())
+	}
+}
+
+func Test_topClients_FewerClientsThanN(t *testing.T) {
+
+	clients := topClients(map[string]int{"airliberty": 1}, 5)
+
+	if len(clients) != 1 || clients[0].name != "airliberty" {
+		t.Errorf("Expected only airliberty, got %v", clients)
+	}
+}
+
+func Test_main_ClientsFile(t *testing.T) {
+
+	directory := t.TempDir()
+	clientsFilePath := filepath.Join(directory, "clients.txt")
+	if err := os.WriteFile(clientsFilePath, []byte("  airliberty \n\n\t\nbooksy\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	inputFilePath := filepath.Join(directory, "events.json")
+	events := `{"timestamp": "2018-12-26 18:11:08","client_name": "airliberty","duration": 20}
+{"timestamp": "2018-12-26 18:11:18","client_name": "taxi-eats","duration": 100}
+{"timestamp": "2018-12-26 18:11:28","client_name": "booksy","duration": 40}
+{"timestamp": "2018-12-26 18:11:38","client_name": "uber eats","duration": 1000}
+`
+	if err := os.WriteFile(inputFilePath, []byte(events), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// the events of airliberty and booksy, without the blank lines and the whitespace of the file
+	data := getContentFromConsole(t, "--input_file="+inputFilePath, "--clients-file="+clientsFilePath)
+	if average := data[len(data)-1].Average_delivery_time; average != 60 {
+		t.Errorf("Expected the average of the clients of the file to be 60, got %f", average)
+	}
+
+	// the clients of the file and of --client are combined
+	data = getContentFromConsole(t, "--input_file="+inputFilePath, "--clients-file="+clientsFilePath, "--client=uber eats")
+	if average := data[len(data)-1].Average_delivery_time; average != 1060 {
+		t.Errorf("Expected the average of the clients of the file and the flag to be 1060, got %f", average)
+	}
+
+	data = getContentFromConsole(t, "--input_file="+inputFilePath, "--client=taxi-eats", "--assume-sorted")
+	if average := data[len(data)-1].Average_delivery_time; average != 100 {
+		t.Errorf("Expected the average of taxi-eats to be 100, got %f", average)
+	}
+
+	if err := run(context.Background(), []string{"--input_file=" + inputFilePath, "--clients-file=" + filepath.Join(directory, "missing.txt")}, io.Discard, io.Discard); err == nil {
+		t.Errorf("Expected an error for a missing clients file")
+	}
+}
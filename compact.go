@@ -0,0 +1,41 @@
+package main
+
+// struct that prints the runs of consecutive minutes with an average of 0 as one line (--compact-zeros)
+// the minutes of a run are not printed until the run ends, only its first and last minutes are kept
+type zeroRunsCompactor struct {
+	print func(PrintableValues)
+
+	// first and last minutes of the run being compacted, and its number of minutes
+	first, last PrintableValues
+	length      int
+}
+
+// function to add the next printed minute, the run of zeros before a minute with deliveries is printed before it
+func (compactor *zeroRunsCompactor) add(printableValues PrintableValues) {
+	if printableValues.Average_delivery_time == 0 {
+		if compactor.length == 0 {
+			compactor.first = printableValues
+		}
+		compactor.last = printableValues
+		compactor.length++
+		return
+	}
+
+	compactor.flush()
+	compactor.print(printableValues)
+}
+
+// function to print the run being compacted, must be called after the last minute
+// a run of a single minute is printed as it is
+func (compactor *zeroRunsCompactor) flush() {
+	switch compactor.length {
+	case 0:
+		return
+	case 1:
+		compactor.print(compactor.first)
+	default:
+		compactor.print(PrintableValues{Date: compactor.first.Date, From: compactor.first.Date, To: compactor.last.Date})
+	}
+
+	compactor.length = 0
+}
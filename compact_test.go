@@ -0,0 +1,54 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func Test_main_CompactZeros(t *testing.T) {
+
+	// the deliveries of 18:11 and 19:30 with a gap of more than an hour between their windows
+	inputFilePath := filepath.Join(t.TempDir(), "events.json")
+	events := `{"timestamp": "2018-12-26 18:11:08","duration": 20}
+{"timestamp": "2018-12-26 19:30:08","duration": 31}
+`
+	if err := os.WriteFile(inputFilePath, []byte(events), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// the first minute is a single zero, printed as it is, and the gap is one line
+	expected := []PrintableValues{
+		{Date: "2018-12-26 18:11:00"},
+		{Date: "2018-12-26 18:12:00", Average_delivery_time: 20},
+		{Date: "2018-12-26 18:13:00", Average_delivery_time: 20},
+		{Date: "2018-12-26 18:14:00", Average_delivery_time: 20},
+		{Date: "2018-12-26 18:15:00", Average_delivery_time: 20},
+		{Date: "2018-12-26 18:16:00", Average_delivery_time: 20},
+		{Date: "2018-12-26 18:17:00", From: "2018-12-26 18:17:00", To: "2018-12-26 19:30:00"},
+		{Date: "2018-12-26 19:31:00", Average_delivery_time: 31},
+	}
+	for _, flag := range []string{"--compact-zeros", "--merge-adjacent-zero-runs"} {
+		if data := getContentFromConsole(t, "--input_file="+inputFilePath, "--window_size=5", flag); !reflect.DeepEqual(data, expected) {
+			t.Errorf("Expected %v with %s, got %v", expected, flag, data)
+		}
+	}
+
+	// the runs are the same as the zeros of the full output
+	var zeros int
+	for _, printableValues := range getContentFromConsole(t, "--input_file="+inputFilePath, "--window_size=5") {
+		if printableValues.Average_delivery_time == 0 {
+			zeros++
+		}
+	}
+	if zeros != 1+74 {
+		t.Errorf("Expected 75 minutes with a zero average, got %d", zeros)
+	}
+
+	// a run at the end of the output is printed when the output ends
+	data := getContentFromConsole(t, "--input_file="+inputFilePath, "--window_size=5", "--range-end=2018-12-26 19:40", "--compact-zeros")
+	if last := data[len(data)-1]; last.From != "2018-12-26 19:36:00" || last.To != "2018-12-26 19:40:00" {
+		t.Errorf("Expected the last run from 19:36 to 19:40, got %v", last)
+	}
+}
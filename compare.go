@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// function to compare the series of the input file with an output saved by a previous run (--compare)
+// the rows that differ are printed to stdout, the saved row with "-" and the current one with "+",
+// and it returns an error if there are any, so the exit code of the program is the result of the comparison
+func runCompare(ctx context.Context, options options, stdout io.Writer, stderr io.Writer) error {
+	content, err := os.ReadFile(options.compareFilePath)
+	if err != nil {
+		return err
+	}
+	saved, err := decodeOutput(content)
+	if err != nil {
+		return fmt.Errorf("invalid output in %s: %w", options.compareFilePath, err)
+	}
+
+	// the current series is written as JSON lines, without colors, to be read back
+	var current bytes.Buffer
+	options.format = "json"
+	options.color = "never"
+	options.lineSeparator = "\n"
+	if err := runSeries(ctx, options, &current, stderr); err != nil {
+		return err
+	}
+	actual, err := decodeOutput(current.Bytes())
+	if err != nil {
+		return err
+	}
+
+	if differences := compareSeries(saved, actual, options.compareEps, stdout); differences > 0 {
+		return fmt.Errorf("%d rows differ from %s", differences, options.compareFilePath)
+	}
+	return nil
+}
+
+// function to read the rows of an output, JSON lines or a JSON array
+func decodeOutput(content []byte) ([]PrintableValues, error) {
+	var series []PrintableValues
+
+	if trimmed := bytes.TrimSpace(content); len(trimmed) > 0 && trimmed[0] == '[' {
+		err := json.Unmarshal(trimmed, &series)
+		return series, err
+	}
+
+	var decoder = json.NewDecoder(bytes.NewReader(content))
+	for {
+		var printableValues PrintableValues
+		if err := decoder.Decode(&printableValues); errors.Is(err, io.EOF) {
+			return series, nil
+		} else if err != nil {
+			return nil, err
+		}
+		series = append(series, printableValues)
+	}
+}
+
+// function to print the rows of two series that differ, matched by date, and return how many there are
+// the rows of the current series are printed in its order, followed by the saved rows of the dates it doesn't have
+func compareSeries(saved []PrintableValues, actual []PrintableValues, eps float64, output io.Writer) int {
+	var savedByDate = make(map[string]PrintableValues, len(saved))
+	for _, printableValues := range saved {
+		savedByDate[printableValues.Date] = printableValues
+	}
+
+	var differences int
+	var line []byte
+	var printRow = func(prefix string, printableValues PrintableValues) {
+		line = append(append(line[:0], prefix...), ' ')
+		line = append(appendPrintableValues(line, printableValues, false, false), '\n')
+		output.Write(line)
+	}
+
+	var actualDates = make(map[string]bool, len(actual))
+	for _, printableValues := range actual {
+		actualDates[printableValues.Date] = true
+
+		savedValues, ok := savedByDate[printableValues.Date]
+		if ok && rowsEqual(savedValues, printableValues, eps) {
+			continue
+		}
+
+		if ok {
+			printRow("-", savedValues)
+		}
+		printRow("+", printableValues)
+		differences++
+	}
+
+	for _, printableValues := range saved {
+		if !actualDates[printableValues.Date] {
+			printRow("-", printableValues)
+			differences++
+		}
+	}
+
+	return differences
+}
+
+// function to check if two rows are the same, with the numbers within eps of each other
+// a field added to PrintableValues must also be added here
+func rowsEqual(a PrintableValues, b PrintableValues, eps float64) bool {
+	var numbersEqual = func(x, y *float64) bool {
+		if x == nil || y == nil {
+			return x == y
+		}
+		return math.Abs(*x-*y) <= eps
+	}
+
+	var slaEqual = (a.Within_sla == nil) == (b.Within_sla == nil) && (a.Within_sla == nil || *a.Within_sla == *b.Within_sla)
+
+	return a.Date == b.Date &&
+		numbersEqual(&a.Average_delivery_time, &b.Average_delivery_time) &&
+		numbersEqual(a.Normalized, b.Normalized) &&
+		numbersEqual(a.Delta_prev, b.Delta_prev) &&
+		numbersEqual(a.Delta, b.Delta) &&
+		a.Window_start == b.Window_start &&
+		a.Window_end == b.Window_end &&
+		slaEqual &&
+		numbersEqual(a.Throughput, b.Throughput) &&
+		numbersEqual(a.Percentile, b.Percentile) &&
+		numbersEqual(a.Vs_baseline, b.Vs_baseline) &&
+		a.From == b.From &&
+		a.To == b.To &&
+		a.Stream_id == b.Stream_id
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_main_Compare(t *testing.T) {
+
+	var directory = t.TempDir()
+	var output = getConsoleOutput(t, "--input_file=./events.json", "--with-throughput")
+
+	// an identical output, as JSON lines and as a JSON array
+	savedFilePath := filepath.Join(directory, "saved.json")
+	if err := os.WriteFile(savedFilePath, []byte(output), 0644); err != nil {
+		t.Fatal(err)
+	}
+	arrayFilePath := filepath.Join(directory, "array.json")
+	if err := os.WriteFile(arrayFilePath, []byte(getConsoleOutput(t, "--input_file=./events.json", "--with-throughput", "--format=json-array")), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{savedFilePath, arrayFilePath} {
+		console, _, err := runWithOutputs("--input_file=./events.json", "--with-throughput", "--compare="+path)
+		if err != nil || console != "" {
+			t.Errorf("Expected no differences with %s, got %q and %v", path, console, err)
+		}
+	}
+
+	// a perturbed output, with an average changed by 0.5 and the last minute removed
+	lines := strings.SplitAfter(output, "\n")
+	lines[5] = strings.Replace(lines[5], `"average_delivery_time":25.5`, `"average_delivery_time":26`, 1)
+	perturbedFilePath := filepath.Join(directory, "perturbed.json")
+	if err := os.WriteFile(perturbedFilePath, []byte(strings.Join(lines[:len(lines)-2], "")), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	console, _, err := runWithOutputs("--input_file=./events.json", "--with-throughput", "--compare="+perturbedFilePath)
+	if err == nil || err.Error() != "2 rows differ from "+perturbedFilePath {
+		t.Errorf("Expected 2 rows to differ, got %v", err)
+	}
+	expected := `- {"date":"2018-12-26 18:16:00","average_delivery_time":26,"throughput":0.3333333333333333}
++ {"date":"2018-12-26 18:16:00","average_delivery_time":25.5,"throughput":0.3333333333333333}
++ {"date":"2018-12-26 18:24:00","average_delivery_time":42.5,"throughput":0.2}
+`
+	if console != expected {
+		t.Errorf("Expected the rows that differ %q, got %q", expected, console)
+	}
+
+	// the difference of the average is within the eps, the missing minute is still a difference
+	console, _, err = runWithOutputs("--input_file=./events.json", "--with-throughput", "--compare="+perturbedFilePath, "--compare-eps=0.5")
+	if err == nil || !strings.HasPrefix(console, "+ {\"date\":\"2018-12-26 18:24:00\"") || strings.Count(console, "\n") != 1 {
+		t.Errorf("Expected only the missing minute to differ, got %q and %v", console, err)
+	}
+
+	// a saved output that is not JSON
+	if err := run(context.Background(), []string{"--input_file=./events.json", "--compare=./README.md"}, io.Discard, io.Discard); err == nil {
+		t.Errorf("Expected an error with a saved output that is not JSON")
+	}
+	if err := run(context.Background(), []string{"--input_file=./events.json", "--compare=" + savedFilePath, "--format=csv"}, io.Discard, io.Discard); err == nil {
+		t.Errorf("Expected an error with --compare and --format=csv")
+	}
+}
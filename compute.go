@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+)
+
+// metric calculated over the window, the names are the same as the values of --metric
+type Metric string
+
+// the metrics of --metric that don't need other flags
+const (
+	Mean        Metric = "mean"
+	TrimmedMean Metric = "trimmed-mean"
+	Median      Metric = "median"
+	Max         Metric = "max"
+	Min         Metric = "min"
+	Sum         Metric = "sum"
+)
+
+// struct with the settings of Compute, changed by the options
+// the settings start with the defaults of the command line and are set in the options like their flags
+type computeSettings struct {
+	windowSize     uint
+	metric         Metric
+	withThroughput bool
+	precision      int
+	hasPrecision   bool
+	transform      func(PrintableValues) PrintableValues
+}
+
+// option of Compute, the options not given keep the defaults of the command line
+// a later option has precedence over an earlier one, like a later flag
+type ComputeOption func(settings *computeSettings)
+
+// function to set the window size, in minutes, the same as --window_size, the default is 10
+func WithWindow(windowSize uint) ComputeOption {
+	return func(settings *computeSettings) {
+		settings.windowSize = windowSize
+	}
+}
+
+// function to set the metric calculated over the window, the same as --metric, the default is the mean
+func WithMetric(metric Metric) ComputeOption {
+	return func(settings *computeSettings) {
+		settings.metric = metric
+	}
+}
+
+// function to add the number of deliveries per minute in the window to the values, the same as --with-throughput
+func WithThroughput() ComputeOption {
+	return func(settings *computeSettings) {
+		settings.withThroughput = true
+	}
+}
+
+// function to round the values of the minutes to a number of decimals, the default is to keep them unchanged
+func WithPrecision(decimals int) ComputeOption {
+	return func(settings *computeSettings) {
+		settings.precision = decimals
+		settings.hasPrecision = true
+	}
+}
+
+// function to set a function called with the values of each minute before they are returned, e.g. to tag them
+// it is called after the values are rounded
+func WithTransform(transform func(PrintableValues) PrintableValues) ComputeOption {
+	return func(settings *computeSettings) {
+		settings.transform = transform
+	}
+}
+
+// function to calculate the moving window of the events of a reader, one JSON event per line, and return the values of the minutes
+// it is the calculation of the program without the output, with the defaults of the command line for what the options don't set
+// e.g. Compute(ctx, reader, WithWindow(10), WithMetric(Median), WithPrecision(2))
+func Compute(ctx context.Context, reader io.Reader, computeOptions ...ComputeOption) ([]PrintableValues, error) {
+	// the options of the command line without flags, with the settings of the options set like their flags
+	options, err := parseFlags(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var settings = computeSettings{windowSize: options.windowSize, metric: Metric(options.metric)}
+	for _, computeOption := range computeOptions {
+		computeOption(&settings)
+	}
+	if settings.hasPrecision && settings.precision < 0 {
+		return nil, fmt.Errorf("invalid precision %d, expected a number of decimals greater or equal to 0", settings.precision)
+	}
+
+	options.windowSize = settings.windowSize
+	options.metric = string(settings.metric)
+	options.withThroughput = settings.withThroughput
+	if options, err = validateOptions(options); err != nil {
+		return nil, err
+	}
+
+	series, err := computeSeries(ctx, reader, options)
+	if err != nil {
+		return nil, err
+	}
+
+	if settings.hasPrecision {
+		for i := range series {
+			roundValues(&series[i], settings.precision)
+		}
+	}
+
+	if settings.transform != nil {
+		for i := range series {
+			series[i] = settings.transform(series[i])
+		}
+	}
+
+	return series, nil
+}
+
+// function to calculate the values of the minutes of the events of a reader with the options, used by Compute and --serve
+// the values that are not finite are replaced like in the output, or are an error with --nan-policy=error
+func computeSeries(ctx context.Context, reader io.Reader, options options) ([]PrintableValues, error) {
+	translationsDeliveriesData, firstMinute, lastMinute, _, err := readTranslationsAndProcessData(reader, options)
+	if err != nil {
+		return nil, err
+	}
+
+	// the same range as the output of the program
+	if options.roundToWindow {
+		firstMinute = roundDownToWindow(firstMinute, options.windowSize)
+	}
+	if !options.rangeStart.IsZero() {
+		firstMinute = options.rangeStart
+	}
+	if !options.rangeEnd.IsZero() {
+		lastMinute = options.rangeEnd
+	}
+
+	var window = &movingWindow{options: options}
+	var series []PrintableValues
+	for currentMinute := firstMinute; !currentMinute.After(lastMinute) && ctx.Err() == nil; currentMinute = currentMinute.Add(options.bucket) {
+		var currentMinuteData = translationsDeliveriesData[currentMinute.Format("2006-01-02 15:04:05")]
+
+		if printableValues, printable := window.next(currentMinute, currentMinuteData); printable {
+			series = append(series, printableValues)
+		}
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	// the normalization needs the whole series, so the transform is called after it
+	if options.normalize {
+		normalizeAverages(series)
+	}
+
+	// the values that are not finite are replaced like in the output, so the series can be marshaled to JSON
+	for i := range series {
+		if field, value := replaceNonFinite(&series[i], options.gapValue); field != "" && options.nanPolicy == "error" {
+			return nil, fmt.Errorf("the %s of %s is %v, which is not valid in the output", field, series[i].Date, value)
+		}
+	}
+
+	return series, nil
+}
+
+// function to round the values of a minute to a number of decimals (WithPrecision)
+// the pointers are replaced instead of changing the values they point to, like in replaceNonFinite
+// a float field added to PrintableValues must also be added here
+func roundValues(printableValues *PrintableValues, decimals int) {
+	var scale = math.Pow10(decimals)
+	var round = func(value float64) float64 {
+		return math.Round(value*scale) / scale
+	}
+
+	printableValues.Average_delivery_time = round(printableValues.Average_delivery_time)
+	for _, number := range [...]**float64{&printableValues.Normalized, &printableValues.Delta_prev, &printableValues.Delta, &printableValues.Throughput, &printableValues.Percentile, &printableValues.Vs_baseline} {
+		if *number != nil {
+			rounded := round(**number)
+			*number = &rounded
+		}
+	}
+}
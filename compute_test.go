@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"os"
+	"testing"
+)
+
+func Test_Compute(t *testing.T) {
+
+	// the values of the minutes are the same as the output of the program
+	file, err := os.Open("./events.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	series, err := Compute(context.Background(), file, WithThroughput())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []float64{0, 20, 20, 20, 20, 25.5, 25.5, 25.5, 25.5, 25.5, 25.5, 31, 31, 42.5}
+	if len(series) != len(expected) {
+		t.Fatalf("Expected %d minutes, got %d", len(expected), len(series))
+	}
+	for i, printableValues := range series {
+		if printableValues.Average_delivery_time != expected[i] {
+			t.Errorf("Expected the average %v in the minute %s, got %v", expected[i], printableValues.Date, printableValues.Average_delivery_time)
+		}
+	}
+
+	// a transform that doubles every value of the minutes
+	if _, err := file.Seek(0, 0); err != nil {
+		t.Fatal(err)
+	}
+	doubled, err := Compute(context.Background(), file, WithThroughput(), WithTransform(func(printableValues PrintableValues) PrintableValues {
+		printableValues.Average_delivery_time *= 2
+		throughput := *printableValues.Throughput * 2
+		printableValues.Throughput = &throughput
+		return printableValues
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range series {
+		if doubled[i].Date != series[i].Date || doubled[i].Average_delivery_time != 2*series[i].Average_delivery_time || *doubled[i].Throughput != 2**series[i].Throughput {
+			t.Errorf("Expected the doubled values of %+v, got %+v", series[i], doubled[i])
+		}
+	}
+}
+
+// function to compute the values of the sample events with some options
+func computeSampleEvents(t *testing.T, computeOptions ...ComputeOption) []PrintableValues {
+	file, err := os.Open("./events.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	series, err := Compute(context.Background(), file, computeOptions...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return series
+}
+
+func Test_Compute_Options(t *testing.T) {
+
+	// without options the values are the default output of the program, a window of 10 minutes with the mean
+	defaults := computeSampleEvents(t)
+	expected := getContentFromConsole(t, "--input_file=./events.json")
+	if len(defaults) != len(expected) {
+		t.Fatalf("Expected %d minutes without options, got %d", len(expected), len(defaults))
+	}
+	for i := range defaults {
+		if defaults[i] != expected[i] {
+			t.Errorf("Expected %+v without options, got %+v", expected[i], defaults[i])
+		}
+	}
+
+	// the options are the same as their flags
+	series := computeSampleEvents(t, WithWindow(3), WithMetric(Median))
+	expected = getContentFromConsole(t, "--input_file=./events.json", "--window_size=3", "--metric=median")
+	if len(series) != len(expected) {
+		t.Fatalf("Expected %d minutes with a window of 3 and the median, got %d", len(expected), len(series))
+	}
+	for i := range series {
+		if series[i] != expected[i] {
+			t.Errorf("Expected %+v with a window of 3 and the median, got %+v", expected[i], series[i])
+		}
+	}
+
+	// a later option has precedence over an earlier one
+	if series := computeSampleEvents(t, WithWindow(3), WithWindow(10)); len(series) != len(defaults) || series[5] != defaults[5] {
+		t.Errorf("Expected the window of the later option to replace the earlier one, got %+v", series)
+	}
+
+	// the values are rounded, the sample averages have at most one decimal
+	for i, printableValues := range computeSampleEvents(t, WithMetric(Mean), WithWindow(3), WithThroughput(), WithPrecision(2)) {
+		if rounded := float64(int(*printableValues.Throughput*100+0.5)) / 100; *printableValues.Throughput != rounded {
+			t.Errorf("Expected the throughput of the minute %d rounded to 2 decimals, got %v", i, *printableValues.Throughput)
+		}
+	}
+	if series := computeSampleEvents(t, WithPrecision(0)); series[5].Average_delivery_time != 26 {
+		t.Errorf("Expected the average 25.5 rounded to 26, got %v", series[5].Average_delivery_time)
+	}
+
+	if _, err := Compute(context.Background(), nil, WithPrecision(-1)); err == nil {
+		t.Errorf("Expected an error with a negative precision")
+	}
+	if _, err := Compute(context.Background(), nil, WithMetric("average")); err == nil {
+		t.Errorf("Expected an error with an unknown metric")
+	}
+	if _, err := Compute(context.Background(), nil, WithWindow(20000)); err == nil {
+		t.Errorf("Expected an error with a window bigger than the max window size")
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// function to set the flags that are not in the command line with the values of a config file
+// the file is a flat list of "flag: value" (YAML) or "flag = value" (TOML) lines
+// comments starting with "#", empty lines and TOML tables are ignored, and the values can be quoted
+// the values are set through the flag set, so they are validated like the command line ones
+func applyConfigFile(flags *flag.FlagSet, configFilePath string) error {
+	file, err := os.Open(configFilePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// the flags in the command line take precedence over the config file
+	var setInCommandLine = make(map[string]bool)
+	flags.Visit(func(setFlag *flag.Flag) {
+		setInCommandLine[setFlag.Name] = true
+	})
+
+	var scanner = bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "[") || line == "---" {
+			continue
+		}
+
+		// the separator is the first ":" or "=", the values can have both (like dates or field maps)
+		separatorIndex := strings.IndexAny(line, ":=")
+		if separatorIndex < 0 {
+			return fmt.Errorf("%s:%d: expected flag: value or flag = value, got %q", configFilePath, lineNumber, line)
+		}
+		name := strings.TrimSpace(line[:separatorIndex])
+		value := parseConfigValue(line[separatorIndex+1:])
+
+		if flags.Lookup(name) == nil {
+			return fmt.Errorf("%s:%d: unknown flag %q", configFilePath, lineNumber, name)
+		}
+
+		if setInCommandLine[name] {
+			continue
+		}
+
+		if err := flags.Set(name, value); err != nil {
+			return fmt.Errorf("%s:%d: invalid value %q for flag %s: %w", configFilePath, lineNumber, value, name, err)
+		}
+	}
+
+	return scanner.Err()
+}
+
+// function to get the value of a config file line
+// removes the comments and the quotes around the value
+func parseConfigValue(value string) string {
+	value = strings.TrimSpace(value)
+
+	if strings.HasPrefix(value, `"`) {
+		if closingIndex := strings.LastIndex(value, `"`); closingIndex > 0 {
+			if unquoted, err := strconv.Unquote(value[:closingIndex+1]); err == nil {
+				return unquoted
+			}
+		}
+	}
+
+	if strings.HasPrefix(value, "'") {
+		if closingIndex := strings.LastIndex(value, "'"); closingIndex > 0 {
+			return value[1:closingIndex]
+		}
+	}
+
+	// a comment after an unquoted value
+	if commentIndex := strings.Index(value, " #"); commentIndex >= 0 {
+		value = strings.TrimSpace(value[:commentIndex])
+	}
+
+	return value
+}
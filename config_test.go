@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeConfigFile(t *testing.T, name string, content string) string {
+
+	configFilePath := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(configFilePath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return configFilePath
+}
+
+func Test_parseFlags_YamlConfig(t *testing.T) {
+
+	configFilePath := writeConfigFile(t, "config.yaml", `# settings of the nightly report
+input_file: "./events-template.json"
+window_size: 5 # minutes
+normalize: true
+`)
+
+	options, err := parseFlags([]string{"--config=" + configFilePath})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if options.inputFilePath != "./events-template.json" || options.windowSize != 5 || !options.normalize {
+		t.Errorf("Expected the values of the config file, got %+v", options)
+	}
+}
+
+func Test_parseFlags_TomlConfigOverriddenByCommandLine(t *testing.T) {
+
+	configFilePath := writeConfigFile(t, "config.toml", `[flags]
+input_file = './events-template.json'
+window_size = 5
+`)
+
+	options, err := parseFlags([]string{"--window_size=3", "--config=" + configFilePath})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if options.inputFilePath != "./events-template.json" || options.windowSize != 3 {
+		t.Errorf("Expected the input file of the config and the window size of the command line, got %+v", options)
+	}
+}
+
+func Test_parseFlags_InvalidConfig(t *testing.T) {
+
+	for _, content := range []string{"unknown_flag: 1\n", "window_size: ten\n", "window_size\n"} {
+		configFilePath := writeConfigFile(t, "config.yaml", content)
+
+		if _, err := parseFlags([]string{"--config=" + configFilePath}); err == nil {
+			t.Errorf("Expected an error for config %q", content)
+		}
+	}
+}
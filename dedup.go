@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// struct that finds the events that repeat within a short time with identical fields (--dedup-window)
+// the events are compared by every field read from the file but the timestamp,
+// or only by the value of the key of --dedupe-by if it is set
+type eventsDeduplicator struct {
+	window time.Duration
+	byKey  bool
+
+	// time of the last kept event of each signature
+	lastSeen map[DeliveredTranslation]time.Time
+
+	// the old signatures are removed from time to time, so the map only has the recent events
+	lastPruned time.Time
+}
+
+// function to create a deduplicator of the events within the window, that compares the keys of --dedupe-by if it is set
+func newEventsDeduplicator(window time.Duration, dedupeBy string) *eventsDeduplicator {
+	return &eventsDeduplicator{window: window, byKey: dedupeBy != "", lastSeen: make(map[DeliveredTranslation]time.Time)}
+}
+
+// function to read the value of a key of a line (--dedupe-by), as it is in the line, like "abc" with the quotes or 42
+// returns an empty string if the line doesn't have the key
+func readDedupKey(line []byte, key string) string {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(line, &fields); err != nil {
+		return ""
+	}
+
+	return string(fields[key])
+}
+
+// function to check if an event is identical to an event kept within the window
+// the duplicates are not kept, so the window is measured from the first of the repeated events
+func (deduplicator *eventsDeduplicator) isDuplicate(deliveredTranslation DeliveredTranslation, eventTime time.Time) bool {
+	// the signature is the event without the timestamp, or only its key
+	if deduplicator.byKey {
+		if deliveredTranslation.dedupKey == "" {
+			return false
+		}
+		deliveredTranslation = DeliveredTranslation{dedupKey: deliveredTranslation.dedupKey}
+	} else {
+		deliveredTranslation.Timestamp = ""
+	}
+
+	// removing the signatures older than the window, once per window
+	if eventTime.Sub(deduplicator.lastPruned) > deduplicator.window {
+		for signature, seen := range deduplicator.lastSeen {
+			if eventTime.Sub(seen) > deduplicator.window {
+				delete(deduplicator.lastSeen, signature)
+			}
+		}
+		deduplicator.lastPruned = eventTime
+	}
+
+	// the events can be before the kept one if the file is not sorted
+	if seen, ok := deduplicator.lastSeen[deliveredTranslation]; ok {
+		difference := eventTime.Sub(seen)
+		if difference < 0 {
+			difference = -difference
+		}
+		if difference <= deduplicator.window {
+			return true
+		}
+	}
+
+	deduplicator.lastSeen[deliveredTranslation] = eventTime
+	return false
+}
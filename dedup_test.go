@@ -0,0 +1,87 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_main_DedupWindow(t *testing.T) {
+
+	inputFilePath := filepath.Join(t.TempDir(), "events.json")
+	events := `{"timestamp": "2018-12-26 18:11:08","translation_id": "1","client_name": "airliberty","source_language": "en","target_language": "fr","event_name": "translation_delivered","duration": 20}
+{"timestamp": "2018-12-26 18:11:11","translation_id": "2","client_name": "airliberty","source_language": "en","target_language": "fr","event_name": "translation_delivered","duration": 20}
+{"timestamp": "2018-12-26 18:11:12","translation_id": "3","client_name": "airliberty","source_language": "en","target_language": "fr","event_name": "translation_delivered","duration": 40}
+{"timestamp": "2018-12-26 18:11:12","translation_id": "4","client_name": "taxi-eats","source_language": "en","target_language": "fr","event_name": "translation_delivered","duration": 20}
+{"timestamp": "2018-12-26 18:11:13","translation_id": "5","client_name": "airliberty","source_language": "en","target_language": "fr","event_name": "translation_delivered","duration": 20}
+{"timestamp": "2018-12-26 18:11:20","translation_id": "6","client_name": "airliberty","source_language": "en","target_language": "fr","event_name": "translation_delivered","duration": 20}
+`
+	if err := os.WriteFile(inputFilePath, []byte(events), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// the events 2 and 5 repeat the event 1 within 5 seconds, the 6 is 12 seconds after it
+	// the events 3 and 4 have a different duration and client
+	_, _, _, statistics, err := readTranslationsFileAndProcessData(inputFilePath, options{bucket: time.Minute, dedupWindow: 5 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if statistics.Total_events != 4 || statistics.Duplicate_events != 2 {
+		t.Errorf("Expected 4 events and 2 duplicates, got %d and %d", statistics.Total_events, statistics.Duplicate_events)
+	}
+
+	// (20 + 40 + 20 + 20) / 1 minute with deliveries
+	expected := []PrintableValues{
+		{Date: "2018-12-26 18:11:00", Average_delivery_time: 0},
+		{Date: "2018-12-26 18:12:00", Average_delivery_time: 100},
+	}
+	for _, arguments := range [][]string{{"--dedup-window=5s"}, {"--dedup-window=5s", "--assume-sorted"}} {
+		if data := getContentFromConsole(t, append(arguments, "--input_file="+inputFilePath)...); !reflect.DeepEqual(data, expected) {
+			t.Errorf("Expected %v with %v, got %v", expected, arguments, data)
+		}
+	}
+
+	// without the window every event is kept
+	if data := getContentFromConsole(t, "--input_file="+inputFilePath); data[1].Average_delivery_time != 140 {
+		t.Errorf("Expected every event to be kept without --dedup-window, got %v", data)
+	}
+}
+
+func Test_main_DedupeBy(t *testing.T) {
+
+	// the event 2 is a retry of the event 1 with a different duration, the event 4 has the id of the event 3 after the window
+	// the event 5 has the same fields as the event 1 with another id, and the event 6 has no id
+	inputFilePath := filepath.Join(t.TempDir(), "events.json")
+	events := `{"timestamp": "2018-12-26 18:11:08","delivery_id": "a","client_name": "airliberty","duration": 20}
+{"timestamp": "2018-12-26 18:11:10","delivery_id": "a","client_name": "taxi-eats","duration": 25}
+{"timestamp": "2018-12-26 18:11:12","delivery_id": 7,"client_name": "airliberty","duration": 40}
+{"timestamp": "2018-12-26 18:11:30","delivery_id": 7,"client_name": "airliberty","duration": 40}
+{"timestamp": "2018-12-26 18:11:13","delivery_id": "b","client_name": "airliberty","duration": 20}
+{"timestamp": "2018-12-26 18:11:14","client_name": "airliberty","duration": 20}
+`
+	if err := os.WriteFile(inputFilePath, []byte(events), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// only the event 2 is dropped, (20 + 40 + 40 + 20 + 20) / 1 minute with deliveries
+	expected := []PrintableValues{
+		{Date: "2018-12-26 18:11:00", Average_delivery_time: 0},
+		{Date: "2018-12-26 18:12:00", Average_delivery_time: 140},
+	}
+	for _, arguments := range [][]string{{"--dedup-window=5s", "--dedupe-by=delivery_id"}, {"--dedup-window=5s", "--dedupe-by=delivery_id", "--assume-sorted"}, {"--dedup-window=5s", "--dedupe-by=delivery_id", "--parse-workers=4"}} {
+		if data := getContentFromConsole(t, append(arguments, "--input_file="+inputFilePath)...); !reflect.DeepEqual(data, expected) {
+			t.Errorf("Expected %v with %v, got %v", expected, arguments, data)
+		}
+	}
+
+	// comparing the fields, the event 5 is the duplicate of the event 1 instead, and the event 2 is kept
+	if data := getContentFromConsole(t, "--input_file="+inputFilePath, "--dedup-window=5s"); data[1].Average_delivery_time != 145 {
+		t.Errorf("Expected only the event 5 to be dropped without --dedupe-by, got %v", data)
+	}
+
+	if _, _, err := runWithOutputs("--input_file="+inputFilePath, "--dedupe-by=delivery_id"); err == nil {
+		t.Errorf("Expected an error with --dedupe-by without --dedup-window")
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// function to calculate one value for each group of consecutive minutes of the size of the window (--downsample)
+// unlike the moving average the groups don't overlap, so the output has one minute per window of input
+// the groups start at the first minute, and each value is printed with the date of the last minute of its group
+// the last group can have fewer minutes if the minutes don't divide in whole windows, it is printed with the last minute
+func downsampleMinutes(ctx context.Context, translationsDeliveriesData map[string]MinuteDeliveries, firstMinute time.Time, lastMinute time.Time, options options, print func(PrintableValues)) {
+	var groupSize = int(time.Duration(options.windowSize) * time.Minute / options.bucket)
+	var aggregator = newAggregator(options)
+	var group, groupCounts []int
+
+	for currentMinute := firstMinute; !currentMinute.After(lastMinute) && ctx.Err() == nil; currentMinute = currentMinute.Add(options.bucket) {
+		minuteDeliveries := translationsDeliveriesData[currentMinute.Format("2006-01-02 15:04:05")]
+		group = append(group, minuteDeliveries.Duration)
+		groupCounts = append(groupCounts, minuteDeliveries.Count)
+
+		// the group is complete, or it is the last minute
+		if len(group) == groupSize || !currentMinute.Before(lastMinute) {
+			var average float64
+			if options.averageMode == "per-delivery" {
+				average = deliveriesMean(group, groupCounts)
+			} else {
+				average = aggregateWindow(aggregator, group)
+			}
+
+			print(PrintableValues{
+				Date:                  currentMinute.Format("2006-01-02 15:04:05"),
+				Average_delivery_time: average,
+			})
+			group, groupCounts = group[:0], groupCounts[:0]
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_main_Downsample(t *testing.T) {
+
+	// the 31 minutes of the template from 18:11 in groups of 10, the last group has the minute 18:41
+	// the deliveries are 20 at 18:12, 31 at 18:16, 54 at 18:24 and 100 at 18:41
+	data := getContentFromConsole(t, "--input_file=./events-template.json", "--downsample")
+	expected := []PrintableValues{
+		{Date: "2018-12-26 18:20:00", Average_delivery_time: 25.5},
+		{Date: "2018-12-26 18:30:00", Average_delivery_time: 54},
+		{Date: "2018-12-26 18:40:00", Average_delivery_time: 0},
+		{Date: "2018-12-26 18:41:00", Average_delivery_time: 100},
+	}
+
+	if !reflect.DeepEqual(data, expected) {
+		t.Errorf("Expected %v, got %v", expected, data)
+	}
+
+	// the groups aligned to the window boundaries, with the metric of the window
+	data = getContentFromConsole(t, "--input_file=./events-template.json", "--coalesce-window", "--window_size=15", "--round-to-window", "--agg=max")
+	expected = []PrintableValues{
+		{Date: "2018-12-26 18:14:00", Average_delivery_time: 20},
+		{Date: "2018-12-26 18:29:00", Average_delivery_time: 54},
+		{Date: "2018-12-26 18:41:00", Average_delivery_time: 100},
+	}
+
+	if !reflect.DeepEqual(data, expected) {
+		t.Errorf("Expected %v, got %v", expected, data)
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+)
+
+// struct with an event read from the input, with its parsed time and its line
+type inputEvent struct {
+	deliveredTranslation DeliveredTranslation
+	eventTime            time.Time
+	lineNumber           int
+}
+
+// error that stops the read of the lines after --max-events events, it is not returned by readEvents
+var errMaxEvents = errors.New("maximum number of events read")
+
+// function to read the valid events of the input and call handle with each of them, used by the readers of the files
+// the lines that are not valid and the events in the future (--no-future-minutes) are skipped,
+// the events of the clients and language pairs that are not included are ignored,
+// and the events repeated within the --dedup-window are dropped and counted in the statistics
+// the consecutive events of the same minute are sorted by time and fields before being deduplicated and handled,
+// so the result doesn't depend on the order of the events within a minute
+// stops at the first error of handle and returns it, or when the context is canceled
+// with --strict-schema it also stops at the first event with unknown fields,
+// and with --require-sorted at the first event before a previous one
+// with --max-events the read ends after that number of valid events, like at the end of the input
+func readEvents(ctx context.Context, reader io.Reader, options options, statistics *EventsStatistics, handle func(inputEvent) error) error {
+	var deduplicator = newEventsDeduplicator(options.dedupWindow, options.dedupeBy)
+
+	// the latest event read, to check the order of the input with --require-sorted
+	var latestTime time.Time
+	var latestTimestamp string
+
+	// the number of valid events read, to stop at --max-events
+	var validEvents int
+
+	// the events of the minute being read
+	var minute time.Time
+	var minuteEvents []inputEvent
+
+	// function to sort the events of the minute and handle them
+	flushMinute := func() error {
+		slices.SortStableFunc(minuteEvents, compareInputEvents)
+
+		for _, event := range minuteEvents {
+			if options.dedupWindow > 0 && deduplicator.isDuplicate(event.deliveredTranslation, event.eventTime) {
+				statistics.Duplicate_events++
+				continue
+			}
+			if event.deliveredTranslation.durationMissing {
+				statistics.Missing_durations++
+			}
+			if event.deliveredTranslation.durationClamped {
+				statistics.Clamped_durations++
+			}
+
+			if err := handle(event); err != nil {
+				return err
+			}
+		}
+
+		minuteEvents = minuteEvents[:0]
+		return nil
+	}
+
+	// the lines are parsed, in parallel with --parse-workers, and the events are handled in the order of the input
+	err := parseLines(ctx, reader, options, func(parsed parsedLine) error {
+		// the events with unknown fields stop the read, even without a valid timestamp, they are a mistake of the producer
+		// the lines that are not valid JSON don't have a schema error, they are skipped like without --strict-schema
+		if parsed.schemaErr != nil {
+			return fmt.Errorf("line %d: %w", parsed.lineNumber, parsed.schemaErr)
+		}
+
+		// lines that are not valid are skipped
+		if parsed.err != nil || (options.noFutureMinutes && parsed.eventTime.After(options.clock())) {
+			statistics.Skipped_lines++
+			options.metrics.addSkippedLine()
+			return nil
+		}
+
+		// the lines after the last event of --max-events are not read, the events before it are still handled
+		if options.maxEvents > 0 && validEvents == options.maxEvents {
+			return errMaxEvents
+		}
+		validEvents++
+
+		// the order is checked in the input, before the events of the minute are sorted and filtered
+		if options.requireSorted {
+			if parsed.eventTime.Before(latestTime) {
+				return fmt.Errorf("line %d: the timestamp %s is before the timestamp %s of a previous event, the input is not sorted", parsed.lineNumber, parsed.deliveredTranslation.Timestamp, latestTimestamp)
+			}
+			latestTime, latestTimestamp = parsed.eventTime, parsed.deliveredTranslation.Timestamp
+		}
+
+		var deliveredTranslation = parsed.deliveredTranslation
+		if !options.clients.includes(deliveredTranslation.Client_name) {
+			return nil
+		}
+		if options.group != nil && *options.group != groupOf(deliveredTranslation, options.groupBy) {
+			return nil
+		}
+		if options.businessHours != nil && !options.businessHours.contains(parsed.eventTime) {
+			return nil
+		}
+
+		// the zone of the output is the offset of the first event used (--retain-input-tz)
+		options.inputZone.set(deliveredTranslation.Timestamp)
+
+		// the durations in other units are converted, so the events are compared and aggregated in the same unit
+		// and then the outliers are clamped (--max-duration)
+		deliveredTranslation.normalizeDuration(options.durationUnit)
+		deliveredTranslation.clampDuration(options.maxDuration)
+
+		// an event of another minute ends the events of the minute being read
+		if eventMinuteStart := parsed.eventTime.Truncate(time.Minute); !eventMinuteStart.Equal(minute) {
+			if err := flushMinute(); err != nil {
+				return err
+			}
+			minute = eventMinuteStart
+		}
+
+		// the event is counted when it is read, it is handled when its minute ends
+		options.metrics.addEvent()
+		minuteEvents = append(minuteEvents, inputEvent{deliveredTranslation: deliveredTranslation, eventTime: parsed.eventTime, lineNumber: parsed.lineNumber})
+		return nil
+	})
+	if err != nil && !errors.Is(err, errMaxEvents) {
+		return err
+	}
+
+	// the events of an interrupted read are not handled
+	if ctx.Err() != nil {
+		return nil
+	}
+
+	return flushMinute()
+}
+
+// function to compare two events by time, and by their fields if they have the same time
+func compareInputEvents(a, b inputEvent) int {
+	if c := a.eventTime.Compare(b.eventTime); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(a.deliveredTranslation.Client_name, b.deliveredTranslation.Client_name); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(a.deliveredTranslation.Source_language, b.deliveredTranslation.Source_language); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(a.deliveredTranslation.Target_language, b.deliveredTranslation.Target_language); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(a.deliveredTranslation.Event_name, b.deliveredTranslation.Event_name); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(a.deliveredTranslation.Stream_id, b.deliveredTranslation.Stream_id); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.deliveredTranslation.Duration, b.deliveredTranslation.Duration)
+}
+
+// function to concatenate several readers of events into one, read one after the other
+// a reader that doesn't end with a line break is followed by one, so its last event is not joined with the next reader
+// the events are read as one input, like the lines of a single file
+func ConcatReaders(readers []io.Reader) io.Reader {
+	var terminated = make([]io.Reader, len(readers))
+	for i, reader := range readers {
+		terminated[i] = &lineTerminatedReader{reader: reader}
+	}
+
+	return io.MultiReader(terminated...)
+}
+
+// struct with a reader that always ends with a line break, adding one at the end if it is missing
+type lineTerminatedReader struct {
+	reader   io.Reader
+	lastByte byte
+}
+
+// function to read from the reader, and the missing line break after its end
+func (reader *lineTerminatedReader) Read(buffer []byte) (int, error) {
+	n, err := reader.reader.Read(buffer)
+	if n > 0 {
+		reader.lastByte = buffer[n-1]
+	}
+
+	// an empty reader or one that ends with a line break doesn't need another one
+	if err == io.EOF && reader.lastByte != 0 && reader.lastByte != '\n' {
+		reader.reader = strings.NewReader("\n")
+		return n, nil
+	}
+
+	return n, err
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+func Test_main_SameMinuteOrder(t *testing.T) {
+
+	// the events A, B and C are identical 4 seconds apart, with a 5 seconds window only A and C are kept if sorted
+	// read in the order B, A, C the event B would be kept, and the events A and C dropped
+	a := `{"timestamp": "2018-12-26 18:11:08","client_name": "airliberty","source_language": "en","target_language": "fr","event_name": "translation_delivered","duration": 20}`
+	b := `{"timestamp": "2018-12-26 18:11:12","client_name": "airliberty","source_language": "en","target_language": "fr","event_name": "translation_delivered","duration": 20}`
+	c := `{"timestamp": "2018-12-26 18:11:16","client_name": "airliberty","source_language": "en","target_language": "fr","event_name": "translation_delivered","duration": 20}`
+	d := `{"timestamp": "2018-12-26 18:11:16","client_name": "taxi-eats","source_language": "en","target_language": "fr","event_name": "translation_delivered","duration": 50}`
+	e := `{"timestamp": "2018-12-26 18:11:16","client_name": "airliberty","source_language": "en","target_language": "de","event_name": "translation_delivered","duration": 35}`
+	last := `{"timestamp": "2018-12-26 18:13:02","client_name": "airliberty","source_language": "en","target_language": "fr","event_name": "translation_delivered","duration": 31}`
+
+	orders := [][]string{
+		{a, b, c, d, e, last},
+		{b, a, c, e, d, last},
+		{e, d, c, b, a, last},
+		{d, b, e, a, c, last},
+	}
+
+	inputFilePaths := make([]string, len(orders))
+	for i, order := range orders {
+		inputFilePaths[i] = filepath.Join(t.TempDir(), "events.json")
+		if err := os.WriteFile(inputFilePaths[i], []byte(strings.Join(order, "\n")+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, arguments := range [][]string{
+		{"--dedup-window=5s", "--top-n-clients=2"},
+		{"--dedup-window=5s", "--assume-sorted", "--top-n-clients=2"},
+		{"--dedup-window=5s", "--client=airliberty", "--explode", "--metric=median"},
+		{"--dedup-window=5s", "--bucket=10s", "--with-throughput"},
+	} {
+		arguments := arguments
+		t.Run(strings.Join(arguments, " "), func(t *testing.T) {
+			t.Parallel()
+
+			expectedConsole, expectedStderr := getConsoleAndStderr(t, append(arguments, "--input_file="+inputFilePaths[0])...)
+			for i, inputFilePath := range inputFilePaths[1:] {
+				console, stderr := getConsoleAndStderr(t, append(arguments, "--input_file="+inputFilePath)...)
+				if console != expectedConsole || stderr != expectedStderr {
+					t.Errorf("Expected the same output for the order %d, got %q and %q instead of %q and %q", i+1, console, stderr, expectedConsole, expectedStderr)
+				}
+			}
+		})
+	}
+
+	// the event B is the duplicate, (20 + 20 + 50 + 35) / 1 minute with deliveries
+	data := getContentFromConsole(t, "--dedup-window=5s", "--input_file="+inputFilePaths[1])
+	if len(data) < 2 || data[1].Average_delivery_time != 125 {
+		t.Errorf("Expected an average of 125 at 18:12, got %v", data)
+	}
+}
+
+func Test_ConcatReaders(t *testing.T) {
+
+	// the first reader doesn't end with a line break, the second one does
+	first := `{"timestamp": "2018-12-26 18:11:08","duration": 20}
+{"timestamp": "2018-12-26 18:15:19","duration": 31}`
+	second := `{"timestamp": "2018-12-26 18:15:40","duration": 40}
+{"timestamp": "2018-12-26 18:23:19","duration": 54}
+`
+
+	data, firstMinute, lastMinute, statistics, err := readTranslationsAndProcessData(ConcatReaders([]io.Reader{strings.NewReader(first), strings.NewReader(second)}), options{bucket: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if statistics.Total_events != 4 || statistics.Skipped_lines != 0 {
+		t.Errorf("Expected 4 events and no skipped lines, got %d and %d", statistics.Total_events, statistics.Skipped_lines)
+	}
+
+	// the merged events are aggregated like the events of a single input
+	expectedData, expectedFirstMinute, expectedLastMinute, _, err := readTranslationsAndProcessData(strings.NewReader(first+"\n"+second), options{bucket: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(data, expectedData) || !firstMinute.Equal(expectedFirstMinute) || !lastMinute.Equal(expectedLastMinute) {
+		t.Errorf("Expected %v from %s to %s, got %v from %s to %s", expectedData, expectedFirstMinute, expectedLastMinute, data, firstMinute, lastMinute)
+	}
+	if minute := data["2018-12-26 18:16:00"]; minute.Duration != 71 || minute.Count != 2 {
+		t.Errorf("Expected the events of both readers in the minute 18:16, got %v", minute)
+	}
+
+	// the line break is added even when the reader is read one byte at a time, and an empty reader adds nothing
+	content, err := io.ReadAll(ConcatReaders([]io.Reader{iotest.OneByteReader(strings.NewReader("a")), strings.NewReader(""), strings.NewReader("b\n")}))
+	if err != nil || string(content) != "a\nb\n" {
+		t.Errorf("Expected %q, got %q and %v", "a\nb\n", content, err)
+	}
+}
+
+func Test_main_MaxEvents(t *testing.T) {
+
+	events, err := os.ReadFile("./events.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.SplitAfter(string(events), "\n")
+
+	// the first two events of the sample file, with a line that is not an event between them, before the other events
+	var directory = t.TempDir()
+	inputFilePath := filepath.Join(directory, "events.json")
+	if err := os.WriteFile(inputFilePath, []byte(lines[0]+"not an event\n"+strings.Join(lines[1:], "")), 0644); err != nil {
+		t.Fatal(err)
+	}
+	firstEventsPath := filepath.Join(directory, "first-events.json")
+	if err := os.WriteFile(firstEventsPath, []byte(lines[0]+lines[1]), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// only the first two events are aggregated, the line that is not an event is not counted
+	expected, _, _ := runWithOutputs("--input_file=" + firstEventsPath)
+	for _, arguments := range [][]string{{"--max-events=2"}, {"--max-events=2", "--parse-workers=4"}, {"--max-events=2", "--assume-sorted"}} {
+		console, _, err := runWithOutputs(append(arguments, "--input_file="+inputFilePath)...)
+		if err != nil || console != expected {
+			t.Errorf("Expected the output of the first two events with %v, got %q and %v", arguments, console, err)
+		}
+	}
+
+	// a maximum above the number of events reads the whole file
+	expected, _, _ = runWithOutputs("--input_file=" + inputFilePath)
+	if console, _, err := runWithOutputs("--input_file="+inputFilePath, "--max-events=100"); err != nil || console != expected {
+		t.Errorf("Expected the output of every event, got %q and %v", console, err)
+	}
+
+	if _, _, err := runWithOutputs("--input_file="+inputFilePath, "--max-events=-1"); err == nil {
+		t.Errorf("Expected an error with a negative maximum")
+	}
+}
+
+func Test_main_MaxDuration(t *testing.T) {
+
+	// the second event is a stuck translation with a duration of 10 days
+	var directory = t.TempDir()
+	inputFilePath := filepath.Join(directory, "events.json")
+	if err := os.WriteFile(inputFilePath, []byte(`{"timestamp": "2018-12-26 18:11:08","duration": 20}
+{"timestamp": "2018-12-26 18:12:19","duration": 864000}
+{"timestamp": "2018-12-26 18:14:19","duration": 31}
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	clampedFilePath := filepath.Join(directory, "clamped.json")
+	if err := os.WriteFile(clampedFilePath, []byte(`{"timestamp": "2018-12-26 18:11:08","duration": 20}
+{"timestamp": "2018-12-26 18:12:19","duration": 100}
+{"timestamp": "2018-12-26 18:14:19","duration": 31}
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// the extreme duration is aggregated as the maximum, in the files and in the streams
+	expected, _, _ := runWithOutputs("--input_file=" + clampedFilePath)
+	for _, arguments := range [][]string{{"--max-duration=100"}, {"--max-duration=100", "--assume-sorted"}} {
+		console, _, err := runWithOutputs(append(arguments, "--input_file="+inputFilePath)...)
+		if err != nil || console != expected {
+			t.Errorf("Expected the output of the clamped duration with %v, got %q and %v", arguments, console, err)
+		}
+	}
+	if console, _, _ := runWithOutputs("--input_file=" + inputFilePath); console == expected {
+		t.Errorf("Expected the extreme duration to change the output without --max-duration")
+	}
+
+	// the clamped events are counted in the statistics
+	statsFilePath := filepath.Join(directory, "stats.json")
+	if err := run(context.Background(), []string{"--input_file=" + inputFilePath, "--max-duration=100", "--stats-json=" + statsFilePath}, io.Discard, io.Discard); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(statsFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var statistics EventsStatistics
+	if err := json.Unmarshal(content, &statistics); err != nil {
+		t.Fatal(err)
+	}
+	if statistics.Clamped_durations != 1 || statistics.Max_duration != 100 {
+		t.Errorf("Expected 1 clamped duration and the maximum 100, got %+v", statistics)
+	}
+
+	if _, _, err := runWithOutputs("--input_file="+inputFilePath, "--max-duration=-1"); err == nil {
+		t.Errorf("Expected an error with a negative maximum")
+	}
+}
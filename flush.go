@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// struct with when the buffered output is flushed (--autoflush), only one of the fields is set
+// rows: the output is flushed after every number of rows
+// interval: the rows are flushed at most the interval after they are written
+type autoFlush struct {
+	rows     int
+	interval time.Duration
+}
+
+// function to parse the value of the --autoflush flag, like "rows:100" or "duration:1s"
+func parseAutoFlush(value string) (autoFlush, error) {
+	kind, number, _ := strings.Cut(value, ":")
+
+	switch kind {
+	case "rows":
+		rows, err := strconv.Atoi(number)
+		if err == nil && rows > 0 {
+			return autoFlush{rows: rows}, nil
+		}
+	case "duration":
+		interval, err := time.ParseDuration(number)
+		if err == nil && interval > 0 {
+			return autoFlush{interval: interval}, nil
+		}
+	}
+
+	return autoFlush{}, fmt.Errorf("invalid autoflush %q, expected rows:N or duration:D, like rows:100 or duration:1s", value)
+}
+
+// function to check if the flag was set
+func (policy autoFlush) enabled() bool {
+	return policy.rows > 0 || policy.interval > 0
+}
+
+// interface of the buffered writers of the output, like bufio.Writer
+type flushWriter interface {
+	io.Writer
+	Flush() error
+}
+
+// struct that flushes a buffered output with the cadence of --autoflush
+// the printers write one row per write, so the rows are counted as the writes
+// the writes and the flushes are locked, the flushes of the interval are called from the goroutine of a timer
+type autoFlushWriter struct {
+	output flushWriter
+	policy autoFlush
+
+	mutex   sync.Mutex
+	rows    int
+	timer   *time.Timer
+	stopped bool
+}
+
+// function to create a writer that flushes the output with the cadence of the policy
+// stop must be called before the output is closed
+func newAutoFlushWriter(output flushWriter, policy autoFlush) *autoFlushWriter {
+	return &autoFlushWriter{output: output, policy: policy}
+}
+
+// function to write a row to the output and flush it if it is the time
+// with an interval the timer is started by the first row written after a flush
+func (writer *autoFlushWriter) Write(row []byte) (int, error) {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	written, err := writer.output.Write(row)
+	if err != nil {
+		return written, err
+	}
+
+	if writer.policy.rows > 0 {
+		writer.rows++
+		if writer.rows >= writer.policy.rows {
+			writer.rows = 0
+			return written, writer.output.Flush()
+		}
+	}
+
+	if writer.policy.interval > 0 && writer.timer == nil && !writer.stopped {
+		writer.timer = time.AfterFunc(writer.policy.interval, writer.flushInterval)
+	}
+
+	return written, nil
+}
+
+// function called by the timer to flush the rows written since the last flush
+// the write errors are kept by the buffered output and returned when it is closed
+func (writer *autoFlushWriter) flushInterval() {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	writer.timer = nil
+	if !writer.stopped {
+		writer.output.Flush()
+	}
+}
+
+// function to stop the timer of the interval, so the output is not flushed after it is closed
+// the rows not flushed yet are flushed when the output is closed
+func (writer *autoFlushWriter) stop() {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	writer.stopped = true
+	if writer.timer != nil {
+		writer.timer.Stop()
+		writer.timer = nil
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+)
+
+// struct with a buffered output that counts its flushes and the rows flushed
+type flushCountingWriter struct {
+	mutex   sync.Mutex
+	buffer  bytes.Buffer
+	flushed bytes.Buffer
+	flushes int
+}
+
+func (writer *flushCountingWriter) Write(row []byte) (int, error) {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+	return writer.buffer.Write(row)
+}
+
+func (writer *flushCountingWriter) Flush() error {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+	writer.flushes++
+	writer.buffer.WriteTo(&writer.flushed)
+	return nil
+}
+
+// function to get the number of flushes and of the lines flushed
+func (writer *flushCountingWriter) counts() (int, int) {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+	return writer.flushes, bytes.Count(writer.flushed.Bytes(), []byte("\n"))
+}
+
+func Test_autoFlushWriter_Rows(t *testing.T) {
+
+	var output flushCountingWriter
+	var flusher = newAutoFlushWriter(&output, autoFlush{rows: 3})
+	var printer = newValuesPrinter(flusher, options{lineSeparator: "\n"})
+
+	for i := 1; i <= 10; i++ {
+		printer.print(PrintableValues{Date: "2018-12-26 18:11:00"})
+
+		// a flush after every 3 rows, with every row printed until then
+		if flushes, lines := output.counts(); flushes != i/3 || lines != i/3*3 {
+			t.Errorf("Expected %d flushes of %d lines after %d rows, got %d flushes of %d lines", i/3, i/3*3, i, flushes, lines)
+		}
+	}
+	flusher.stop()
+}
+
+func Test_autoFlushWriter_Duration(t *testing.T) {
+
+	var output flushCountingWriter
+	var flusher = newAutoFlushWriter(&output, autoFlush{interval: 50 * time.Millisecond})
+	defer flusher.stop()
+	var printer = newValuesPrinter(flusher, options{lineSeparator: "\n"})
+
+	// the rows are not flushed when they are written, but within the interval
+	printer.print(PrintableValues{Date: "2018-12-26 18:11:00"})
+	printer.print(PrintableValues{Date: "2018-12-26 18:12:00"})
+	if flushes, _ := output.counts(); flushes != 0 {
+		t.Errorf("Expected no flush right after the rows are written, got %d", flushes)
+	}
+
+	var deadline = time.Now().Add(5 * time.Second)
+	for flushes, _ := output.counts(); flushes == 0 && time.Now().Before(deadline); flushes, _ = output.counts() {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if flushes, lines := output.counts(); flushes != 1 || lines != 2 {
+		t.Fatalf("Expected one flush of the 2 rows after the interval, got %d flushes of %d lines", flushes, lines)
+	}
+
+	// without new rows there are no more flushes
+	time.Sleep(120 * time.Millisecond)
+	if flushes, _ := output.counts(); flushes != 1 {
+		t.Errorf("Expected no flush without new rows, got %d flushes", flushes)
+	}
+
+	// a new row starts the next interval, and nothing is flushed after the writer is stopped
+	printer.print(PrintableValues{Date: "2018-12-26 18:13:00"})
+	flusher.stop()
+	time.Sleep(120 * time.Millisecond)
+	if flushes, _ := output.counts(); flushes != 1 {
+		t.Errorf("Expected no flush after the writer is stopped, got %d flushes", flushes)
+	}
+}
+
+func Test_main_AutoFlush(t *testing.T) {
+
+	// the output is the same, only flushed with a different cadence
+	expected := getConsoleOutput(t, "--input_file=./events.json")
+	for _, autoflush := range []string{"rows:1", "rows:4", "duration:1ms"} {
+		if output := getConsoleOutput(t, "--input_file=./events.json", "--autoflush="+autoflush); output != expected {
+			t.Errorf("Expected the same output with --autoflush=%s, got %q", autoflush, output)
+		}
+	}
+
+	for _, value := range []string{"", "rows", "rows:0", "rows:x", "duration:0s", "duration:1", "bytes:10"} {
+		if _, err := parseAutoFlush(value); err == nil {
+			t.Errorf("Expected an error for the autoflush %q", value)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// struct with the daily hours of the events that are used and the minutes that are printed (--business-hours)
+// start and end are the times since midnight, in the zone of --business-hours-tz
+// the hours can cross midnight, like 22:00-06:00, then the start is after the end
+type businessHours struct {
+	start    time.Duration
+	end      time.Duration
+	location *time.Location
+}
+
+// function to parse the value of the --business-hours flag, like "09:00-18:00", the end is not included
+func parseBusinessHours(value string) (*businessHours, error) {
+	var hours = businessHours{location: time.UTC}
+
+	startValue, endValue, found := strings.Cut(value, "-")
+	start, startErr := time.Parse("15:04", startValue)
+	end, endErr := time.Parse("15:04", endValue)
+	if !found || startErr != nil || endErr != nil || start.Equal(end) {
+		return nil, fmt.Errorf("invalid business hours %q, expected two different times like 09:00-18:00", value)
+	}
+
+	hours.start = time.Duration(start.Hour())*time.Hour + time.Duration(start.Minute())*time.Minute
+	hours.end = time.Duration(end.Hour())*time.Hour + time.Duration(end.Minute())*time.Minute
+	return &hours, nil
+}
+
+// function to parse the zone of the --business-hours-tz flag, an IANA name like "Europe/Lisbon" or an offset like "+01:00"
+func parseLocation(value string) (*time.Location, error) {
+	if len(value) == len("+01:00") && (value[0] == '+' || value[0] == '-') && value[3] == ':' {
+		hours, hoursErr := strconv.Atoi(value[1:3])
+		minutes, minutesErr := strconv.Atoi(value[4:])
+		if hoursErr == nil && minutesErr == nil && hours < 24 && minutes < 60 {
+			offset := hours*3600 + minutes*60
+			if value[0] == '-' {
+				offset = -offset
+			}
+			return time.FixedZone(value, offset), nil
+		}
+	}
+
+	location, err := time.LoadLocation(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time zone %q, expected a name like Europe/Lisbon or an offset like +01:00", value)
+	}
+	return location, nil
+}
+
+// function to check if a time is within the business hours, in their zone
+func (hours *businessHours) contains(moment time.Time) bool {
+	moment = moment.In(hours.location)
+	sinceMidnight := time.Duration(moment.Hour())*time.Hour + time.Duration(moment.Minute())*time.Minute + time.Duration(moment.Second())*time.Second
+
+	if hours.start < hours.end {
+		return sinceMidnight >= hours.start && sinceMidnight < hours.end
+	}
+	return sinceMidnight >= hours.start || sinceMidnight < hours.end
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_main_BusinessHours(t *testing.T) {
+
+	// business hours from 23:00 to 01:00 cross midnight, the events at 22:59 and 01:30 are outside them
+	events := strings.Join([]string{
+		`{"timestamp": "2018-12-26 22:59:30.000000","translation_id": "1","source_language": "en","target_language": "fr","client_name": "airliberty","event_name": "translation_delivered","nr_words": 30, "duration": 500}`,
+		`{"timestamp": "2018-12-26 23:30:10.000000","translation_id": "2","source_language": "en","target_language": "fr","client_name": "airliberty","event_name": "translation_delivered","nr_words": 30, "duration": 20}`,
+		`{"timestamp": "2018-12-27 00:30:10.000000","translation_id": "3","source_language": "en","target_language": "fr","client_name": "airliberty","event_name": "translation_delivered","nr_words": 30, "duration": 40}`,
+		`{"timestamp": "2018-12-27 01:30:10.000000","translation_id": "4","source_language": "en","target_language": "fr","client_name": "airliberty","event_name": "translation_delivered","nr_words": 30, "duration": 700}`,
+	}, "\n")
+	inputFilePath := filepath.Join(t.TempDir(), "events.json")
+	if err := os.WriteFile(inputFilePath, []byte(events), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// the range of the output is wider than the business hours, the minutes outside them are not printed
+	var arguments = []string{"--input_file=" + inputFilePath, "--window_size=2", "--range-start=2018-12-26 22:00:00", "--range-end=2018-12-27 02:00:00", "--business-hours=23:00-01:00"}
+	output := getContentFromConsole(t, arguments...)
+	if len(output) != 120 {
+		t.Fatalf("Expected the 120 minutes from 23:01 to 01:00, got %d", len(output))
+	}
+	if output[0].Date != "2018-12-26 23:01:00" || output[len(output)-1].Date != "2018-12-27 01:00:00" {
+		t.Errorf("Expected the minutes from 23:01 to 01:00, got %s to %s", output[0].Date, output[len(output)-1].Date)
+	}
+	for _, minute := range output {
+		if minute.Average_delivery_time != 0 && minute.Average_delivery_time != 20 && minute.Average_delivery_time != 40 {
+			t.Errorf("Expected the events outside the business hours to be ignored, got %v at %s", minute.Average_delivery_time, minute.Date)
+		}
+	}
+
+	// in the +01:00 zone the same hours are from 22:00 to 00:00 in UTC, the event at 22:59 is used and the one at 00:30 is not
+	output = getContentFromConsole(t, append(arguments, "--business-hours-tz=+01:00")...)
+	if len(output) != 120 || output[0].Date != "2018-12-26 22:01:00" || output[len(output)-1].Date != "2018-12-27 00:00:00" {
+		t.Fatalf("Expected the minutes from 22:01 to 00:00 with the +01:00 zone, got %v", output)
+	}
+	var averages = map[float64]bool{}
+	for _, minute := range output {
+		averages[minute.Average_delivery_time] = true
+	}
+	if !averages[500] || averages[40] {
+		t.Errorf("Expected the event at 22:59 and not the one at 00:30 with the +01:00 zone, got the averages %v", averages)
+	}
+}
+
+func Test_parseBusinessHours(t *testing.T) {
+
+	for _, value := range []string{"", "09:00", "09:00-09:00", "9-18", "25:00-18:00", "09:00-18:60"} {
+		if _, err := parseBusinessHours(value); err == nil {
+			t.Errorf("Expected an error for the business hours %q", value)
+		}
+	}
+
+	hours, err := parseBusinessHours("22:00-06:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for moment, expected := range map[string]bool{"21:59": false, "22:00": true, "23:59": true, "00:00": true, "05:59": true, "06:00": false, "12:00": false} {
+		parsed, _ := time.Parse("15:04", moment)
+		if hours.contains(parsed) != expected {
+			t.Errorf("Expected %v for %s within 22:00-06:00", expected, moment)
+		}
+	}
+
+	if _, err := parseFlags([]string{"--business-hours=09:00-18:00", "--business-hours-tz=Nowhere/Unknown"}); err == nil {
+		t.Error("Expected an error for an unknown time zone")
+	}
+}
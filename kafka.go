@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// struct with a message consumed from Kafka
+// the value has one event, in the same format as the lines of the input file
+type kafkaMessage struct {
+	Partition int
+	Offset    int64
+	Value     []byte
+}
+
+// interface of the Kafka consumer used by the --kafka flag
+// it is implemented by the Kafka client in kafka_client.go, only built with the kafka build tag,
+// and can be replaced in the tests
+type kafkaConsumer interface {
+	// returns the next message, blocking until there is one or the context is canceled
+	Fetch(ctx context.Context) (kafkaMessage, error)
+
+	// commits the offsets of the messages, so they are not consumed again by the group
+	Commit(ctx context.Context, messages ...kafkaMessage) error
+
+	Close() error
+}
+
+// function to create the Kafka consumer, set by kafka_client.go when built with the kafka build tag
+var newKafkaConsumer func(brokers []string, topic string, group string) (kafkaConsumer, error)
+
+// function to parse the value of the --kafka flag, with the brokers, the topic and the group
+// the brokers are separated by "+" because the parts of the value are separated by commas
+func parseKafkaSource(value string) (brokers []string, topic string, group string, err error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return nil, "", "", fmt.Errorf("invalid kafka source %q, expected brokers,topic,group", value)
+	}
+
+	return strings.Split(parts[0], "+"), parts[1], parts[2], nil
+}
+
+// function to consume the events of a Kafka topic and stream their moving averages to the output
+// runs until the context is canceled
+func consumeKafkaAndStream(ctx context.Context, options options, stdout io.Writer) error {
+	if newKafkaConsumer == nil {
+		return errors.New("--kafka is not available, the program must be built with the kafka build tag (go build -tags kafka)")
+	}
+
+	brokers, topic, group, err := parseKafkaSource(options.kafka)
+	if err != nil {
+		return err
+	}
+
+	consumer, err := newKafkaConsumer(brokers, topic, group)
+	if err != nil {
+		return err
+	}
+	defer consumer.Close()
+
+	output, closeOutput, err := createOutputWriter(stdout, options.outputFilePath, options.gzipOutput, nil)
+	if err != nil {
+		return err
+	}
+
+	// the minutes are flushed right away, or with the cadence of --autoflush
+	var rowsOutput io.Writer = output
+	var flusher *autoFlushWriter
+	if options.autoFlush.enabled() {
+		flusher = newAutoFlushWriter(output, options.autoFlush)
+		rowsOutput = flusher
+	}
+
+	var printer = newValuesPrinter(rowsOutput, options)
+	err = consumeKafka(ctx, consumer, options, func(printableValues PrintableValues) {
+		printer.print(printableValues)
+		if flusher == nil {
+			output.Flush()
+		}
+	})
+
+	printer.finish()
+	if flusher != nil {
+		flusher.stop()
+	}
+	if closeError := closeOutput(); closeError != nil {
+		return closeError
+	}
+
+	return err
+}
+
+// function to consume the messages of the consumer and calculate the moving averages as they arrive
+// the offsets of the messages are committed after their minutes are complete and printed,
+// so if the program stops the messages of the incomplete minute are consumed again
+func consumeKafka(ctx context.Context, consumer kafkaConsumer, options options, emit func(PrintableValues)) error {
+	var stream = newEventsStream(options, emit)
+
+	// messages of the current minute, not committed yet
+	var pending []kafkaMessage
+
+	for {
+		message, err := consumer.Fetch(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+
+		// the minutes completed by the message don't include it, only the pending messages before it
+		if stream.addLine(message.Value) && len(pending) > 0 {
+			if err := consumer.Commit(ctx, pending...); err != nil {
+				return err
+			}
+			pending = pending[:0]
+		}
+
+		pending = append(pending, message)
+	}
+}
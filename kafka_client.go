@@ -0,0 +1,49 @@
+//go:build kafka
+
+package main
+
+import (
+	"context"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func init() {
+	newKafkaConsumer = func(brokers []string, topic string, group string) (kafkaConsumer, error) {
+		reader := kafka.NewReader(kafka.ReaderConfig{
+			Brokers: brokers,
+			Topic:   topic,
+			GroupID: group,
+		})
+
+		return &kafkaReaderConsumer{reader: reader}, nil
+	}
+}
+
+// Kafka consumer implemented with the reader of the kafka-go client
+// the offsets are committed explicitly, after the minutes of the messages are complete
+type kafkaReaderConsumer struct {
+	reader *kafka.Reader
+}
+
+func (consumer *kafkaReaderConsumer) Fetch(ctx context.Context) (kafkaMessage, error) {
+	message, err := consumer.reader.FetchMessage(ctx)
+	if err != nil {
+		return kafkaMessage{}, err
+	}
+
+	return kafkaMessage{Partition: message.Partition, Offset: message.Offset, Value: message.Value}, nil
+}
+
+func (consumer *kafkaReaderConsumer) Commit(ctx context.Context, messages ...kafkaMessage) error {
+	var kafkaMessages = make([]kafka.Message, len(messages))
+	for i, message := range messages {
+		kafkaMessages[i] = kafka.Message{Topic: consumer.reader.Config().Topic, Partition: message.Partition, Offset: message.Offset}
+	}
+
+	return consumer.reader.CommitMessages(ctx, kafkaMessages...)
+}
+
+func (consumer *kafkaReaderConsumer) Close() error {
+	return consumer.reader.Close()
+}
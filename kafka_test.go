@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// consumer that returns the messages of a list and records the committed ones
+// cancels the context when there are no more messages
+type mockKafkaConsumer struct {
+	messages  []kafkaMessage
+	committed []kafkaMessage
+	cancel    context.CancelFunc
+}
+
+func (consumer *mockKafkaConsumer) Fetch(ctx context.Context) (kafkaMessage, error) {
+	if len(consumer.messages) == 0 {
+		consumer.cancel()
+		return kafkaMessage{}, ctx.Err()
+	}
+
+	message := consumer.messages[0]
+	consumer.messages = consumer.messages[1:]
+	return message, nil
+}
+
+func (consumer *mockKafkaConsumer) Commit(ctx context.Context, messages ...kafkaMessage) error {
+	consumer.committed = append(consumer.committed, messages...)
+	return nil
+}
+
+func (consumer *mockKafkaConsumer) Close() error {
+	return nil
+}
+
+func Test_consumeKafka(t *testing.T) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	consumer := &mockKafkaConsumer{
+		cancel: cancel,
+		messages: []kafkaMessage{
+			{Offset: 0, Value: []byte(`{"timestamp": "2018-12-26 18:11:08.509654","duration": 20}`)},
+			{Offset: 1, Value: []byte(`{"timestamp": "2018-12-26 18:11:38.509654","duration": 10}`)},
+			{Offset: 2, Value: []byte(`{"timestamp": "2018-12-26 18:15:19.903159","duration": 31}`)},
+			{Offset: 3, Value: []byte(`{"timestamp": "2018-12-26 18:15:49.903159","duration": 14}`)},
+		},
+	}
+
+	options, _ := parseFlags(nil)
+	var data []PrintableValues
+
+	err := consumeKafka(ctx, consumer, options, func(printableValues PrintableValues) {
+		data = append(data, printableValues)
+	})
+
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the consumer to stop when canceled, got %v", err)
+	}
+
+	// the minutes up to 18:15 are complete, the minute 18:16 is still receiving events
+	expected := []PrintableValues{
+		{Date: "2018-12-26 18:11:00", Average_delivery_time: 0},
+		{Date: "2018-12-26 18:12:00", Average_delivery_time: 30},
+		{Date: "2018-12-26 18:13:00", Average_delivery_time: 30},
+		{Date: "2018-12-26 18:14:00", Average_delivery_time: 30},
+		{Date: "2018-12-26 18:15:00", Average_delivery_time: 30},
+	}
+
+	if len(data) != len(expected) {
+		t.Fatalf("Expected %d minutes, got %v", len(expected), data)
+	}
+	for i := range expected {
+		if data[i] != expected[i] {
+			t.Errorf("Expected minute %d to be %v, got %v", i, expected[i], data[i])
+		}
+	}
+
+	// only the messages of the complete minutes are committed
+	if len(consumer.committed) != 2 || consumer.committed[0].Offset != 0 || consumer.committed[1].Offset != 1 {
+		t.Errorf("Expected the offsets 0 and 1 to be committed, got %v", consumer.committed)
+	}
+}
+
+func Test_parseKafkaSource(t *testing.T) {
+
+	brokers, topic, group, err := parseKafkaSource("kafka1:9092+kafka2:9092,events,go-challenge")
+
+	if err != nil || len(brokers) != 2 || brokers[1] != "kafka2:9092" || topic != "events" || group != "go-challenge" {
+		t.Errorf("Expected two brokers, the events topic and the go-challenge group, got %v %s %s %v", brokers, topic, group, err)
+	}
+
+	if _, _, _, err := parseKafkaSource("kafka1:9092,events"); err == nil {
+		t.Errorf("Expected an error without the group")
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"os"
+	"time"
+)
+
+// function to read the input files and merge the minutes of their events into one series
+// with one file it is the same as readTranslationsFileAndProcessData
+// the files are read one at a time, each into its own minutes, and the minutes of the same date are merged with --overlap-policy
+// the statistics have the events of every file, even the ones of the minutes that are not kept by the policy
+func readInputFilesAndProcessData(options options) (map[string]MinuteDeliveries, time.Time, time.Time, EventsStatistics, error) {
+	if len(options.inputFilePaths) <= 1 {
+		return readTranslationsFileAndProcessData(options.inputFilePath, options)
+	}
+
+	var statistics = EventsStatistics{clientDeliveries: make(map[string]int)}
+	var merged = newSeriesMinutes()
+
+	for _, inputFilePath := range options.inputFilePaths {
+		file, err := os.Open(inputFilePath)
+		if err != nil {
+			return nil, time.Time{}, time.Time{}, statistics, err
+		}
+
+		var series = newSeriesMinutes()
+		err = readEvents(context.Background(), file, options, &statistics, func(event inputEvent) error {
+			statistics.add(series.add(event, options), event.deliveredTranslation.Client_name)
+			return nil
+		})
+		file.Close()
+		if err != nil {
+			return nil, time.Time{}, time.Time{}, statistics, err
+		}
+
+		merged.merge(series, options.overlapPolicy)
+	}
+
+	return merged.numberTranslationsPerMinuteUTC, merged.firstMinute, merged.lastMinute, statistics, nil
+}
+
+// function to merge the minutes of another series into the series
+// with the "sum" policy the deliveries of the same minute are added, with "first" the minute of the series is kept,
+// and with "last" it is replaced by the minute of the other series
+// the range is from the earliest first minute to the latest last minute of the two series
+func (series *seriesMinutes) merge(other *seriesMinutes, overlapPolicy string) {
+	for date, minuteDeliveries := range other.numberTranslationsPerMinuteUTC {
+		existing, found := series.numberTranslationsPerMinuteUTC[date]
+
+		switch {
+		case !found || overlapPolicy == "last":
+			series.numberTranslationsPerMinuteUTC[date] = minuteDeliveries
+		case overlapPolicy == "sum":
+			existing.Duration += minuteDeliveries.Duration
+			existing.Count += minuteDeliveries.Count
+			existing.Durations = append(existing.Durations, minuteDeliveries.Durations...)
+			series.numberTranslationsPerMinuteUTC[date] = existing
+		}
+	}
+
+	if other.firstMinute.IsZero() {
+		return
+	}
+	if series.firstMinute.IsZero() || other.firstMinute.Before(series.firstMinute) {
+		series.firstMinute = other.firstMinute
+	}
+	if other.lastMinute.After(series.lastMinute) {
+		series.lastMinute = other.lastMinute
+	}
+}
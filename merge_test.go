@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_main_OverlapPolicy(t *testing.T) {
+
+	// two exports with the minute of 18:16 in both
+	var directory = t.TempDir()
+	firstFilePath := filepath.Join(directory, "first.json")
+	secondFilePath := filepath.Join(directory, "second.json")
+	if err := os.WriteFile(firstFilePath, []byte(`{"timestamp": "2018-12-26 18:11:08","duration": 20}
+{"timestamp": "2018-12-26 18:15:19","duration": 31}
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(secondFilePath, []byte(`{"timestamp": "2018-12-26 18:15:40","duration": 60}
+{"timestamp": "2018-12-26 18:23:19","duration": 54}
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// the minutes of only one file are the same with every policy, the overlapping minute depends on it
+	for overlapPolicy, overlap := range map[string]RawMinute{
+		"sum":   {Date: "2018-12-26 18:16:00", Sum_duration: 91, Count: 2},
+		"first": {Date: "2018-12-26 18:16:00", Sum_duration: 31, Count: 1},
+		"last":  {Date: "2018-12-26 18:16:00", Sum_duration: 60, Count: 1},
+	} {
+		expected := []RawMinute{{Date: "2018-12-26 18:12:00", Sum_duration: 20, Count: 1}, overlap, {Date: "2018-12-26 18:24:00", Sum_duration: 54, Count: 1}}
+
+		var rawMinutes []RawMinute
+		output := getConsoleOutput(t, "--input_file="+firstFilePath, "--input_file="+secondFilePath, "--overlap-policy="+overlapPolicy, "--raw")
+		for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+			var rawMinute RawMinute
+			if err := json.Unmarshal([]byte(line), &rawMinute); err != nil {
+				t.Fatal(err)
+			}
+			rawMinutes = append(rawMinutes, rawMinute)
+		}
+
+		if !reflect.DeepEqual(rawMinutes, expected) {
+			t.Errorf("Expected the minutes %+v with --overlap-policy=%s, got %+v", expected, overlapPolicy, rawMinutes)
+		}
+	}
+
+	// the range of the series is from the earliest to the latest minute of the files, in any order
+	averages := getContentFromConsole(t, "--input_file="+secondFilePath, "--input_file="+firstFilePath, "--overlap-policy=first")
+	if len(averages) != 14 || averages[0].Date != "2018-12-26 18:11:00" || averages[len(averages)-1].Date != "2018-12-26 18:24:00" {
+		t.Fatalf("Expected the minutes from 18:11 to 18:24, got %+v", averages)
+	}
+	// the first file of the flags is the second export, so its delivery of 60 is kept
+	if averages[5].Average_delivery_time != 40 {
+		t.Errorf("Expected the average of 20 and 60 at 18:16, got %v", averages[5].Average_delivery_time)
+	}
+
+	for _, arguments := range [][]string{{"--overlap-policy=max"}, {"--input_file=" + secondFilePath, "--assume-sorted"}} {
+		if err := run(context.Background(), append([]string{"--input_file=" + firstFilePath}, arguments...), io.Discard, io.Discard); err == nil {
+			t.Errorf("Expected an error with %v", arguments)
+		}
+	}
+}
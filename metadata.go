@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// struct with the metadata line of a self-describing input, like {"window_size": 15} (--read-window-from-input)
+// the events don't have a window_size field, so a line with it is the metadata and not an event
+type inputMetadata struct {
+	Window_size *json.Number `json:"window_size"`
+}
+
+// function to read the window size of the metadata line, the first line of the input file
+// returns false if the first line is not a metadata line, and an error if its window size is not valid
+func readInputMetadata(inputFilePath string) (uint, bool, error) {
+	file, err := os.Open(inputFilePath)
+	if err != nil {
+		return 0, false, err
+	}
+	defer file.Close()
+
+	var scanner = bufio.NewScanner(file)
+	if !scanner.Scan() {
+		return 0, false, scanner.Err()
+	}
+
+	var metadata inputMetadata
+	if err := json.Unmarshal(scanner.Bytes(), &metadata); err != nil || metadata.Window_size == nil {
+		return 0, false, nil
+	}
+
+	windowSize, err := metadata.Window_size.Int64()
+	if err != nil || windowSize < 0 {
+		return 0, false, fmt.Errorf("invalid window size %s in the first line of %s, expected an integer greater or equal to 0", *metadata.Window_size, inputFilePath)
+	}
+	return uint(windowSize), true, nil
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// struct with the counters of a run, printed periodically with the --metrics-interval flag
+// the counters are updated while reading and printing, and read by the goroutine that prints them
+// the skipped lines, the connections and the requests are only served with --metrics-addr
+type runMetrics struct {
+	events       atomic.Int64
+	rows         atomic.Int64
+	skippedLines atomic.Int64
+
+	// the connections closed and the sum of their durations, in nanoseconds
+	connections         atomic.Int64
+	connectionsDuration atomic.Int64
+
+	// the HTTP requests served with --serve and the sum of their durations, in nanoseconds
+	requests         atomic.Int64
+	requestsDuration atomic.Int64
+}
+
+// function to count an event read, does nothing without metrics
+func (metrics *runMetrics) addEvent() {
+	if metrics != nil {
+		metrics.events.Add(1)
+	}
+}
+
+// function to count a row printed, does nothing without metrics
+func (metrics *runMetrics) addRow() {
+	if metrics != nil {
+		metrics.rows.Add(1)
+	}
+}
+
+// function to count a line that is not a valid event, does nothing without metrics
+func (metrics *runMetrics) addSkippedLine() {
+	if metrics != nil {
+		metrics.skippedLines.Add(1)
+	}
+}
+
+// function to count a closed connection and its duration, does nothing without metrics
+func (metrics *runMetrics) addConnection(duration time.Duration) {
+	if metrics != nil {
+		metrics.connections.Add(1)
+		metrics.connectionsDuration.Add(int64(duration))
+	}
+}
+
+// function to count a served HTTP request and its duration, does nothing without metrics
+func (metrics *runMetrics) addRequest(duration time.Duration) {
+	if metrics != nil {
+		metrics.requests.Add(1)
+		metrics.requestsDuration.Add(int64(duration))
+	}
+}
+
+// function to write the counters in the text format of Prometheus
+// the durations of the connections and the requests are summaries without quantiles, the sum and the count
+func writePrometheusMetrics(writer io.Writer, metrics *runMetrics) {
+	for _, counter := range []struct {
+		name, help string
+		value      int64
+	}{
+		{"translations_events_total", "Events read and used in the calculations.", metrics.events.Load()},
+		{"translations_skipped_lines_total", "Lines skipped because they are not valid events.", metrics.skippedLines.Load()},
+		{"translations_rows_total", "Rows emitted to the output.", metrics.rows.Load()},
+	} {
+		fmt.Fprintf(writer, "# HELP %s %s\n# TYPE %s counter\n%s %d\n", counter.name, counter.help, counter.name, counter.name, counter.value)
+	}
+
+	for _, summary := range []struct {
+		name, help string
+		count, sum int64
+	}{
+		{"translations_connection_duration_seconds", "Duration of the TCP connections closed.", metrics.connections.Load(), metrics.connectionsDuration.Load()},
+		{"translations_request_duration_seconds", "Duration of the HTTP requests served.", metrics.requests.Load(), metrics.requestsDuration.Load()},
+	} {
+		fmt.Fprintf(writer, "# HELP %s %s\n# TYPE %s summary\n", summary.name, summary.help, summary.name)
+		fmt.Fprintf(writer, "%s_sum %g\n%s_count %d\n", summary.name, time.Duration(summary.sum).Seconds(), summary.name, summary.count)
+	}
+}
+
+// function to create the handler of the /metrics path, with the counters in the text format of Prometheus
+func newMetricsHandler(metrics *runMetrics) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		writePrometheusMetrics(writer, metrics)
+	})
+}
+
+// function to serve the counters in the /metrics path of the listener (--metrics-addr), until the returned function is called
+// the returned function closes the listener and the open requests
+func serveMetrics(listener net.Listener, metrics *runMetrics) func() {
+	var mux = http.NewServeMux()
+	mux.Handle("/metrics", newMetricsHandler(metrics))
+
+	var server = &http.Server{Handler: mux}
+	go server.Serve(listener)
+
+	return func() {
+		server.Close()
+	}
+}
+
+// function to print the counters of the run to stderr every interval, until the returned function is called
+// the events per second are calculated over the last interval
+// the returned function waits for the goroutine, so nothing is printed after it returns
+func startMetricsReporter(metrics *runMetrics, interval time.Duration, stderr io.Writer) func() {
+	var done = make(chan struct{})
+	var stopped sync.WaitGroup
+
+	stopped.Add(1)
+	go func() {
+		defer stopped.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		var previousEvents int64
+		var previousTick = time.Now()
+
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				events := metrics.events.Load()
+				perSecond := float64(events-previousEvents) / now.Sub(previousTick).Seconds()
+				fmt.Fprintf(stderr, "metrics: %d events (%.1f events/s), %d rows emitted\n", events, perSecond, metrics.rows.Load())
+
+				previousEvents, previousTick = events, now
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		stopped.Wait()
+	}
+}
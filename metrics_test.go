@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_main_MetricsInterval(t *testing.T) {
+
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	// the events are read from a pipe that is written slowly
+	var stderr syncBuffer
+	finished := make(chan error)
+	go func() {
+		finished <- run(context.Background(), []string{"--input_file=" + fmt.Sprintf("/dev/fd/%d", reader.Fd()), "--assume-sorted", "--metrics-interval=10ms"}, io.Discard, &stderr)
+	}()
+
+	io.WriteString(writer, `{"timestamp": "2018-12-26 18:11:08","duration": 20}`+"\n")
+	time.Sleep(100 * time.Millisecond)
+	io.WriteString(writer, `{"timestamp": "2018-12-26 18:15:19","duration": 31}`+"\n")
+	writer.Close()
+
+	if err := <-finished; err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(stderr.String(), "metrics: 1 events") {
+		t.Errorf("Expected a metrics line while waiting for the second event, got %q", stderr.String())
+	}
+
+	// nothing is printed without the flag
+	stderr = syncBuffer{}
+	if err := run(context.Background(), []string{"--input_file=./events.json"}, io.Discard, &stderr); err != nil || stderr.String() != "" {
+		t.Errorf("Expected no metrics without --metrics-interval, got %q and %v", stderr.String(), err)
+	}
+}
+
+func Test_serveMetrics(t *testing.T) {
+
+	metricsListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	eventsListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	options, err := parseFlags([]string{"--window_size=10"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	options.metrics = &runMetrics{}
+
+	stopServer := serveMetrics(metricsListener, options.metrics)
+	defer stopServer()
+
+	var console syncBuffer
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error)
+	go func() {
+		served <- serveTCP(ctx, eventsListener, options, bufio.NewWriter(&console), io.Discard)
+	}()
+
+	var metricsUrl = "http://" + metricsListener.Addr().String() + "/metrics"
+	if body := getMetrics(t, metricsUrl); !strings.Contains(body, "translations_events_total 0\n") || !strings.Contains(body, "# TYPE translations_events_total counter\n") {
+		t.Errorf("Expected no events before the connection, got %q", body)
+	}
+
+	// a connection with two events and a line that is not valid
+	connection, err := net.Dial("tcp", eventsListener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(connection, `{"timestamp": "2018-12-26 18:11:08.509654","duration": 20}`+"\n")
+	io.WriteString(connection, "not json\n")
+	io.WriteString(connection, `{"timestamp": "2018-12-26 18:15:19.903159","duration": 31}`+"\n")
+	connection.Close()
+
+	// the connection is counted when the server closes it
+	var expected = []string{
+		"translations_events_total 2\n",
+		"translations_skipped_lines_total 1\n",
+		"translations_rows_total 6\n",
+		"translations_connection_duration_seconds_count 1\n",
+		"translations_request_duration_seconds_count 0\n",
+	}
+	var body string
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if body = getMetrics(t, metricsUrl); strings.Contains(body, expected[3]) {
+			break
+		}
+	}
+	for _, line := range expected {
+		if !strings.Contains(body, line) {
+			t.Errorf("Expected %q in the metrics after the connection, got %q", line, body)
+		}
+	}
+
+	cancel()
+	<-served
+
+	if _, err := parseFlags([]string{"--metrics-addr=:9100"}); err == nil {
+		t.Errorf("Expected an error with --metrics-addr without --listen-tcp, --kafka or --serve")
+	}
+	if _, err := parseFlags([]string{"--input_file=./events.json", "--serve=:8080", "--metrics-addr=:9100"}); err != nil {
+		t.Errorf("Expected --metrics-addr with --serve, got %v", err)
+	}
+}
+
+// function to get the body of the metrics endpoint
+func getMetrics(t *testing.T, url string) string {
+	t.Helper()
+
+	response, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
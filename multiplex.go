@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"hash"
+	"io"
+	"os"
+	"slices"
+)
+
+// function to calculate the series of each stream of a multiplexed input file in one read (--group-by=stream_id)
+// the minutes of every stream are kept while the file is read, and then the series are printed one after the other,
+// sorted by the id of the stream, with the id in each minute
+// each stream has its own window and range, from the first to the last minute with deliveries of the stream
+func runStreamsSeries(ctx context.Context, options options, stdout io.Writer, stderr io.Writer) error {
+	file, err := os.Open(options.inputFilePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var streams = make(map[string]*seriesMinutes)
+	var statistics = EventsStatistics{clientDeliveries: make(map[string]int)}
+	err = readEvents(ctx, file, options, &statistics, func(event inputEvent) error {
+		series, ok := streams[event.deliveredTranslation.Stream_id]
+		if !ok {
+			series = newSeriesMinutes()
+			streams[event.deliveredTranslation.Stream_id] = series
+		}
+
+		series.add(event, options)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var streamIds = make([]string, 0, len(streams))
+	for streamId := range streams {
+		streamIds = append(streamIds, streamId)
+	}
+	slices.Sort(streamIds)
+
+	// with --checksum a copy of the output is hashed as it is written
+	var checksum hash.Hash
+	if options.checksum {
+		checksum = sha256.New()
+	}
+
+	output, closeOutput, err := createOutputWriter(stdout, options.outputFilePath, options.gzipOutput, checksum)
+	if err != nil {
+		return err
+	}
+
+	// with --autoflush the buffered output is flushed while the minutes are printed, not only at the end
+	var rowsOutput io.Writer = output
+	var flusher *autoFlushWriter
+	if options.autoFlush.enabled() {
+		flusher = newAutoFlushWriter(output, options.autoFlush)
+		rowsOutput = flusher
+	}
+
+	// the streams are printed one after the other, so the CSV header is of every stream, with the stream_id field
+	var printer = newValuesPrinter(rowsOutput, options)
+	printer.warnings = stderr
+	printer.streamIds = true
+
+	for _, streamId := range streamIds {
+		var series = streams[streamId]
+		var firstMinute, lastMinute = series.firstMinute, series.lastMinute
+
+		// the same range as the series of the whole file
+		if options.roundToWindow {
+			firstMinute = roundDownToWindow(firstMinute, options.windowSize)
+		}
+		if !options.rangeStart.IsZero() {
+			firstMinute = options.rangeStart
+		}
+		if !options.rangeEnd.IsZero() {
+			lastMinute = options.rangeEnd
+		}
+
+		var window = &movingWindow{options: options}
+		for currentMinute := firstMinute; !currentMinute.After(lastMinute) && ctx.Err() == nil; currentMinute = currentMinute.Add(options.bucket) {
+			var currentMinuteData = series.numberTranslationsPerMinuteUTC[currentMinute.Format("2006-01-02 15:04:05")]
+
+			if printableValues, printable := window.next(currentMinute, currentMinuteData); printable {
+				printableValues.Stream_id = streamId
+				printer.print(printableValues)
+			}
+		}
+	}
+
+	printer.finish()
+	if flusher != nil {
+		flusher.stop()
+	}
+	if err := closeOutput(); err != nil {
+		return err
+	}
+
+	if checksum != nil {
+		printChecksum(stderr, checksum, options.outputFilePath)
+	}
+	if printer.err != nil {
+		return printer.err
+	}
+
+	return ctx.Err()
+}
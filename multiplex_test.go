@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_main_GroupByStreamId(t *testing.T) {
+
+	// two streams interleaved in one input, and the events of each stream in their own file
+	var streamEvents = map[string][]string{
+		"b": {`{"timestamp": "2018-12-26 18:11:08","duration": 20,"stream_id": "b"}`, `{"timestamp": "2018-12-26 18:13:08","duration": 40,"stream_id": "b"}`, `{"timestamp": "2018-12-26 18:20:00","duration": 60,"stream_id": "b"}`},
+		"a": {`{"timestamp": "2018-12-26 18:11:30","duration": 100,"stream_id": "a"}`, `{"timestamp": "2018-12-26 18:14:30","duration": 50,"stream_id": "a"}`},
+	}
+	var interleaved = []string{streamEvents["b"][0], streamEvents["a"][0], streamEvents["b"][1], streamEvents["a"][1], streamEvents["b"][2]}
+
+	var directory = t.TempDir()
+	inputFilePath := filepath.Join(directory, "events.json")
+	if err := os.WriteFile(inputFilePath, []byte(strings.Join(interleaved, "\n")), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// the series of each stream is the series of a file with only its events, tagged with the id, sorted by the id
+	var expected []PrintableValues
+	for _, streamId := range []string{"a", "b"} {
+		streamFilePath := filepath.Join(directory, streamId+".json")
+		if err := os.WriteFile(streamFilePath, []byte(strings.Join(streamEvents[streamId], "\n")), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, printableValues := range getContentFromConsole(t, "--input_file="+streamFilePath, "--window_size=3", "--with-throughput") {
+			printableValues.Stream_id = streamId
+			expected = append(expected, printableValues)
+		}
+	}
+
+	output := getConsoleOutput(t, "--input_file="+inputFilePath, "--group-by=stream_id", "--window_size=3", "--with-throughput")
+	var series []PrintableValues
+	if err := json.Unmarshal([]byte("["+strings.Join(strings.Split(strings.TrimSpace(output), "\n"), ",")+"]"), &series); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(series) != len(expected) {
+		t.Fatalf("Expected %d minutes, got %d in %q", len(expected), len(series), output)
+	}
+	for i := range series {
+		if series[i].Date != expected[i].Date || series[i].Average_delivery_time != expected[i].Average_delivery_time ||
+			*series[i].Throughput != *expected[i].Throughput || series[i].Stream_id != expected[i].Stream_id {
+			t.Errorf("Expected the minute %d to be %+v, got %+v", i, expected[i], series[i])
+		}
+	}
+
+	// with --split-output-dir each stream is written to its own file, without the id
+	outputDir := filepath.Join(directory, "streams")
+	getConsoleOutput(t, "--input_file="+inputFilePath, "--group-by=stream_id", "--window_size=3", "--split-output-dir="+outputDir)
+	for _, streamId := range []string{"a", "b"} {
+		content, err := os.ReadFile(filepath.Join(outputDir, streamId+".json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if streamOutput := getConsoleOutput(t, "--input_file="+filepath.Join(directory, streamId+".json"), "--window_size=3"); string(content) != streamOutput {
+			t.Errorf("Expected the file of the stream %s to be %q, got %q", streamId, streamOutput, content)
+		}
+	}
+
+	for _, arguments := range [][]string{{"--group-by=stream_id", "--normalize"}, {"--group-by=stream_id", "--assume-sorted"}, {"--group-by=stream"}} {
+		if err := run(context.Background(), append(arguments, "--input_file="+inputFilePath), io.Discard, io.Discard); err == nil {
+			t.Errorf("Expected an error with %v", arguments)
+		}
+	}
+}
+
+func Test_main_GroupByStreamIdCSV(t *testing.T) {
+
+	// the events without a stream_id are the stream with the empty id, printed first
+	inputFilePath := filepath.Join(t.TempDir(), "events.json")
+	events := `{"timestamp": "2018-12-26 18:11:08","duration": 20}
+{"timestamp": "2018-12-26 18:11:30","duration": 100,"stream_id": "a"}
+{"timestamp": "2018-12-26 18:12:30","duration": 50,"stream_id": "a"}
+`
+	if err := os.WriteFile(inputFilePath, []byte(events), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// every record has the same fields, the reader fails with a record that has more fields than the header
+	output := getConsoleOutput(t, "--input_file="+inputFilePath, "--group-by=stream_id", "--format=csv")
+	records, err := csv.NewReader(strings.NewReader(output)).ReadAll()
+	if err != nil {
+		t.Fatalf("Expected a valid CSV, got %v in %q", err, output)
+	}
+
+	if header := strings.Join(records[0], ","); header != "date,average_delivery_time,stream_id" {
+		t.Errorf("Expected the stream_id field in the header, got %q", header)
+	}
+	var streamIds []string
+	for _, record := range records[1:] {
+		streamIds = append(streamIds, record[2])
+	}
+	if expected := []string{"", "", "a", "a", "a"}; strings.Join(streamIds, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected the stream ids %q, got %q", expected, streamIds)
+	}
+}
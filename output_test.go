@@ -0,0 +1,658 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// function to set every field of the struct to a non zero value
+// the pointers are allocated, so every omitempty field is present in the output
+func fillAllFields(t *testing.T, value reflect.Value, number float64) {
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Field(i)
+
+		if field.Kind() == reflect.Pointer {
+			field.Set(reflect.New(field.Type().Elem()))
+			field = field.Elem()
+		}
+
+		switch field.Kind() {
+		case reflect.String:
+			field.SetString(fmt.Sprintf("value <%d> \"quoted\"\n", i))
+		case reflect.Float64:
+			field.SetFloat(number * float64(i+1))
+		case reflect.Bool:
+			field.SetBool(true)
+		default:
+			t.Fatalf("Field %s of kind %s is not filled by the test", value.Type().Field(i).Name, field.Kind())
+		}
+	}
+}
+
+func Test_appendPrintableValues_SameAsJSON(t *testing.T) {
+
+	var allFields PrintableValues
+	fillAllFields(t, reflect.ValueOf(&allFields).Elem(), 1.5)
+
+	normalized := 0.25
+	series := []PrintableValues{
+		allFields,
+		{Date: "2018-12-26 18:11:00", Average_delivery_time: 0},
+		{Date: "2018-12-26 18:12:00", Average_delivery_time: 25.5, Normalized: &normalized},
+		{Date: "2018-12-26 18:13:00", Average_delivery_time: 100.0 / 3},
+		{Date: "  \xff é", Average_delivery_time: 1e-7},
+		{Date: "", Average_delivery_time: -1e21},
+		{Date: "", Average_delivery_time: math.MaxFloat64},
+	}
+
+	for _, printableValues := range series {
+		expected, _ := json.Marshal(printableValues)
+		line := appendPrintableValues(nil, printableValues, false, false)
+
+		if string(line) != string(expected) {
+			t.Errorf("Expected %s, got %s", expected, line)
+		}
+	}
+}
+
+func Test_valuesPrinter_NoAllocations(t *testing.T) {
+
+	delta := 5.5
+	printer := valuesPrinter{output: io.Discard}
+	printableValues := PrintableValues{Date: "2018-12-26 18:16:00", Average_delivery_time: 25.5, Delta_prev: &delta}
+
+	allocations := testing.AllocsPerRun(100, func() {
+		printer.print(printableValues)
+	})
+
+	if allocations != 0 {
+		t.Errorf("Expected printing a minute to not allocate, got %f allocations", allocations)
+	}
+}
+
+func BenchmarkValuesPrinter(b *testing.B) {
+
+	printer := valuesPrinter{output: io.Discard}
+	printableValues := PrintableValues{Date: "2018-12-26 18:16:00", Average_delivery_time: 25.5}
+
+	// the benchmark fails if the printer starts allocating again
+	if allocations := testing.AllocsPerRun(100, func() { printer.print(printableValues) }); allocations != 0 {
+		b.Fatalf("Expected 0 allocs/op, got %f", allocations)
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		printer.print(printableValues)
+	}
+}
+
+// the previous implementation, kept to compare with the printer
+func BenchmarkJSONMarshal(b *testing.B) {
+
+	printableValues := PrintableValues{Date: "2018-12-26 18:16:00", Average_delivery_time: 25.5}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		line, _ := json.Marshal(printableValues)
+		fmt.Fprintln(io.Discard, string(line))
+	}
+}
+
+func Test_valuesPrinter_Csv(t *testing.T) {
+
+	var console bytes.Buffer
+	printer := valuesPrinter{output: &console, csv: true}
+
+	withinSla := true
+	printer.print(PrintableValues{Date: "2018-12-26 18:16:00", Average_delivery_time: 25.5, Within_sla: &withinSla})
+	printer.print(PrintableValues{Date: "2018-12-26 18:17:00", Average_delivery_time: 100.0 / 3, Within_sla: &withinSla})
+
+	expected := `date,average_delivery_time,within_sla
+2018-12-26 18:16:00,25.5,true
+2018-12-26 18:17:00,33.333333333333336,true
+`
+	if console.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, console.String())
+	}
+}
+
+func Test_valuesPrinter_WholeAverages(t *testing.T) {
+
+	// the whole averages are printed without a decimal point in every format, the others keep their decimals
+	for _, test := range []struct {
+		printer  valuesPrinter
+		expected string
+	}{
+		{valuesPrinter{}, `{"date":"2018-12-26 18:16:00","average_delivery_time":100}
+{"date":"2018-12-26 18:17:00","average_delivery_time":33.333333333333336}
+`},
+		{valuesPrinter{csv: true}, `date,average_delivery_time
+2018-12-26 18:16:00,100
+2018-12-26 18:17:00,33.333333333333336
+`},
+		{valuesPrinter{numbersAsStrings: true}, `{"date":"2018-12-26 18:16:00","average_delivery_time":"100"}
+{"date":"2018-12-26 18:17:00","average_delivery_time":"33.333333333333336"}
+`},
+	} {
+		var console bytes.Buffer
+		test.printer.output = &console
+
+		test.printer.print(PrintableValues{Date: "2018-12-26 18:16:00", Average_delivery_time: 300.0 / 3})
+		test.printer.print(PrintableValues{Date: "2018-12-26 18:17:00", Average_delivery_time: 100.0 / 3})
+
+		if console.String() != test.expected {
+			t.Errorf("Expected %q, got %q", test.expected, console.String())
+		}
+	}
+}
+
+func Test_main_CsvDecimalComma(t *testing.T) {
+
+	var console bytes.Buffer
+
+	if err := run(context.Background(), []string{"--input_file=./events.json", "--format=csv", "--decimal-comma", "--diff"}, &console, io.Discard); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	lines := strings.Split(console.String(), "\n")
+
+	if lines[0] != "date;average_delivery_time;delta_prev" {
+		t.Errorf("Expected the header with semicolons, got %q", lines[0])
+	}
+
+	// the minute 18:16 is the first with the average of two deliveries
+	if lines[6] != "2018-12-26 18:16:00;25,5;5,5" {
+		t.Errorf("Expected the record with decimal commas, got %q", lines[6])
+	}
+}
+
+func Test_appendCSVString_Quoted(t *testing.T) {
+
+	if field := string(appendCSVString(nil, `a;"b"`, ';')); field != `"a;""b"""` {
+		t.Errorf("Expected the field to be quoted, got %s", field)
+	}
+}
+
+func Test_valuesPrinter_JsonArray(t *testing.T) {
+
+	var console bytes.Buffer
+	printer := valuesPrinter{output: &console, array: true}
+
+	// each object is written when it is printed, the array is not kept in memory
+	for i := 0; i < 10000; i++ {
+		printer.print(PrintableValues{Date: "2018-12-26 18:16:00", Average_delivery_time: float64(i)})
+
+		if !bytes.HasSuffix(console.Bytes(), []byte(fmt.Sprintf(`"average_delivery_time":%d}`, i))) {
+			t.Fatalf("Expected the object %d to be written when printed, got %q", i, console.Bytes()[max(0, console.Len()-80):])
+		}
+	}
+
+	// printing an object of the array doesn't allocate either
+	if allocations := testing.AllocsPerRun(100, func() { printer.print(PrintableValues{Date: "2018-12-26 18:16:00"}) }); allocations != 0 {
+		t.Errorf("Expected printing a minute to not allocate, got %f allocations", allocations)
+	}
+
+	printer.finish()
+
+	var series []PrintableValues
+	if err := json.Unmarshal(console.Bytes(), &series); err != nil {
+		t.Fatalf("Expected a valid JSON array, got %v", err)
+	}
+	if len(series) != 10101 || series[9999].Average_delivery_time != 9999 {
+		t.Errorf("Expected 10101 minutes in the array, got %d", len(series))
+	}
+}
+
+func Test_main_JsonArray(t *testing.T) {
+
+	// a series of a week of minutes
+	var console bytes.Buffer
+	if err := run(context.Background(), []string{"--input_file=./events.json", "--format=json-array", "--range-end=2019-01-02 18:11:00"}, &console, io.Discard); err != nil {
+		t.Fatal(err)
+	}
+
+	var series []PrintableValues
+	if err := json.Unmarshal(console.Bytes(), &series); err != nil {
+		t.Fatalf("Expected a valid JSON array, got %v", err)
+	}
+	if len(series) != 7*24*60+1 || series[13] != (PrintableValues{Date: "2018-12-26 18:24:00", Average_delivery_time: 42.5}) {
+		t.Errorf("Expected a week of minutes in the array, got %d", len(series))
+	}
+
+	// an empty series is an empty array
+	console.Reset()
+	printer := valuesPrinter{output: &console, array: true}
+	printer.finish()
+	if console.String() != "[]\n" {
+		t.Errorf("Expected an empty array, got %q", console.String())
+	}
+}
+
+func Test_main_Color(t *testing.T) {
+
+	// the averages of the example are 0, 20, 25.5, 31 and 42.5
+	always := getConsoleOutput(t, "--input_file=./events.json", "--color=always", "--thresholds=20,31")
+	for _, expected := range []string{
+		"\x1b[32m{\"date\":\"2018-12-26 18:12:00\",\"average_delivery_time\":20}\x1b[0m\n",
+		"\x1b[33m{\"date\":\"2018-12-26 18:22:00\",\"average_delivery_time\":31}\x1b[0m\n",
+		"\x1b[31m{\"date\":\"2018-12-26 18:24:00\",\"average_delivery_time\":42.5}\x1b[0m\n",
+	} {
+		if !strings.Contains(always, expected) {
+			t.Errorf("Expected the line %q with --color=always, got\n%s", expected, always)
+		}
+	}
+
+	never := getConsoleOutput(t, "--input_file=./events.json", "--color=never")
+	if strings.Contains(never, "\x1b[") {
+		t.Errorf("Expected no escape codes with --color=never, got\n%s", never)
+	}
+
+	// the console of the tests is not a terminal, and the CSV output is never colored
+	for _, arguments := range [][]string{{"--color=auto"}, {"--color=always", "--format=csv"}} {
+		if output := getConsoleOutput(t, append(arguments, "--input_file=./events.json")...); strings.Contains(output, "\x1b[") {
+			t.Errorf("Expected no escape codes with %v, got\n%s", arguments, output)
+		}
+	}
+
+	for _, invalid := range []string{"--color=sometimes", "--thresholds=30", "--thresholds=60,30", "--thresholds=a,b"} {
+		if err := run(context.Background(), []string{"--input_file=./events.json", invalid}, io.Discard, io.Discard); err == nil {
+			t.Errorf("Expected an error for %s", invalid)
+		}
+	}
+}
+
+func Test_main_JsonNumbersAsStrings(t *testing.T) {
+
+	output := getConsoleOutput(t, "--input_file=./events.json", "--json-numbers-as-strings", "--diff")
+	if !strings.Contains(output, `{"date":"2018-12-26 18:24:00","average_delivery_time":"42.5","delta_prev":11.5}`) {
+		t.Errorf("Expected the average to be quoted, got\n%s", output)
+	}
+
+	// the same as json.Marshal with the ",string" option
+	type quotedValues struct {
+		Date                  string  `json:"date"`
+		Average_delivery_time float64 `json:"average_delivery_time,string"`
+	}
+	for _, average := range []float64{0, 31.43, 100.0 / 3, 1e-7, 1e21} {
+		expected, _ := json.Marshal(quotedValues{Date: "2018-12-26 18:24:00", Average_delivery_time: average})
+		if line := appendPrintableValues(nil, PrintableValues{Date: "2018-12-26 18:24:00", Average_delivery_time: average}, true, false); string(line) != string(expected) {
+			t.Errorf("Expected %s, got %s", expected, line)
+		}
+	}
+
+	if output := getConsoleOutput(t, "--input_file=./events.json"); strings.Contains(output, `"average_delivery_time":"`) {
+		t.Errorf("Expected the average to be a number without the flag, got\n%s", output)
+	}
+}
+
+func Test_main_Checksum(t *testing.T) {
+
+	// the checksum is in stderr, and the console only has the output
+	console, stderr := getConsoleAndStderr(t, "--input_file=./events.json", "--checksum", "--format=csv")
+	if expected := fmt.Sprintf("%x  -\n", sha256.Sum256([]byte(console))); stderr != expected {
+		t.Errorf("Expected the checksum %q, got %q", expected, stderr)
+	}
+	if console != getConsoleOutput(t, "--input_file=./events.json", "--format=csv") {
+		t.Errorf("Expected the same output with --checksum, got %q", console)
+	}
+
+	// the checksum of a compressed file is the checksum of the bytes of the file
+	outputFilePath := filepath.Join(t.TempDir(), "output.json.gz")
+	_, stderr = getConsoleAndStderr(t, "--input_file=./events.json", "--checksum", "--output_file="+outputFilePath)
+	content, err := os.ReadFile(outputFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := fmt.Sprintf("%x  %s\n", sha256.Sum256(content), outputFilePath); stderr != expected {
+		t.Errorf("Expected the checksum %q, got %q", expected, stderr)
+	}
+
+	// the raw output has its checksum too
+	console, stderr = getConsoleAndStderr(t, "--input_file=./events.json", "--checksum", "--raw")
+	if expected := fmt.Sprintf("%x  -\n", sha256.Sum256([]byte(console))); stderr != expected {
+		t.Errorf("Expected the checksum %q of the raw output, got %q", expected, stderr)
+	}
+
+	// the output flushed in several writes is hashed as a whole, with the end of the JSON array written by the last one
+	console, stderr = getConsoleAndStderr(t, "--input_file=./events.json", "--checksum", "--format=json-array", "--autoflush=rows:2")
+	if expected := fmt.Sprintf("%x  -\n", sha256.Sum256([]byte(console))); stderr != expected || !strings.HasSuffix(console, "]\n") {
+		t.Errorf("Expected the checksum %q of the flushed output, got %q", expected, stderr)
+	}
+}
+
+func Test_main_DateFormatOffset(t *testing.T) {
+
+	// the first minute is 0 and the next ones increment by one bucket
+	for _, arguments := range [][]string{
+		{"--input_file=./events.json", "--date-format=offset"},
+		{"--input_file=./events.json", "--date-format=offset", "--bucket=10s", "--drop-first"},
+		{"--input_file=./events.json", "--date-format=offset", "--format=json-array"},
+	} {
+		output := getConsoleOutput(t, arguments...)
+
+		// the JSON objects of the lines are the same as the objects of the array
+		if !strings.HasPrefix(output, "[") {
+			output = "[" + strings.Join(strings.Split(strings.TrimSpace(output), "\n"), ",") + "]"
+		}
+		var dates []struct {
+			Date int `json:"date"`
+		}
+		if err := json.Unmarshal([]byte(output), &dates); err != nil {
+			t.Fatalf("Expected the dates as numbers with %v, got %v", arguments, err)
+		}
+
+		if len(dates) < 2 {
+			t.Fatalf("Expected several minutes with %v, got %q", arguments, output)
+		}
+		for i, date := range dates {
+			if date.Date != i {
+				t.Errorf("Expected the offset %d with %v, got %d", i, arguments, date.Date)
+			}
+		}
+	}
+
+	// the CSV dates are the offsets too
+	if output := getConsoleOutput(t, "--input_file=./events.json", "--date-format=offset", "--format=csv"); !strings.HasPrefix(output, "date,average_delivery_time\n0,0\n1,20\n") {
+		t.Errorf("Expected the offsets in the CSV output, got %q", output)
+	}
+
+	if err := run(context.Background(), []string{"--input_file=./events.json", "--date-format=seconds"}, io.Discard, io.Discard); err == nil {
+		t.Errorf("Expected an error with an invalid date format")
+	}
+}
+
+func Test_main_DateFormatLayouts(t *testing.T) {
+
+	// the minute 18:12 of the 26th of December in the layouts of the locales and a Go layout
+	for dateFormat, expected := range map[string]string{"eu": "26/12/2018 18:12", "us": "12/26/2018 18:12", "2006-01-02T15:04": "2018-12-26T18:12"} {
+		data := getContentFromConsole(t, "--input_file=./events.json", "--date-format="+dateFormat, "--with-window-span")
+		if data[1].Date != expected || data[1].Window_end != expected {
+			t.Errorf("Expected the date %s with %s, got %+v", expected, dateFormat, data[1])
+		}
+	}
+
+	// the CSV dates, the runs of zeros and the raw minutes have the layout too
+	if output := getConsoleOutput(t, "--input_file=./events.json", "--date-format=eu", "--format=csv"); !strings.HasPrefix(output, "date,average_delivery_time\n26/12/2018 18:11,0\n26/12/2018 18:12,20\n") {
+		t.Errorf("Expected the eu dates in the CSV output, got %q", output)
+	}
+	if output := getConsoleOutput(t, "--input_file=./events.json", "--date-format=eu", "--compact-zeros", "--window_size=1"); !strings.Contains(output, `"from":"26/12/2018 18:13","to":"26/12/2018 18:15"`) {
+		t.Errorf("Expected the eu dates in the runs of zeros, got %q", output)
+	}
+	if output := getConsoleOutput(t, "--input_file=./events.json", "--date-format=eu", "--raw"); !strings.HasPrefix(output, `{"date":"26/12/2018 18:12","sum_duration":20,"count":1}`) {
+		t.Errorf("Expected the eu dates in the raw output, got %q", output)
+	}
+
+	if err := run(context.Background(), []string{"--input_file=./events.json", "--date-format=eu", "--retain-input-tz"}, io.Discard, io.Discard); err == nil {
+		t.Errorf("Expected an error with a layout and --retain-input-tz")
+	}
+}
+
+func Test_main_JsonLinesStrict(t *testing.T) {
+
+	// the ids of the streams have line breaks and the separators of JavaScript, which are escaped in the strings
+	inputFilePath := filepath.Join(t.TempDir(), "events.json")
+	events := `{"timestamp": "2018-12-26 18:11:08","stream_id": "first\nstream","duration": 20}
+{"timestamp": "2018-12-26 18:12:08","stream_id": "second\u2028stream\r","duration": 30}
+`
+	if err := os.WriteFile(inputFilePath, []byte(events), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, arguments := range [][]string{
+		{"--input_file=" + inputFilePath, "--group-by=stream_id"},
+		{"--input_file=./events.json", "--color=always", "--with-throughput", "--window_size=0", "--nan-policy=null"},
+		{"--input_file=./events.json", "--raw"},
+	} {
+		output := getConsoleOutput(t, append(arguments, "--json-lines-strict")...)
+
+		// every line ends in a line break, including the last one, and is one JSON object by itself
+		if !strings.HasSuffix(output, "\n") {
+			t.Errorf("Expected the output to end in a line break with %v, got %q", arguments, output)
+		}
+		lines := strings.SplitAfter(output, "\n")
+		for _, line := range lines[:len(lines)-1] {
+			var object map[string]any
+			if err := json.Unmarshal([]byte(line), &object); err != nil || strings.Count(line, "\n") != 1 {
+				t.Errorf("Expected one JSON object in the line %q with %v, got %v", line, arguments, err)
+			}
+		}
+		if lines[len(lines)-1] != "" {
+			t.Errorf("Expected nothing after the last line with %v, got %q", arguments, lines[len(lines)-1])
+		}
+	}
+
+	// a line that is not one object is not written, and the error is kept
+	var console bytes.Buffer
+	var printer = valuesPrinter{output: &console, strict: true}
+	printer.startLine()
+	printer.buffer = append(printer.buffer, "{\"date\":\n1}"...)
+	printer.endLine()
+	printer.print(PrintableValues{Date: "2018-12-26 18:12:00"})
+	if printer.err == nil || console.Len() != 0 {
+		t.Errorf("Expected an error and no output for a line with a line break, got %v and %q", printer.err, console.String())
+	}
+
+	for _, format := range []string{"--format=csv", "--format=json-array", "--line-separator=,"} {
+		if _, err := parseFlags([]string{"--json-lines-strict", format}); err == nil {
+			t.Errorf("Expected an error with --json-lines-strict and %s", format)
+		}
+	}
+}
+
+func Test_main_UnixTimestamps(t *testing.T) {
+
+	// the same minutes as the formatted dates, with the seconds since the Unix epoch
+	expected := getContentFromConsole(t, "--input_file=./events.json")
+	output := getConsoleOutput(t, "--input_file=./events.json", "--unix-ts")
+
+	var minutes []struct {
+		Date                  int64   `json:"date"`
+		Average_delivery_time float64 `json:"average_delivery_time"`
+	}
+	if err := json.Unmarshal([]byte("["+strings.Join(strings.Split(strings.TrimSpace(output), "\n"), ",")+"]"), &minutes); err != nil {
+		t.Fatalf("Expected the dates as numbers, got %v", err)
+	}
+
+	if len(minutes) != len(expected) {
+		t.Fatalf("Expected %d minutes, got %d", len(expected), len(minutes))
+	}
+	for i, minute := range minutes {
+		date, err := time.Parse("2006-01-02 15:04:05", expected[i].Date)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if minute.Date != date.Unix() || minute.Average_delivery_time != expected[i].Average_delivery_time {
+			t.Errorf("Expected the minute %d at %d with %v, got %d with %v", i, date.Unix(), expected[i].Average_delivery_time, minute.Date, minute.Average_delivery_time)
+		}
+	}
+
+	// the CSV dates are the seconds too
+	if output := getConsoleOutput(t, "--input_file=./events.json", "--unix-ts", "--format=csv"); !strings.HasPrefix(output, "date,average_delivery_time\n1545847860,0\n1545847920,20\n") {
+		t.Errorf("Expected the seconds in the CSV output, got %q", output)
+	}
+
+	for _, dateFormat := range []string{"timestamp", "offset"} {
+		if err := run(context.Background(), []string{"--input_file=./events.json", "--unix-ts", "--date-format=" + dateFormat}, io.Discard, io.Discard); err == nil {
+			t.Errorf("Expected an error with --unix-ts and --date-format=%s", dateFormat)
+		}
+	}
+}
+
+func Test_main_GapValue(t *testing.T) {
+
+	// the throughput of a window of 0 minutes is 0 deliveries in 0 minutes, NaN
+	for _, format := range []string{"json", "csv"} {
+		var console, stderr bytes.Buffer
+		if err := run(context.Background(), []string{"--input_file=./events.json", "--window_size=0", "--with-throughput", "--gap-value=-1", "--format=" + format}, &console, &stderr); err != nil {
+			t.Fatal(err)
+		}
+
+		if strings.Contains(console.String(), "NaN") || strings.Contains(console.String(), "Inf") {
+			t.Errorf("Expected only finite values in the %s output, got %q", format, console.String())
+		}
+		if expected := "warning: the throughput of 2018-12-26 18:11:00 is NaN, it is written as -1, the next values that are not finite are not reported\n"; stderr.String() != expected {
+			t.Errorf("Expected the warning %q, got %q", expected, stderr.String())
+		}
+	}
+
+	// the JSON lines are valid
+	for _, printableValues := range getContentFromConsole(t, "--input_file=./events.json", "--window_size=0", "--with-throughput", "--gap-value=-1") {
+		if printableValues.Throughput == nil || *printableValues.Throughput != -1 {
+			t.Errorf("Expected the throughput -1, got %+v", printableValues)
+		}
+	}
+
+	// the infinite values and the pointers are replaced too, without changing the values they point to
+	var infinity = math.Inf(1)
+	var printableValues = PrintableValues{Date: "2018-12-26 18:11:00", Average_delivery_time: math.Inf(-1), Vs_baseline: &infinity}
+	if field, value := replaceNonFinite(&printableValues, 0); field != "average_delivery_time" || !math.IsInf(value, -1) {
+		t.Errorf("Expected the average to be the first replaced value, got %s %v", field, value)
+	}
+	if printableValues.Average_delivery_time != 0 || *printableValues.Vs_baseline != 0 || !math.IsInf(infinity, 1) {
+		t.Errorf("Expected the values to be replaced with 0, got %+v", printableValues)
+	}
+
+	if err := run(context.Background(), []string{"--input_file=./events.json", "--gap-value=NaN"}, io.Discard, io.Discard); err == nil {
+		t.Errorf("Expected an error with a gap value that is not finite")
+	}
+}
+
+func Test_main_NanPolicy(t *testing.T) {
+
+	// the throughput of a window of 0 minutes is NaN in every minute
+	var arguments = []string{"--input_file=./events.json", "--window_size=0", "--with-throughput"}
+
+	// with null the lines are valid JSON with a null throughput, and the CSV fields are empty
+	var console, stderr bytes.Buffer
+	if err := run(context.Background(), append(arguments, "--nan-policy=null", "--json-numbers-as-strings"), &console, &stderr); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(console.String(), "\n"), "\n") {
+		var fields map[string]any
+		if err := json.Unmarshal([]byte(line), &fields); err != nil {
+			t.Fatalf("Expected a valid JSON line, got %q: %v", line, err)
+		}
+		if throughput, ok := fields["throughput"]; !ok || throughput != nil {
+			t.Errorf("Expected a null throughput, got %q", line)
+		}
+	}
+	if expected := "warning: the throughput of 2018-12-26 18:11:00 is NaN, it is written as null, the next values that are not finite are not reported\n"; stderr.String() != expected {
+		t.Errorf("Expected the warning %q, got %q", expected, stderr.String())
+	}
+
+	csvOutput := getConsoleOutput(t, append(arguments, "--nan-policy=null", "--format=csv")...)
+	if lines := strings.Split(csvOutput, "\n"); lines[0] != "date,average_delivery_time,throughput" || lines[1] != "2018-12-26 18:11:00,0," {
+		t.Errorf("Expected an empty throughput in the CSV output, got %q", csvOutput)
+	}
+
+	// with error the output ends before the first minute, and the JSON array is closed
+	console.Reset()
+	err := run(context.Background(), append(arguments, "--nan-policy=error", "--format=json-array"), &console, io.Discard)
+	if err == nil || !strings.Contains(err.Error(), "the throughput of 2018-12-26 18:11:00 is NaN") {
+		t.Errorf("Expected an error with the first value that is not finite, got %v", err)
+	}
+	if console.String() != "[]\n" {
+		t.Errorf("Expected an empty JSON array, got %q", console.String())
+	}
+
+	// the values that are finite are printed
+	if err := run(context.Background(), []string{"--input_file=./events.json", "--nan-policy=error"}, io.Discard, io.Discard); err != nil {
+		t.Errorf("Expected no error without values that are not finite, got %v", err)
+	}
+
+	// the series of Compute and --serve returns the error
+	reader, err := os.Open("./events.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	options, err := parseFlags([]string{"--window_size=0", "--with-throughput", "--nan-policy=error"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := computeSeries(context.Background(), reader, options); err == nil {
+		t.Errorf("Expected an error from computeSeries with --nan-policy=error")
+	}
+
+	// the values that are not finite are never written as NaN or infinity
+	for _, value := range []float64{math.NaN(), math.Inf(1), math.Inf(-1)} {
+		if written := string(appendJSONFloat(nil, value)); written != "null" {
+			t.Errorf("Expected %v to be written as null, got %s", value, written)
+		}
+	}
+
+	for _, flags := range [][]string{{"--nan-policy=nan"}, {"--nan-policy=error", "--listen-tcp=:9000"}} {
+		if _, err := parseFlags(flags); err == nil {
+			t.Errorf("Expected an error with %v", flags)
+		}
+	}
+}
+
+func Test_main_GapValueFillForward(t *testing.T) {
+
+	// the windows of 2 minutes between the deliveries are empty, they repeat the last average, before the first delivery it is 0
+	var expected = map[string]float64{
+		"2018-12-26 18:10:00": 0, "2018-12-26 18:11:00": 0,
+		"2018-12-26 18:12:00": 20, "2018-12-26 18:13:00": 20, "2018-12-26 18:14:00": 20, "2018-12-26 18:15:00": 20,
+		"2018-12-26 18:16:00": 31, "2018-12-26 18:20:00": 31, "2018-12-26 18:23:00": 31,
+		"2018-12-26 18:24:00": 54,
+	}
+
+	var content = getContentFromConsole(t, "--input_file=./events.json", "--window_size=2", "--gap-value=ffill", "--range-start=2018-12-26 18:10:00")
+	if len(content) != 15 {
+		t.Fatalf("Expected 15 minutes, got %d", len(content))
+	}
+	for _, printableValues := range content {
+		if average, ok := expected[printableValues.Date]; ok && printableValues.Average_delivery_time != average {
+			t.Errorf("Expected the average %v in %s, got %v", average, printableValues.Date, printableValues.Average_delivery_time)
+		}
+	}
+
+	// the same minutes are 0 without it
+	for _, printableValues := range getContentFromConsole(t, "--input_file=./events.json", "--window_size=2") {
+		if printableValues.Date == "2018-12-26 18:20:00" && printableValues.Average_delivery_time != 0 {
+			t.Errorf("Expected the average 0 in %s without ffill, got %v", printableValues.Date, printableValues.Average_delivery_time)
+		}
+	}
+
+	if err := run(context.Background(), []string{"--input_file=./events.json", "--gap-value=fill"}, io.Discard, io.Discard); err == nil {
+		t.Errorf("Expected an error with a gap value that is not a number or ffill")
+	}
+}
+
+func Test_main_LineSeparator(t *testing.T) {
+
+	var expected = strings.Split(strings.TrimSuffix(getConsoleOutput(t, "--input_file=./events.json"), "\n"), "\n")
+
+	// the objects are joined in one line, that ends in a line break
+	for separator, joined := range map[string]string{" ": " ", `\t`: "\t", ", ": ", "} {
+		output := getConsoleOutput(t, "--input_file=./events.json", "--line-separator="+separator)
+		if output != strings.Join(expected, joined)+"\n" {
+			t.Errorf("Expected the objects joined with %q, got %q", joined, output)
+		}
+	}
+
+	// the JSON array and the CSV output don't use the separator
+	for _, format := range []string{"json-array", "csv"} {
+		if output := getConsoleOutput(t, "--input_file=./events.json", "--line-separator= ", "--format="+format); output != getConsoleOutput(t, "--input_file=./events.json", "--format="+format) {
+			t.Errorf("Expected the separator to be ignored with --format=%s, got %q", format, output)
+		}
+	}
+
+	for _, separator := range []string{"", `\`} {
+		if err := run(context.Background(), []string{"--input_file=./events.json", "--line-separator=" + separator}, io.Discard, io.Discard); err == nil {
+			t.Errorf("Expected an error with the separator %q", separator)
+		}
+	}
+}
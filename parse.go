@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// number of lines parsed together by a worker of --parse-workers
+// parsing the lines in batches keeps the cost of the channels small compared to the cost of decoding the JSON
+const parseBatchSize = 256
+
+// struct with a line of the input parsed into an event
+// err is set if the line is not a valid event, and schemaErr if the event has unknown fields (--strict-schema)
+type parsedLine struct {
+	deliveredTranslation DeliveredTranslation
+	eventTime            time.Time
+	lineNumber           int
+	err                  error
+	schemaErr            error
+}
+
+// function to parse a line of the input into an event, without changing any state, so the lines can be parsed in parallel
+func parseLine(line []byte, lineNumber int, options options) parsedLine {
+	var parsed = parsedLine{lineNumber: lineNumber}
+
+	parsed.deliveredTranslation, parsed.err = parseDeliveredTranslation(line, options.inputFieldMap)
+	if parsed.err != nil {
+		return parsed
+	}
+
+	if options.strictSchema {
+		parsed.schemaErr = checkEventSchema(line, options.inputFieldMap)
+	}
+	if options.dedupeBy != "" {
+		parsed.deliveredTranslation.dedupKey = readDedupKey(line, options.dedupeBy)
+	}
+
+	parsed.eventTime, parsed.err = parseTimestamp(parsed.deliveredTranslation.Timestamp)
+	return parsed
+}
+
+// error of ParseEvent when the line is not a valid event, like invalid JSON, a field of another type or an unknown duration unit
+type EventError struct {
+	Err error
+}
+
+func (err *EventError) Error() string {
+	return fmt.Sprintf("invalid event: %v", err.Err)
+}
+
+func (err *EventError) Unwrap() error {
+	return err.Err
+}
+
+// error of ParseEvent when the event is valid but its timestamp is missing or not in one of the layouts of the timestamps
+type TimestampError struct {
+	Timestamp string
+	Err       error
+}
+
+func (err *TimestampError) Error() string {
+	return fmt.Sprintf("invalid timestamp %q: %v", err.Timestamp, err.Err)
+}
+
+func (err *TimestampError) Unwrap() error {
+	return err.Err
+}
+
+// function to parse a line of the input into an event and the minute it is counted in, for the readers of other sources of events
+// the minute is the end of the minute of the event, like in the output, so an event at 18:11:08 is in the minute 18:12:00
+// the duration is in the unit of the event (duration_unit), without the flags of the program
+// the error is an *EventError or a *TimestampError, to tell the lines that are not events from the events with an invalid timestamp
+func ParseEvent(line []byte) (DeliveredTranslation, time.Time, error) {
+	deliveredTranslation, err := parseDeliveredTranslation(line, nil)
+	if err != nil {
+		return deliveredTranslation, time.Time{}, &EventError{Err: err}
+	}
+
+	eventTime, err := parseTimestamp(deliveredTranslation.Timestamp)
+	if err != nil {
+		return deliveredTranslation, time.Time{}, &TimestampError{Timestamp: deliveredTranslation.Timestamp, Err: err}
+	}
+
+	return deliveredTranslation, eventMinute(eventTime, time.Time{}, options{bucket: time.Minute}), nil
+}
+
+// function to parse the lines of a reader and call handle with each of them, in the order of the input
+// with --parse-workers greater than 1 the lines are parsed in parallel, see parseLinesParallel
+// stops at the first error of handle and returns it, or when the context is canceled
+func parseLines(ctx context.Context, reader io.Reader, options options, handle func(parsedLine) error) error {
+	// the input is read in blocks of --input-buffer-size bytes
+	if options.inputBufferSize > 0 {
+		reader = bufio.NewReaderSize(reader, options.inputBufferSize)
+	}
+
+	if options.parseWorkers > 1 {
+		return parseLinesParallel(ctx, reader, options, handle)
+	}
+
+	var scanner = bufio.NewScanner(reader)
+	var lineNumber int
+
+	// the header lines (--skip-lines) are not parsed, but they are counted in the line numbers
+	for lineNumber < options.skipLines && scanner.Scan() {
+		lineNumber++
+	}
+
+	// read the file line by line, stopping if the program was interrupted
+	for scanner.Scan() && ctx.Err() == nil {
+		lineNumber++
+
+		if err := handle(parseLine(scanner.Bytes(), lineNumber, options)); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
+
+// struct with a batch of lines of the input, parsed by a worker
+// done is closed when the lines are parsed
+type parseBatch struct {
+	lines     [][]byte
+	firstLine int
+	parsed    []parsedLine
+	done      chan struct{}
+}
+
+// function to parse the lines of a reader with a pipeline of three stages
+// a goroutine reads the lines in batches, --parse-workers goroutines parse the batches,
+// and the calling goroutine calls handle with the parsed lines, one at a time
+// the batches are queued for handle in a channel with --channel-buffer batches, in the order they are read,
+// and each one is handled when it is parsed, so the lines are handled in the order of the input
+func parseLinesParallel(ctx context.Context, reader io.Reader, options options, handle func(parsedLine) error) error {
+	var scanner = bufio.NewScanner(reader)
+	var scanErr error
+
+	var batches = make(chan *parseBatch, options.channelBuffer)
+	var jobs = make(chan *parseBatch)
+
+	// the workers are waited for before returning, after they are stopped, so none is parsing after it returns
+	// the reader is not waited for, it can be blocked reading the input until its next line
+	var workers sync.WaitGroup
+	defer workers.Wait()
+
+	// closed when handle stops, so the reader and the workers don't wait for the batches that are not handled
+	var stop = make(chan struct{})
+	defer close(stop)
+
+	for i := 0; i < options.parseWorkers; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+
+			for {
+				var batch *parseBatch
+				var ok bool
+				select {
+				case batch, ok = <-jobs:
+				case <-stop:
+					return
+				}
+				if !ok {
+					return
+				}
+
+				batch.parsed = make([]parsedLine, len(batch.lines))
+				for i, line := range batch.lines {
+					batch.parsed[i] = parseLine(line, batch.firstLine+i, options)
+				}
+				close(batch.done)
+			}
+		}()
+	}
+
+	// the reader stops at the end of the input, or when handle stops
+	// the error of the scanner is set before the batches are closed, so it is read after the last batch
+	go func() {
+		defer close(batches)
+		defer close(jobs)
+
+		var lineNumber int
+		for lineNumber < options.skipLines && scanner.Scan() {
+			lineNumber++
+		}
+
+		for {
+			var batch = &parseBatch{firstLine: lineNumber + 1, done: make(chan struct{})}
+			for len(batch.lines) < parseBatchSize && scanner.Scan() {
+				lineNumber++
+				batch.lines = append(batch.lines, bytes.Clone(scanner.Bytes()))
+			}
+			if len(batch.lines) == 0 {
+				scanErr = scanner.Err()
+				return
+			}
+
+			select {
+			case batches <- batch:
+			case <-stop:
+				return
+			}
+			select {
+			case jobs <- batch:
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	for batch := range batches {
+		select {
+		case <-batch.done:
+		case <-ctx.Done():
+			return nil
+		}
+
+		for _, parsed := range batch.parsed {
+			if err := handle(parsed); err != nil {
+				return err
+			}
+		}
+
+		// stop handling if the program was interrupted
+		if ctx.Err() != nil {
+			return nil
+		}
+	}
+
+	return scanErr
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_main_ParseWorkersStable(t *testing.T) {
+
+	// more lines than a few batches, with invalid lines and events of several clients within the same minutes
+	var events strings.Builder
+	for i := 0; i < 3000; i++ {
+		if i%97 == 0 {
+			events.WriteString("not json\n")
+		}
+		fmt.Fprintf(&events, `{"timestamp": "2018-12-26 %02d:%02d:%02d","client_name": "client-%d","duration": %d}`+"\n", 10+i/600, i/10%60, i%10*5, i%3, 10+i%37)
+	}
+	inputFilePath := filepath.Join(t.TempDir(), "events.json")
+	if err := os.WriteFile(inputFilePath, []byte(events.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// an unknown field in the middle of a batch stops the read at the same line
+	unknownFieldFilePath := filepath.Join(t.TempDir(), "unknown.json")
+	lines := strings.SplitAfter(events.String(), "\n")
+	lines[1000] = `{"timestamp": "2018-12-26 11:00:00","duration": 1,"clinet_name": "typo"}` + "\n"
+	if err := os.WriteFile(unknownFieldFilePath, []byte(strings.Join(lines, "")), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, arguments := range [][]string{
+		{"--input_file=" + inputFilePath},
+		{"--input_file=" + inputFilePath, "--dedup-window=10s", "--top-n-clients=3", "--client=client-1,client-2"},
+		{"--input_file=" + inputFilePath, "--assume-sorted", "--with-throughput"},
+		{"--input_file=" + unknownFieldFilePath, "--strict-schema"},
+	} {
+		expectedConsole, expectedStderr, expectedErr := runWithOutputs(arguments...)
+
+		for _, tuning := range [][]string{{"--parse-workers=2"}, {"--parse-workers=8", "--channel-buffer=0"}, {"--parse-workers=3", "--channel-buffer=1"}, {"--parse-workers=8", "--channel-buffer=64"}} {
+			console, stderr, err := runWithOutputs(append(arguments, tuning...)...)
+
+			if console != expectedConsole || stderr != expectedStderr || fmt.Sprint(err) != fmt.Sprint(expectedErr) {
+				t.Errorf("Expected the same result with %v and %v, got a different output or error %v instead of %v", arguments, tuning, err, expectedErr)
+			}
+		}
+	}
+
+	if err := run(context.Background(), []string{"--parse-workers=0"}, io.Discard, io.Discard); err == nil {
+		t.Errorf("Expected an error without parse workers")
+	}
+}
+
+func Test_parseLinesParallel_Stop(t *testing.T) {
+
+	// a pipe with some batches of lines that is never closed, so the reader is blocked after them
+	reader, writer := io.Pipe()
+	defer writer.Close()
+	go func() {
+		for i := 0; i < 3*parseBatchSize; i++ {
+			if _, err := io.WriteString(writer, `{"timestamp": "2018-12-26 18:11:08","duration": 20}`+"\n"); err != nil {
+				return
+			}
+		}
+	}()
+
+	options, err := parseFlags([]string{"--parse-workers=4"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// handle stops at the first line, the workers are stopped and waited for without waiting for the reader
+	var errStop = errors.New("stop")
+	returned := make(chan error)
+	go func() {
+		returned <- parseLinesParallel(context.Background(), reader, options, func(parsed parsedLine) error {
+			return errStop
+		})
+	}()
+
+	select {
+	case err := <-returned:
+		if err != errStop {
+			t.Errorf("Expected the error of handle, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected parseLinesParallel to return when handle stops, with the reader blocked")
+	}
+}
+
+func Test_main_SkipLines(t *testing.T) {
+
+	events, err := os.ReadFile("./events.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a banner and a header before the events of the sample file
+	inputFilePath := filepath.Join(t.TempDir(), "events.json")
+	if err := os.WriteFile(inputFilePath, append([]byte("export of 2018-12-26\ntimestamp,duration\n"), events...), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// the output is the same as the file without the header, even with --strict-schema and the lines parsed in parallel
+	expected, _, _ := runWithOutputs("--input_file=./events.json")
+	for _, arguments := range [][]string{{"--skip-lines=2"}, {"--skip-lines=2", "--strict-schema"}, {"--skip-lines=2", "--parse-workers=4"}, {"--skip-lines=2", "--assume-sorted"}, {"--skip-header-lines=2"}} {
+		console, _, err := runWithOutputs(append(arguments, "--input_file="+inputFilePath)...)
+		if err != nil || console != expected {
+			t.Errorf("Expected the output of the events without the header with %v, got %q and %v", arguments, console, err)
+		}
+	}
+
+	// the header lines are not skipped lines of the statistics
+	statsFilePath := filepath.Join(t.TempDir(), "stats.json")
+	for skipLines, skippedLines := range map[int]string{0: `"skipped_lines": 2`, 2: `"skipped_lines": 0`} {
+		if _, _, err := runWithOutputs(fmt.Sprintf("--skip-lines=%d", skipLines), "--input_file="+inputFilePath, "--stats-json="+statsFilePath); err != nil {
+			t.Fatal(err)
+		}
+		if content, err := os.ReadFile(statsFilePath); err != nil || !strings.Contains(string(content), skippedLines) {
+			t.Errorf("Expected %s with --skip-lines=%d, got %s and %v", skippedLines, skipLines, content, err)
+		}
+	}
+
+	// the validate subcommand doesn't check the header
+	if console, _, err := runWithOutputs("validate", "--skip-lines=2", "--input_file="+inputFilePath); err != nil || !strings.Contains(console, "invalid lines: 0") {
+		t.Errorf("Expected no invalid lines, got %q and %v", console, err)
+	}
+
+	// the skipped lines are counted in the line numbers of the errors
+	lines := strings.SplitAfter(string(events), "\n")
+	lines[1] = `{"timestamp": "2018-12-26 18:15:19","duration": 31,"clinet_name": "typo"}` + "\n"
+	if err := os.WriteFile(inputFilePath, []byte("timestamp,duration\n"+strings.Join(lines, "")), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := runWithOutputs("--skip-lines=1", "--strict-schema", "--input_file="+inputFilePath); err == nil || !strings.HasPrefix(err.Error(), "line 3:") {
+		t.Errorf("Expected the error of the line 3, got %v", err)
+	}
+
+	if _, _, err := runWithOutputs("--skip-lines=-1"); err == nil {
+		t.Errorf("Expected an error with a negative number of lines")
+	}
+}
+
+func Test_main_InputBufferSize(t *testing.T) {
+
+	// the lines are longer than the smallest buffer
+	expectedConsole, expectedStderr, _ := runWithOutputs("--input_file=./events.json", "--top-n-clients=2")
+	for _, arguments := range [][]string{{"--input-buffer-size=16"}, {"--input-buffer-size=100"}, {"--input-buffer-size=1048576"}, {"--input-buffer-size=16", "--parse-workers=2"}} {
+		console, stderr, err := runWithOutputs(append(arguments, "--input_file=./events.json", "--top-n-clients=2")...)
+		if err != nil || console != expectedConsole || stderr != expectedStderr {
+			t.Errorf("Expected the same output with %v, got %q and %v", arguments, console, err)
+		}
+	}
+
+	if console, _, err := runWithOutputs("validate", "--input-buffer-size=16", "--input_file=./events.json"); err != nil || !strings.Contains(console, "valid lines: 3") {
+		t.Errorf("Expected 3 valid lines, got %q and %v", console, err)
+	}
+
+	if _, _, err := runWithOutputs("--input-buffer-size=8"); err == nil {
+		t.Errorf("Expected an error with a buffer smaller than 16 bytes")
+	}
+}
+
+// function to run the program and return the console, stderr and the error
+func runWithOutputs(arguments ...string) (string, string, error) {
+	var console, stderr bytes.Buffer
+	err := run(context.Background(), arguments, &console, &stderr)
+
+	return console.String(), stderr.String(), err
+}
+
+// the windowing is the same with any number of workers, only the parsing is parallel
+func BenchmarkRunParseWorkers(b *testing.B) {
+
+	inputFilePath := writeSortedEventsFile(b, 20000)
+
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if err := run(context.Background(), []string{"--input_file=" + inputFilePath, fmt.Sprintf("--parse-workers=%d", workers)}, io.Discard, io.Discard); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// the reads of the input file with different buffer sizes
+func BenchmarkRunInputBufferSize(b *testing.B) {
+
+	inputFilePath := writeSortedEventsFile(b, 20000)
+
+	for _, size := range []int{4 * 1024, 64 * 1024, 1024 * 1024} {
+		b.Run(fmt.Sprintf("size=%d", size), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if err := run(context.Background(), []string{"--input_file=" + inputFilePath, fmt.Sprintf("--input-buffer-size=%d", size)}, io.Discard, io.Discard); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func Test_main_ReadWindowFromInput(t *testing.T) {
+
+	events, err := os.ReadFile("./events.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inputFilePath := filepath.Join(t.TempDir(), "events.json")
+	if err := os.WriteFile(inputFilePath, append([]byte(`{"window_size": 3}`+"\n"), events...), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// the window of the metadata line is used, and the line is not an event
+	for _, arguments := range [][]string{{}, {"--strict-schema"}, {"--assume-sorted"}} {
+		expected, _, _ := runWithOutputs(append(arguments, "--input_file=./events.json", "--window_size=3")...)
+		console, _, err := runWithOutputs(append(arguments, "--input_file="+inputFilePath, "--read-window-from-input")...)
+		if err != nil || console != expected {
+			t.Errorf("Expected the output with a window of 3 minutes with %v, got %q and %v", arguments, console, err)
+		}
+	}
+
+	// an explicit window size wins, the line is still skipped
+	expected, _, _ := runWithOutputs("--input_file=./events.json", "--window_size=5")
+	if console, _, err := runWithOutputs("--input_file="+inputFilePath, "--read-window-from-input", "--window_size=5", "--strict-schema"); err != nil || console != expected {
+		t.Errorf("Expected the output with a window of 5 minutes, got %q and %v", console, err)
+	}
+
+	// without the flag the line is a line without a timestamp
+	expected, _, _ = runWithOutputs("--input_file=./events.json")
+	if console, _, err := runWithOutputs("--input_file=" + inputFilePath); err != nil || console != expected {
+		t.Errorf("Expected the output with the default window, got %q and %v", console, err)
+	}
+
+	// a first line that is an event is not skipped
+	if console, _, err := runWithOutputs("--input_file=./events.json", "--read-window-from-input"); err != nil || console != expected {
+		t.Errorf("Expected the output of every event with the default window, got %q and %v", console, err)
+	}
+
+	if err := os.WriteFile(inputFilePath, append([]byte(`{"window_size": -1}`+"\n"), events...), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := runWithOutputs("--input_file="+inputFilePath, "--read-window-from-input"); err == nil {
+		t.Errorf("Expected an error with a negative window size")
+	}
+}
+
+func Test_ParseEvent(t *testing.T) {
+
+	for _, test := range []struct {
+		line           string
+		expectedMinute string
+		expectedError  error
+	}{
+		{`{"timestamp": "2018-12-26 18:11:08.509654","client_name": "airliberty","duration": 20}`, "2018-12-26 18:12:00", nil},
+		{`{"timestamp": "2018-12-26 18:11:00","duration": 20}`, "2018-12-26 18:12:00", nil},
+		{`{"timestamp": "2018-12-26 18:11","duration": 20}`, "2018-12-26 18:12:00", nil},
+		{`{"timestamp": "2018-12-26T18:11:08+01:00","duration": 20}`, "2018-12-26 17:12:00", nil},
+		{`{"timestamp": "2018-12-26 18:11:08","duration": [20]}`, "", &EventError{}},
+		{`{"timestamp": "2018-12-26 18:11:08","duration": 20,"duration_unit": "h"}`, "", &EventError{}},
+		{`not json`, "", &EventError{}},
+		{``, "", &EventError{}},
+		{`{"timestamp": "26/12/2018 18:11:08","duration": 20}`, "", &TimestampError{}},
+		{`{"duration": 20}`, "", &TimestampError{}},
+	} {
+		deliveredTranslation, minute, err := ParseEvent([]byte(test.line))
+
+		switch test.expectedError.(type) {
+		case nil:
+			if err != nil {
+				t.Errorf("Expected no error for %s, got %v", test.line, err)
+			} else if minute.Format("2006-01-02 15:04:05") != test.expectedMinute || deliveredTranslation.Duration != 20 {
+				t.Errorf("Expected the minute %s and the duration 20 for %s, got %s and %v", test.expectedMinute, test.line, minute, deliveredTranslation.Duration)
+			}
+		case *EventError:
+			var eventError *EventError
+			if !errors.As(err, &eventError) || !minute.IsZero() {
+				t.Errorf("Expected an event error for %s, got %v", test.line, err)
+			}
+		case *TimestampError:
+			var timestampError *TimestampError
+			if !errors.As(err, &timestampError) || !minute.IsZero() {
+				t.Errorf("Expected a timestamp error for %s, got %v", test.line, err)
+			}
+		}
+	}
+
+	// the minute is the same as the minute of the event in the output
+	var _, minute, _ = ParseEvent([]byte(`{"timestamp": "2018-12-26 18:11:08.509654","duration": 20}`))
+	if expected := time.Date(2018, 12, 26, 18, 12, 0, 0, time.UTC); !minute.Equal(expected) {
+		t.Errorf("Expected the minute %s, got %s", expected, minute)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// struct that paces the minutes of a file as if they were received in real time (--replay-speed)
+// the time of each minute is the time since the first minute divided by the speed, measured from the start of the replay
+// the times are measured with the clock of the options, so a late minute doesn't delay the next ones
+type replayPacer struct {
+	speed       float64
+	clock       func() time.Time
+	sleep       func(ctx context.Context, duration time.Duration)
+	firstMinute time.Time
+	start       time.Time
+}
+
+// function to create a pacer of the minutes from the first minute, it doesn't wait without --replay-speed
+func newReplayPacer(options options, firstMinute time.Time) *replayPacer {
+	return &replayPacer{speed: options.replaySpeed, clock: options.clock, sleep: options.sleep, firstMinute: firstMinute}
+}
+
+// function to wait until the time of a minute, the first minute starts the replay
+func (pacer *replayPacer) wait(ctx context.Context, minute time.Time) {
+	if pacer.speed <= 0 {
+		return
+	}
+	if pacer.start.IsZero() {
+		pacer.start = pacer.clock()
+		return
+	}
+
+	var due = pacer.start.Add(time.Duration(float64(minute.Sub(pacer.firstMinute)) / pacer.speed))
+	pacer.sleep(ctx, due.Sub(pacer.clock()))
+}
+
+// function to sleep for a duration, or until the context is canceled
+func sleepContext(ctx context.Context, duration time.Duration) {
+	if duration <= 0 {
+		return
+	}
+
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_runSeries_ReplaySpeed(t *testing.T) {
+
+	options, err := parseFlags([]string{"--input_file=./events.json", "--replay-speed=60"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a fake clock that moves forward when the replay sleeps, with the rows printed before each sleep
+	var console bytes.Buffer
+	var now = time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	var sleeps []time.Duration
+	var printedRows []int
+	options.clock = func() time.Time { return now }
+	options.sleep = func(ctx context.Context, duration time.Duration) {
+		sleeps = append(sleeps, duration)
+		printedRows = append(printedRows, strings.Count(console.String(), "\n"))
+		now = now.Add(duration)
+	}
+
+	start := time.Now()
+	if err := runSeries(context.Background(), options, &console, io.Discard); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected no real delays with the fake clock, took %v", elapsed)
+	}
+
+	// the same rows, in order, each one flushed before waiting one second for the next of the 14 minutes
+	if expected := getConsoleOutput(t, "--input_file=./events.json"); console.String() != expected {
+		t.Errorf("Expected the output without the replay, got %q", console.String())
+	}
+	if len(sleeps) != 13 {
+		t.Fatalf("Expected a wait before each minute but the first, got %v", sleeps)
+	}
+	for i, sleep := range sleeps {
+		if sleep != time.Second || printedRows[i] != i+1 {
+			t.Errorf("Expected to wait 1s after %d rows, waited %v after %d rows", i+1, sleep, printedRows[i])
+		}
+	}
+
+	if _, err := parseFlags([]string{"--replay-speed=-1"}); err == nil {
+		t.Errorf("Expected an error with a negative speed")
+	}
+	if _, err := parseFlags([]string{"--replay-speed=60", "--normalize"}); err == nil {
+		t.Errorf("Expected an error with --replay-speed and --normalize")
+	}
+}
+
+func Test_sleepContext(t *testing.T) {
+
+	// the sleep ends when the context is canceled
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	start := time.Now()
+	sleepContext(ctx, time.Hour)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected the sleep to end with the context, took %v", elapsed)
+	}
+}
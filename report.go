@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// function to parse the value of the --report flag into the options
+// the value is the name of the report, optionally followed by ":" and the number of lines of the report
+// the gaps report has no size, every gap is printed
+func parseReport(value string, options *options) error {
+	name, size, hasSize := strings.Cut(value, ":")
+
+	if name == "gaps" && !hasSize {
+		options.report = name
+		return nil
+	}
+	if name != "top-slow" {
+		return fmt.Errorf("unknown report %q, expected top-slow, top-slow:N or gaps", value)
+	}
+
+	options.report = name
+	options.reportSize = 10
+
+	if hasSize {
+		number, err := strconv.Atoi(size)
+		if err != nil || number <= 0 {
+			return fmt.Errorf("invalid size %q for report %s, expected a positive integer", size, name)
+		}
+		options.reportSize = number
+	}
+
+	return nil
+}
+
+// function to print the n minutes with the highest average, from the highest to the lowest
+// the minutes with the same average keep the chronological order of the series
+func printTopSlowReport(output io.Writer, series []PrintableValues, n int) {
+	var sorted = slices.Clone(series)
+
+	slices.SortStableFunc(sorted, func(a, b PrintableValues) int {
+		switch {
+		case a.Average_delivery_time > b.Average_delivery_time:
+			return -1
+		case a.Average_delivery_time < b.Average_delivery_time:
+			return 1
+		}
+		return 0
+	})
+
+	for i, printableValues := range sorted[:min(n, len(sorted))] {
+		fmt.Fprintf(output, "%d. %s %s\n", i+1, printableValues.Date, strconv.FormatFloat(printableValues.Average_delivery_time, 'f', -1, 64))
+	}
+}
+
+// struct with an interval of minutes without deliveries, the start and end minutes are included
+type deliveriesGap struct {
+	start  time.Time
+	end    time.Time
+	length int
+}
+
+// function to find the intervals of minutes without deliveries between the first and the last minute with deliveries
+// the minutes are the keys of the map of the minutes, so they are the dates of the output
+func findGaps(translationsDeliveriesData map[string]MinuteDeliveries, firstMinute, lastMinute time.Time, bucket time.Duration) []deliveriesGap {
+	var gaps []deliveriesGap
+	var current *deliveriesGap
+
+	for currentMinute := firstMinute; !currentMinute.After(lastMinute); currentMinute = currentMinute.Add(bucket) {
+		if _, ok := translationsDeliveriesData[currentMinute.Format("2006-01-02 15:04:05")]; ok {
+			current = nil
+			continue
+		}
+
+		if current == nil {
+			gaps = append(gaps, deliveriesGap{start: currentMinute})
+			current = &gaps[len(gaps)-1]
+		}
+		current.end = currentMinute
+		current.length++
+	}
+
+	return gaps
+}
+
+// function to print the gaps, one per line with the first and the last minute without deliveries and the number of minutes
+// like "2018-12-26 18:13:00 2018-12-26 18:15:00 3"
+func printGapsReport(output io.Writer, gaps []deliveriesGap) {
+	for _, gap := range gaps {
+		fmt.Fprintf(output, "%s %s %d\n", gap.start.Format("2006-01-02 15:04:05"), gap.end.Format("2006-01-02 15:04:05"), gap.length)
+	}
+}
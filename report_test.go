@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+)
+
+func Test_main_TopSlowReport(t *testing.T) {
+
+	var errorConsole bytes.Buffer
+
+	if err := run(context.Background(), []string{"--input_file=./events-template.json", "--report=top-slow:4"}, io.Discard, &errorConsole); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// the last minute has the average of 100, and the minutes from 18:26 to 18:33 have the average of 54
+	// the minutes with the same average are in chronological order
+	expected := `1. 2018-12-26 18:41:00 100
+2. 2018-12-26 18:26:00 54
+3. 2018-12-26 18:27:00 54
+4. 2018-12-26 18:28:00 54
+`
+	if errorConsole.String() != expected {
+		t.Errorf("Expected %q in the error console, got %q", expected, errorConsole.String())
+	}
+}
+
+func Test_main_GapsReport(t *testing.T) {
+
+	var errorConsole bytes.Buffer
+
+	if err := run(context.Background(), []string{"--input_file=./events-template.json", "--report=gaps"}, io.Discard, &errorConsole); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// the deliveries are in the minutes of 18:12, 18:16, 18:24 and 18:41, the minutes between them are the gaps
+	expected := `2018-12-26 18:13:00 2018-12-26 18:15:00 3
+2018-12-26 18:17:00 2018-12-26 18:23:00 7
+2018-12-26 18:25:00 2018-12-26 18:40:00 16
+`
+	if errorConsole.String() != expected {
+		t.Errorf("Expected %q in the error console, got %q", expected, errorConsole.String())
+	}
+
+	// the gaps are of the data, not of the range of the output
+	errorConsole.Reset()
+	if err := run(context.Background(), []string{"--input_file=./events-template.json", "--report=gaps", "--range-end=2018-12-26 19:00:00"}, io.Discard, &errorConsole); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if errorConsole.String() != expected {
+		t.Errorf("Expected %q in the error console with a wider range, got %q", expected, errorConsole.String())
+	}
+}
+
+func Test_parseReport_Invalid(t *testing.T) {
+
+	for _, value := range []string{"slowest", "top-slow:0", "top-slow:ten", "gaps:3"} {
+		if err := parseReport(value, &options{}); err == nil {
+			t.Errorf("Expected an error for report %q", value)
+		}
+	}
+}
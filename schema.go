@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+)
+
+// struct with every field of the events, the ones read into DeliveredTranslation and the ones that are not used
+// the events with other fields are rejected with the --strict-schema flag
+// the duration is a pointer, the events without a duration are also rejected
+type eventSchema struct {
+	DeliveredTranslation
+	Duration       *DeliveryDuration `json:"duration"`
+	Translation_id string            `json:"translation_id"`
+	Nr_words       json.RawMessage   `json:"nr_words"`
+}
+
+// function to check that a line only has the fields of the events, after renaming the mapped keys
+// returns the error of the first unknown field, like `json: unknown field "timstamp"`, or of a missing duration
+func checkEventSchema(line []byte, fieldMap fieldMap) error {
+	line, err := mapFields(line, fieldMap)
+	if err != nil {
+		return err
+	}
+
+	var decoder = json.NewDecoder(bytes.NewReader(line))
+	decoder.DisallowUnknownFields()
+
+	var event eventSchema
+	if err := decoder.Decode(&event); err != nil {
+		return err
+	}
+
+	if event.Duration == nil {
+		return errors.New(`missing field "duration"`)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_main_StrictSchema(t *testing.T) {
+
+	// the second event has a typo in the name of the timestamp
+	inputFilePath := filepath.Join(t.TempDir(), "events.json")
+	events := `{"timestamp": "2018-12-26 18:11:08","translation_id": "1","client_name": "airliberty","nr_words": 30,"duration": 20}
+{"timstamp": "2018-12-26 18:15:19","translation_id": "2","client_name": "airliberty","nr_words": 30,"duration": 31}
+{"timestamp": "2018-12-26 18:23:19","translation_id": "3","client_name": "taxi-eats","nr_words": 100,"duration": 54}
+`
+	if err := os.WriteFile(inputFilePath, []byte(events), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, arguments := range [][]string{{"--strict-schema"}, {"--strict-schema", "--assume-sorted"}} {
+		err := run(context.Background(), append(arguments, "--input_file="+inputFilePath), io.Discard, io.Discard)
+		if err == nil || !strings.Contains(err.Error(), `line 2: json: unknown field "timstamp"`) {
+			t.Errorf("Expected the unknown field of the line 2 with %v, got %v", arguments, err)
+		}
+	}
+
+	// the validate subcommand counts the event as invalid
+	var console bytes.Buffer
+	err := run(context.Background(), []string{"validate", "--strict-schema", "--input_file=" + inputFilePath}, &console, io.Discard)
+	if err == nil || !strings.Contains(console.String(), "invalid lines: 1") {
+		t.Errorf("Expected 1 invalid line, got %q and %v", console.String(), err)
+	}
+
+	// without the flag the line is skipped
+	if err := run(context.Background(), []string{"--input_file=" + inputFilePath}, io.Discard, io.Discard); err != nil {
+		t.Errorf("Expected no error without --strict-schema, got %v", err)
+	}
+
+	// the fields of the sample events and the mapped names are in the schema
+	if err := run(context.Background(), []string{"--strict-schema", "--input_file=./events.json"}, io.Discard, io.Discard); err != nil {
+		t.Errorf("Expected the sample events to match the schema, got %v", err)
+	}
+	if err := checkEventSchema([]byte(`{"ts": "2018-12-26 18:11:08","duration": 20}`), fieldMap{"timestamp": "ts"}); err != nil {
+		t.Errorf("Expected the mapped timestamp to match the schema, got %v", err)
+	}
+}
+
+func Test_main_MissingDuration(t *testing.T) {
+
+	// the second event has no duration and the third has an explicit 0
+	inputFilePath := filepath.Join(t.TempDir(), "events.json")
+	events := `{"timestamp": "2018-12-26 18:11:08","duration": 20}
+{"timestamp": "2018-12-26 18:12:08"}
+{"timestamp": "2018-12-26 18:13:08","duration": 0}
+`
+	if err := os.WriteFile(inputFilePath, []byte(events), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// without --strict-schema both are deliveries of 0, in the throughput but not in the average, like the minutes without deliveries
+	series, err := Compute(context.Background(), strings.NewReader(events), WithThroughput())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last := series[len(series)-1]; last.Average_delivery_time != 20 || *last.Throughput != 0.75 {
+		t.Errorf("Expected the average 20 and 3 deliveries in the 4 minutes of the window, got %v and %v", last.Average_delivery_time, *last.Throughput)
+	}
+
+	statsFilePath := filepath.Join(t.TempDir(), "stats.json")
+	if err := run(context.Background(), []string{"--input_file=" + inputFilePath, "--stats-json=" + statsFilePath}, io.Discard, io.Discard); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(statsFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var statistics EventsStatistics
+	if err := json.Unmarshal(content, &statistics); err != nil {
+		t.Fatal(err)
+	}
+	if statistics.Total_events != 3 || statistics.Missing_durations != 1 {
+		t.Errorf("Expected 3 events and 1 missing duration, got %+v", statistics)
+	}
+
+	// with --strict-schema the missing duration is an error, the explicit 0 is not
+	err = run(context.Background(), []string{"--strict-schema", "--input_file=" + inputFilePath}, io.Discard, io.Discard)
+	if err == nil || !strings.Contains(err.Error(), `line 2: missing field "duration"`) {
+		t.Errorf("Expected the missing duration of the line 2, got %v", err)
+	}
+	if err := checkEventSchema([]byte(`{"timestamp": "2018-12-26 18:13:08","duration": 0}`), nil); err != nil {
+		t.Errorf("Expected the explicit 0 to match the schema, got %v", err)
+	}
+	if err := checkEventSchema([]byte(`{"timestamp": "2018-12-26 18:13:08","duration": null}`), nil); err == nil {
+		t.Errorf("Expected the null duration to be missing")
+	}
+
+	// the explicit 0 is not marked as missing
+	for line, missing := range map[string]bool{`{"duration": 0}`: false, `{"duration": null}`: true, `{}`: true, `{"duration": "0"}`: false} {
+		deliveredTranslation, err := parseDeliveredTranslation([]byte(line), nil)
+		if err != nil || deliveredTranslation.durationMissing != missing {
+			t.Errorf("Expected the duration of %s to be missing %v, got %+v and %v", line, missing, deliveredTranslation, err)
+		}
+	}
+}
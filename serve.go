@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// function to listen for HTTP requests and serve the moving averages of the input file (--serve)
+// runs until the context is canceled
+func listenAndServeHTTP(ctx context.Context, options options, stderr io.Writer) error {
+	listener, err := net.Listen("tcp", options.serve)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(stderr, "serving the moving average on", listener.Addr())
+	return serveHTTP(ctx, listener, options)
+}
+
+// function to serve the requests of the listener until the context is canceled
+// closing the server stops serving and closes the open requests
+func serveHTTP(ctx context.Context, listener net.Listener, options options) error {
+	var server = &http.Server{Handler: newMovingAverageHandler(options)}
+
+	stop := context.AfterFunc(ctx, func() { server.Close() })
+	defer stop()
+
+	err := server.Serve(listener)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+// function to create the handler of the /moving-average path
+// each request reads the input file and calculates the whole series, and responds with the page of offset and limit
+// the number of minutes of the series is in the X-Total-Count header, so the clients know how many pages there are
+// with --metrics-addr the requests are counted with their durations, and the counters are also served in /metrics
+func newMovingAverageHandler(options options) http.Handler {
+	var mux = http.NewServeMux()
+
+	if options.metrics != nil {
+		mux.Handle("/metrics", newMetricsHandler(options.metrics))
+	}
+
+	mux.HandleFunc("/moving-average", func(writer http.ResponseWriter, request *http.Request) {
+		var received = time.Now()
+		defer func() { options.metrics.addRequest(time.Since(received)) }()
+
+		if request.Method != http.MethodGet {
+			http.Error(writer, "method not allowed, expected GET", http.StatusMethodNotAllowed)
+			return
+		}
+
+		offset, err := pageParameter(request, "offset")
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusBadRequest)
+			return
+		}
+		limit, err := pageParameter(request, "limit")
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		file, err := os.Open(options.inputFilePath)
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer file.Close()
+
+		series, err := computeSeries(request.Context(), file, options)
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// the pages after the end of the series are empty
+		// the limit is compared with the minutes left instead of added to the start, so a huge limit doesn't overflow
+		var start = min(offset, len(series))
+		var end = len(series)
+		if limit > 0 && limit < len(series)-start {
+			end = start + limit
+		}
+
+		// the page is never null, an empty page is an empty array
+		var page = append([]PrintableValues{}, series[start:end]...)
+
+		writer.Header().Set("Content-Type", "application/json")
+		writer.Header().Set("X-Total-Count", strconv.Itoa(len(series)))
+		json.NewEncoder(writer).Encode(page)
+	})
+
+	return mux
+}
+
+// function to read a query parameter of the page, a number greater or equal to 0, or 0 if it is not set
+func pageParameter(request *http.Request, name string) (int, error) {
+	var value = request.URL.Query().Get(name)
+	if value == "" {
+		return 0, nil
+	}
+
+	number, err := strconv.Atoi(value)
+	if err != nil || number < 0 {
+		return 0, fmt.Errorf("invalid %s %q, expected an integer greater or equal to 0", name, value)
+	}
+	return number, nil
+}
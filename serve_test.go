@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_newMovingAverageHandler(t *testing.T) {
+
+	options, err := parseFlags([]string{"--input_file=./events.json"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(newMovingAverageHandler(options))
+	defer server.Close()
+
+	// the whole series of the file, 14 minutes from 18:11 to 18:24
+	file, err := os.Open("./events.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	series, err := Compute(context.Background(), file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		query    string
+		expected []PrintableValues
+	}{
+		{"", series},
+		{"?limit=5", series[:5]},
+		{"?offset=5&limit=5", series[5:10]},
+		{"?offset=10&limit=5", series[10:]},
+		{"?offset=13", series[13:]},
+		{"?offset=14", []PrintableValues{}},
+		{"?offset=100&limit=5", []PrintableValues{}},
+		{"?limit=100", series},
+		{"?offset=1&limit=9223372036854775807", series[1:]},
+		{"?offset=9223372036854775807&limit=9223372036854775807", []PrintableValues{}},
+	} {
+		response, err := http.Get(server.URL + "/moving-average" + test.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var page []PrintableValues
+		err = json.NewDecoder(response.Body).Decode(&page)
+		response.Body.Close()
+		if err != nil {
+			t.Fatalf("Expected a JSON array with %q: %v", test.query, err)
+		}
+
+		if !reflect.DeepEqual(page, test.expected) {
+			t.Errorf("Expected %v with %q, got %v", test.expected, test.query, page)
+		}
+		if total := response.Header.Get("X-Total-Count"); total != "14" {
+			t.Errorf("Expected the total of 14 minutes with %q, got %q", test.query, total)
+		}
+	}
+
+	// the parameters that are not valid are a bad request
+	for _, query := range []string{"?limit=-1", "?offset=-1", "?offset=abc", "?offset=1.5", "?limit=9223372036854775808"} {
+		response, err := http.Get(server.URL + "/moving-average" + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response.Body.Close()
+
+		if response.StatusCode != http.StatusBadRequest {
+			t.Errorf("Expected a bad request with %q, got %d", query, response.StatusCode)
+		}
+	}
+}
+
+func Test_serveHTTP(t *testing.T) {
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	options, err := parseFlags([]string{"--input_file=./events.json", "--serve=127.0.0.1:0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error)
+	go func() {
+		served <- serveHTTP(ctx, listener, options)
+	}()
+
+	response, err := http.Get("http://" + listener.Addr().String() + "/moving-average?limit=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK || response.Header.Get("X-Total-Count") != "14" {
+		t.Errorf("Expected the first page, got %d with the total %q", response.StatusCode, response.Header.Get("X-Total-Count"))
+	}
+
+	cancel()
+	if err := <-served; err != context.Canceled {
+		t.Errorf("Expected the server to stop when canceled, got %v", err)
+	}
+
+	if _, err := parseFlags([]string{"--serve=:8080", "--raw"}); err == nil {
+		t.Errorf("Expected an error with --serve and --raw")
+	}
+}
+
+func Test_newMovingAverageHandler_Metrics(t *testing.T) {
+
+	// two events and a line that is not valid
+	inputFilePath := filepath.Join(t.TempDir(), "events.json")
+	events := `{"timestamp": "2018-12-26 18:11:08","duration": 20}` + "\nnot json\n" + `{"timestamp": "2018-12-26 18:15:19","duration": 31}` + "\n"
+	if err := os.WriteFile(inputFilePath, []byte(events), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	options, err := parseFlags([]string{"--input_file=" + inputFilePath, "--serve=127.0.0.1:0", "--metrics-addr=127.0.0.1:0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	options.metrics = &runMetrics{}
+
+	// the counters are served in the address of --metrics-addr and in the one of --serve
+	metricsListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stopServer := serveMetrics(metricsListener, options.metrics)
+	defer stopServer()
+
+	server := httptest.NewServer(newMovingAverageHandler(options))
+	defer server.Close()
+
+	for _, url := range []string{"http://" + metricsListener.Addr().String() + "/metrics", server.URL + "/metrics"} {
+		if body := getMetrics(t, url); !strings.Contains(body, "translations_request_duration_seconds_count 0\n") {
+			t.Errorf("Expected no requests before the first one in %s, got %q", url, body)
+		}
+	}
+
+	response, err := http.Get(server.URL + "/moving-average")
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.Copy(io.Discard, response.Body)
+	response.Body.Close()
+
+	var expected = []string{
+		"translations_events_total 2\n",
+		"translations_skipped_lines_total 1\n",
+		"# TYPE translations_request_duration_seconds summary\n",
+		"translations_request_duration_seconds_count 1\n",
+	}
+	for _, url := range []string{"http://" + metricsListener.Addr().String() + "/metrics", server.URL + "/metrics"} {
+		body := getMetrics(t, url)
+		for _, line := range expected {
+			if !strings.Contains(body, line) {
+				t.Errorf("Expected %q in %s after the request, got %q", line, url, body)
+			}
+		}
+	}
+
+	// without --metrics-addr there is no /metrics path
+	options.metrics = nil
+	withoutMetrics := httptest.NewServer(newMovingAverageHandler(options))
+	defer withoutMetrics.Close()
+
+	response, err = http.Get(withoutMetrics.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected no metrics without --metrics-addr, got %d", response.StatusCode)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+// function to read the events of a file sorted by timestamp and add them to a stream (--assume-sorted)
+// only the minute being read is kept in memory, instead of a map with all the minutes of the file
+// returns an error if an event is in a minute before the minute being read, the file is not sorted
+// returns the statistics of the events read so far
+func readSortedEvents(ctx context.Context, reader io.Reader, stream *eventsStream) (EventsStatistics, error) {
+	var options = stream.window.options
+	var statistics = EventsStatistics{clientDeliveries: make(map[string]int)}
+
+	err := readEvents(ctx, reader, options, &statistics, func(event inputEvent) error {
+		if options.align == "data" && stream.origin.IsZero() {
+			stream.origin = event.eventTime
+		}
+
+		// the minutes before the minute of the event are completed and emitted
+		duration := int(event.deliveredTranslation.Duration)
+		stream.add(eventMinute(event.eventTime, stream.origin, options), duration)
+
+		// the stream skips the events of completed minutes, but with a file that means it is not sorted
+		if stream.outOfOrderEvents > 0 {
+			return fmt.Errorf("line %d: the timestamp %s is before the previous events, the input is not sorted", event.lineNumber, event.deliveredTranslation.Timestamp)
+		}
+
+		statistics.add(duration, event.deliveredTranslation.Client_name)
+		return nil
+	})
+	if err != nil {
+		return statistics, err
+	}
+
+	// the last minute is only completed if the whole file was read
+	if ctx.Err() == nil {
+		stream.close()
+	}
+
+	return statistics, nil
+}
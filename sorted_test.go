@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// function to run the program and return what it wrote to the console and to stderr
+func getConsoleAndStderr(t *testing.T, arguments ...string) (string, string) {
+
+	var console, stderr bytes.Buffer
+
+	if err := run(context.Background(), arguments, &console, &stderr); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	return console.String(), stderr.String()
+}
+
+func Test_main_AssumeSortedSameAsGeneral(t *testing.T) {
+
+	for _, arguments := range [][]string{
+		{"--input_file=./events.json"},
+		{"--input_file=./events-template.json", "--window_size=3", "--diff", "--with-window-span"},
+		{"--input_file=./events-template.json", "--normalize", "--full-window-only", "--report=top-slow:3"},
+		{"--input_file=./events.json", "--explode", "--top-n-clients=2", "--sla=25", "--format=csv"},
+		{"--input_file=./events.json", "--align=data", "--bucket=30s"},
+	} {
+		console, stderr := getConsoleAndStderr(t, arguments...)
+		sortedConsole, sortedStderr := getConsoleAndStderr(t, append(arguments, "--assume-sorted")...)
+
+		if sortedConsole != console {
+			t.Errorf("Expected the same output with --assume-sorted and %v, got\n%s\ninstead of\n%s", arguments, sortedConsole, console)
+		}
+		if sortedStderr != stderr {
+			t.Errorf("Expected the same stderr with --assume-sorted and %v, got\n%s\ninstead of\n%s", arguments, sortedStderr, stderr)
+		}
+	}
+}
+
+func Test_main_AssumeSortedStatistics(t *testing.T) {
+
+	statsFilePath := filepath.Join(t.TempDir(), "stats.json")
+	sortedStatsFilePath := filepath.Join(t.TempDir(), "sorted-stats.json")
+	getConsoleOutput(t, "--input_file=./events-template.json", "--stats-json="+statsFilePath)
+	getConsoleOutput(t, "--input_file=./events-template.json", "--stats-json="+sortedStatsFilePath, "--assume-sorted")
+
+	statistics, err := os.ReadFile(statsFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sortedStatistics, err := os.ReadFile(sortedStatsFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(sortedStatistics, statistics) {
+		t.Errorf("Expected the same statistics with --assume-sorted, got\n%s\ninstead of\n%s", sortedStatistics, statistics)
+	}
+}
+
+func Test_main_AssumeSortedDetectsDisorder(t *testing.T) {
+
+	inputFilePath := filepath.Join(t.TempDir(), "events.json")
+	events := `{"timestamp": "2018-12-26 18:11:08","duration": 20}
+{"timestamp": "2018-12-26 18:15:19","duration": 31}
+{"timestamp": "2018-12-26 18:12:19","duration": 54}
+`
+	if err := os.WriteFile(inputFilePath, []byte(events), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var console bytes.Buffer
+	err := run(context.Background(), []string{"--input_file=" + inputFilePath, "--assume-sorted"}, &console, io.Discard)
+	if err == nil || !strings.Contains(err.Error(), "line 3") {
+		t.Fatalf("Expected an error for the line 3, got %v", err)
+	}
+
+	// the minutes before the unsorted event are written
+	data := parseConsoleContent(t, console.Bytes())
+	if len(data) != 5 || data[len(data)-1].Date != "2018-12-26 18:15:00" {
+		t.Errorf("Expected the minutes up to 18:15 to be written, got %v", data)
+	}
+
+	// the general path sorts the events in the map
+	if err := run(context.Background(), []string{"--input_file=" + inputFilePath}, io.Discard, io.Discard); err != nil {
+		t.Errorf("Expected no error without --assume-sorted, got %v", err)
+	}
+
+	// the events of the same minute can be in any order
+	events = `{"timestamp": "2018-12-26 18:11:38","duration": 20}
+{"timestamp": "2018-12-26 18:11:08","duration": 31}
+`
+	if err := os.WriteFile(inputFilePath, []byte(events), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := run(context.Background(), []string{"--input_file=" + inputFilePath, "--assume-sorted"}, io.Discard, io.Discard); err != nil {
+		t.Errorf("Expected no error for events of the same minute, got %v", err)
+	}
+}
+
+func Test_main_RequireSorted(t *testing.T) {
+
+	// the third event is in the same minute as the second, but before it
+	inputFilePath := filepath.Join(t.TempDir(), "events.json")
+	events := `{"timestamp": "2018-12-26 18:11:08","duration": 20}
+{"timestamp": "2018-12-26 18:15:39","duration": 31}
+{"timestamp": "2018-12-26 18:15:19","duration": 54}
+{"timestamp": "2018-12-26 18:12:19","duration": 54}
+`
+	if err := os.WriteFile(inputFilePath, []byte(events), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// the error has the line of the first violation, with or without --assume-sorted
+	for _, arguments := range [][]string{{"--require-sorted"}, {"--require-sorted", "--assume-sorted"}, {"--require-sorted", "--parse-workers=2"}} {
+		err := run(context.Background(), append(arguments, "--input_file="+inputFilePath), io.Discard, io.Discard)
+		if err == nil || err.Error() != "line 3: the timestamp 2018-12-26 18:15:19 is before the timestamp 2018-12-26 18:15:39 of a previous event, the input is not sorted" {
+			t.Errorf("Expected the error of the line 3 with %v, got %v", arguments, err)
+		}
+	}
+
+	// a sorted input has the same output as without the flag
+	if expected, output := getContentFromConsole(t, "--input_file=./events.json"), getContentFromConsole(t, "--input_file=./events.json", "--require-sorted"); len(output) == 0 || !reflect.DeepEqual(output, expected) {
+		t.Errorf("Expected the output %v, got %v", expected, output)
+	}
+}
+
+// function to write a sorted file with one event per minute for the benchmarks
+func writeSortedEventsFile(b *testing.B, minutes int) string {
+
+	var events strings.Builder
+	for i := 0; i < minutes; i++ {
+		fmt.Fprintf(&events, "{\"timestamp\": \"2018-12-%02d %02d:%02d:10\",\"duration\": %d}\n", 1+i/1440, i/60%24, i%60, 10+i%50)
+	}
+
+	inputFilePath := filepath.Join(b.TempDir(), "events.json")
+	if err := os.WriteFile(inputFilePath, []byte(events.String()), 0644); err != nil {
+		b.Fatal(err)
+	}
+
+	return inputFilePath
+}
+
+// the general path keeps a map with every minute of the file
+func BenchmarkRunGeneral(b *testing.B) {
+
+	inputFilePath := writeSortedEventsFile(b, 20000)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := run(context.Background(), []string{"--input_file=" + inputFilePath}, io.Discard, io.Discard); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// the fast path only keeps the minute being read
+func BenchmarkRunAssumeSorted(b *testing.B) {
+
+	inputFilePath := writeSortedEventsFile(b, 20000)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := run(context.Background(), []string{"--input_file=" + inputFilePath, "--assume-sorted"}, io.Discard, io.Discard); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
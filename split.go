@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// struct with the group of an event (--group-by), only the fields of the group are set
+// source and target: the languages of the event, the source is not set with --group-by=target_language
+// stream: the stream of the event in a multiplexed input, only set with --group-by=stream_id
+type eventGroup struct {
+	source string
+	target string
+	stream string
+}
+
+// function to get the group of an event
+// the group is the language pair, only the target language with --group-by=target_language, or the stream with --group-by=stream_id
+func groupOf(deliveredTranslation DeliveredTranslation, groupBy string) eventGroup {
+	switch groupBy {
+	case "target_language":
+		return eventGroup{target: deliveredTranslation.Target_language}
+	case "stream_id":
+		return eventGroup{stream: deliveredTranslation.Stream_id}
+	}
+	return eventGroup{source: deliveredTranslation.Source_language, target: deliveredTranslation.Target_language}
+}
+
+// function to get the name of the file of a group, like "en-fr", "fr" with --group-by=target_language
+// or the id of the stream with --group-by=stream_id
+func (group eventGroup) fileName(groupBy string) string {
+	switch groupBy {
+	case "target_language":
+		return safeFileName(group.target)
+	case "stream_id":
+		return safeFileName(group.stream)
+	}
+	return safeFileName(group.source) + "-" + safeFileName(group.target)
+}
+
+// function to get the name of a group as it is in the events, like "en-fr", used in the messages
+func (group eventGroup) name(groupBy string) string {
+	switch groupBy {
+	case "target_language":
+		return group.target
+	case "stream_id":
+		return group.stream
+	}
+	return group.source + "-" + group.target
+}
+
+// function to get a name of a group that can be used in a file name
+// the characters that can't be in a file name are replaced, so a language or a stream can't write outside the directory
+func safeFileName(name string) string {
+	if name == "" {
+		return "unknown"
+	}
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == '.' {
+			return '_'
+		}
+		return r
+	}, name)
+}
+
+// function to get the file names of the groups, in the same order, without two groups in the same file
+// different groups can have the same name once it is made safe, like "a.b" and "a/b", or an empty group and "unknown"
+// the first of them keeps the name and the next ones get a suffix, like "a_b-2", that is not the name of another group
+func uniqueFileNames(groups []eventGroup, groupBy string) []string {
+	var names = make([]string, len(groups))
+	var used = make(map[string]bool)
+	for i, group := range groups {
+		names[i] = group.fileName(groupBy)
+		used[names[i]] = true
+	}
+
+	var assigned = make(map[string]bool)
+	for i, name := range names {
+		if !assigned[name] {
+			assigned[name] = true
+			continue
+		}
+
+		for suffix := 2; ; suffix++ {
+			if unique := fmt.Sprintf("%s-%d", name, suffix); !used[unique] {
+				names[i] = unique
+				used[unique] = true
+				break
+			}
+		}
+	}
+
+	return names
+}
+
+// function to write the series of each language pair of the input file to its own file (--split-output-dir)
+// the input is read once to find the pairs, and once more for each pair, so only one series is in memory at a time
+// the pairs are written in order, by source and target language
+// with --group-by=target_language the groups are the target languages, with the events of every source language,
+// and with --group-by=stream_id the streams of the input
+func splitByLanguagePair(ctx context.Context, options options, stdout io.Writer, stderr io.Writer) error {
+	if err := os.MkdirAll(options.splitOutputDir, 0755); err != nil {
+		return err
+	}
+
+	groups, err := readGroups(ctx, options)
+	if err != nil {
+		return err
+	}
+
+	var extension = ".json"
+	if options.format == "csv" {
+		extension = ".csv"
+	}
+	if options.gzipOutput {
+		extension += ".gz"
+	}
+
+	// the groups with the same file name are written to files with a suffix instead of replacing the file of the first one
+	var fileNames = uniqueFileNames(groups, options.groupBy)
+	for i, group := range groups {
+		if fileName := group.fileName(options.groupBy); fileNames[i] != fileName {
+			fmt.Fprintf(stderr, "warning: the file name %s is of another group, the group %q is written to %s\n", fileName+extension, group.name(options.groupBy), fileNames[i]+extension)
+		}
+
+		var groupOptions = options
+		groupOptions.group = &group
+		groupOptions.outputFilePath = filepath.Join(options.splitOutputDir, fileNames[i]+extension)
+
+		if err := runSeries(ctx, groupOptions, stdout, stderr); err != nil {
+			return err
+		}
+	}
+
+	return ctx.Err()
+}
+
+// function to read the groups of the events of the input file, sorted by source and target language, or by stream
+// with --group-by=target_language the groups only have the target language, and with --group-by=stream_id only the stream
+func readGroups(ctx context.Context, options options) ([]eventGroup, error) {
+	file, err := os.Open(options.inputFilePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	// the events are only counted by the metrics when the series are calculated
+	options.metrics = nil
+
+	var found = make(map[eventGroup]bool)
+	var statistics EventsStatistics
+	err = readEvents(ctx, file, options, &statistics, func(event inputEvent) error {
+		found[groupOf(event.deliveredTranslation, options.groupBy)] = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var groups = make([]eventGroup, 0, len(found))
+	for group := range found {
+		groups = append(groups, group)
+	}
+	slices.SortFunc(groups, func(a, b eventGroup) int {
+		if a.source != b.source {
+			return strings.Compare(a.source, b.source)
+		}
+		if a.target != b.target {
+			return strings.Compare(a.target, b.target)
+		}
+		return strings.Compare(a.stream, b.stream)
+	})
+
+	return groups, nil
+}
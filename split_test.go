@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_main_SplitOutputDir(t *testing.T) {
+
+	var directory = t.TempDir()
+	enFr := `{"timestamp": "2018-12-26 18:11:08","source_language": "en","target_language": "fr","duration": 20}
+{"timestamp": "2018-12-26 18:15:19","source_language": "en","target_language": "fr","duration": 31}
+`
+	enDe := `{"timestamp": "2018-12-26 18:12:30","source_language": "en","target_language": "de","duration": 40}
+{"timestamp": "2018-12-26 18:13:10","source_language": "en","target_language": "de","duration": 10}
+`
+	// a language with a path can't write outside the directory
+	unsafe := `{"timestamp": "2018-12-26 18:14:00","source_language": "en","target_language": "../x","duration": 54}
+`
+
+	// the events of the pairs are interleaved in the input
+	enFrLines, enDeLines := strings.SplitAfter(enFr, "\n"), strings.SplitAfter(enDe, "\n")
+	var inputFilePath = filepath.Join(directory, "events.json")
+	if err := os.WriteFile(inputFilePath, []byte(enFrLines[0]+enDeLines[0]+enDeLines[1]+unsafe+enFrLines[1]), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var outputDirectory = filepath.Join(directory, "out", "pairs")
+	if err := run(context.Background(), []string{"--input_file=" + inputFilePath, "--window_size=2", "--split-output-dir=" + outputDirectory}, io.Discard, io.Discard); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := os.ReadDir(outputDirectory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if expected := []string{"en-___x.json", "en-de.json", "en-fr.json"}; !reflect.DeepEqual(names, expected) {
+		t.Fatalf("Expected the files %v, got %v", expected, names)
+	}
+
+	// each file is the same series as an input with only the events of its pair
+	for name, events := range map[string]string{"en-fr.json": enFr, "en-de.json": enDe, "en-___x.json": unsafe} {
+		pairFilePath := filepath.Join(directory, "pair.json")
+		if err := os.WriteFile(pairFilePath, []byte(events), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		content, err := os.ReadFile(filepath.Join(outputDirectory, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := getContentFromConsole(t, "--input_file="+pairFilePath, "--window_size=2"); len(expected) == 0 || !reflect.DeepEqual(parseConsoleContent(t, content), expected) {
+			t.Errorf("Expected %v in %s, got %s", expected, name, content)
+		}
+	}
+
+	// a single output file would be written by every pair
+	if err := run(context.Background(), []string{"--input_file=" + inputFilePath, "--split-output-dir=" + outputDirectory, "--output_file=" + filepath.Join(directory, "output.json")}, io.Discard, io.Discard); err == nil {
+		t.Errorf("Expected an error with --split-output-dir and --output_file")
+	}
+}
+
+func Test_main_GroupByTargetLanguage(t *testing.T) {
+
+	var directory = t.TempDir()
+	fr := `{"timestamp": "2018-12-26 18:11:08","source_language": "en","target_language": "fr","duration": 20}
+{"timestamp": "2018-12-26 18:13:10","source_language": "de","target_language": "fr","duration": 10}
+{"timestamp": "2018-12-26 18:15:19","source_language": "en","target_language": "fr","duration": 31}
+`
+	es := `{"timestamp": "2018-12-26 18:12:30","source_language": "en","target_language": "es","duration": 40}
+`
+
+	// the events of the languages are interleaved in the input
+	frLines := strings.SplitAfter(fr, "\n")
+	var inputFilePath = filepath.Join(directory, "events.json")
+	if err := os.WriteFile(inputFilePath, []byte(frLines[0]+es+frLines[1]+frLines[2]), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var outputDirectory = filepath.Join(directory, "out")
+	if err := run(context.Background(), []string{"--input_file=" + inputFilePath, "--window_size=2", "--group-by=target_language", "--output-dir=" + outputDirectory}, io.Discard, io.Discard); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := os.ReadDir(outputDirectory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if expected := []string{"es.json", "fr.json"}; !reflect.DeepEqual(names, expected) {
+		t.Fatalf("Expected the files %v, got %v", expected, names)
+	}
+
+	// each file is the same series as an input with only the events of its target language
+	for name, events := range map[string]string{"fr.json": fr, "es.json": es} {
+		languageFilePath := filepath.Join(directory, "language.json")
+		if err := os.WriteFile(languageFilePath, []byte(events), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		content, err := os.ReadFile(filepath.Join(outputDirectory, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := getContentFromConsole(t, "--input_file="+languageFilePath, "--window_size=2"); len(expected) == 0 || !reflect.DeepEqual(parseConsoleContent(t, content), expected) {
+			t.Errorf("Expected %v in %s, got %s", expected, name, content)
+		}
+	}
+
+	// the output directory can't be created where there is a file
+	if err := run(context.Background(), []string{"--input_file=" + inputFilePath, "--group-by=target_language", "--output-dir=" + inputFilePath}, io.Discard, io.Discard); err == nil {
+		t.Errorf("Expected an error with a file as the output directory")
+	}
+
+	// the group is only used by the output directory
+	if err := run(context.Background(), []string{"--input_file=" + inputFilePath, "--group-by=target_language"}, io.Discard, io.Discard); err == nil {
+		t.Errorf("Expected an error with --group-by without --output-dir")
+	}
+	if err := run(context.Background(), []string{"--input_file=" + inputFilePath, "--group-by=client", "--output-dir=" + outputDirectory}, io.Discard, io.Discard); err == nil {
+		t.Errorf("Expected an error with an invalid group")
+	}
+}
+
+func Test_main_SplitOutputDirCollidingNames(t *testing.T) {
+
+	// streams with different ids and the same safe name, and an empty id with the name of the "unknown" stream
+	var durations = map[string]int{"": 10, "a.b": 20, "a/b": 30, "a_b": 40, "a_b-2": 50, "unknown": 60}
+	var events strings.Builder
+	for streamId, duration := range durations {
+		fmt.Fprintf(&events, `{"timestamp": "2018-12-26 18:11:08","duration": %d,"stream_id": %q}`+"\n", duration, streamId)
+	}
+
+	var directory = t.TempDir()
+	var inputFilePath = filepath.Join(directory, "events.json")
+	if err := os.WriteFile(inputFilePath, []byte(events.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var outputDirectory = filepath.Join(directory, "out")
+	var stderr bytes.Buffer
+	if err := run(context.Background(), []string{"--input_file=" + inputFilePath, "--group-by=stream_id", "--output-dir=" + outputDirectory}, io.Discard, &stderr); err != nil {
+		t.Fatal(err)
+	}
+
+	// the first group of a name keeps it, by the order of the ids, and the next ones get a suffix that is not the name of another group
+	for fileName, duration := range map[string]int{"unknown.json": 10, "a_b.json": 20, "a_b-3.json": 30, "a_b-4.json": 40, "a_b-2.json": 50, "unknown-2.json": 60} {
+		content, err := os.ReadFile(filepath.Join(outputDirectory, fileName))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if data := parseConsoleContent(t, content); data[len(data)-1].Average_delivery_time != float64(duration) {
+			t.Errorf("Expected the stream with the duration %d in %s, got %s", duration, fileName, content)
+		}
+	}
+	if entries, err := os.ReadDir(outputDirectory); err != nil || len(entries) != len(durations) {
+		t.Errorf("Expected a file for each of the %d streams, got %d and %v", len(durations), len(entries), err)
+	}
+	if !strings.Contains(stderr.String(), `warning: the file name a_b.json is of another group, the group "a/b" is written to a_b-3.json`) {
+		t.Errorf("Expected a warning for the renamed groups, got %q", stderr.String())
+	}
+}
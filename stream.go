@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"time"
+)
+
+// struct that calculates the moving averages of a stream of events as they arrive
+// the events must be ordered by timestamp, a minute is complete when an event of a later minute arrives
+type eventsStream struct {
+	window movingWindow
+
+	// function called with the values of every complete minute that must be printed
+	emit func(PrintableValues)
+
+	// the minute that is receiving events and its deliveries so far
+	currentMinute     time.Time
+	currentMinuteData MinuteDeliveries
+
+	// time of the first event, the minutes start at it with --align=data
+	origin time.Time
+
+	// the events repeated within the --dedup-window are dropped
+	deduplicator *eventsDeduplicator
+
+	// number of events older than the current minute, they are skipped
+	outOfOrderEvents int
+}
+
+// function to create a stream that calls emit with the values of every complete minute
+func newEventsStream(options options, emit func(PrintableValues)) *eventsStream {
+	return &eventsStream{window: movingWindow{options: options}, emit: emit, deduplicator: newEventsDeduplicator(options.dedupWindow, options.dedupeBy)}
+}
+
+// function to parse a line with an event and add its delivery to the stream
+// the lines that are not valid are skipped, like in the files
+// returns true if the event completed at least one minute
+func (stream *eventsStream) addLine(line []byte) bool {
+	var options = stream.window.options
+
+	deliveredTranslation, err := parseDeliveredTranslation(line, options.inputFieldMap)
+	if err != nil || (options.strictSchema && checkEventSchema(line, options.inputFieldMap) != nil) {
+		options.metrics.addSkippedLine()
+		return false
+	}
+
+	eventTime, err := parseTimestamp(deliveredTranslation.Timestamp)
+	if err != nil || (options.noFutureMinutes && eventTime.After(options.clock())) {
+		options.metrics.addSkippedLine()
+		return false
+	}
+	if !options.clients.includes(deliveredTranslation.Client_name) {
+		return false
+	}
+	if options.businessHours != nil && !options.businessHours.contains(eventTime) {
+		return false
+	}
+	deliveredTranslation.normalizeDuration(options.durationUnit)
+	deliveredTranslation.clampDuration(options.maxDuration)
+	if options.dedupeBy != "" {
+		deliveredTranslation.dedupKey = readDedupKey(line, options.dedupeBy)
+	}
+	if options.dedupWindow > 0 && stream.deduplicator.isDuplicate(deliveredTranslation, eventTime) {
+		return false
+	}
+	if options.align == "data" && stream.origin.IsZero() {
+		stream.origin = eventTime
+	}
+
+	options.metrics.addEvent()
+	return stream.add(eventMinute(eventTime, stream.origin, options), int(deliveredTranslation.Duration))
+}
+
+// function to add a delivery to the stream
+// the minutes before the minute of the delivery are completed, including the ones without deliveries
+// returns true if at least one minute was completed
+func (stream *eventsStream) add(minute time.Time, duration int) bool {
+	var completed bool
+
+	// like in the files, the output starts one minute before the first delivery, unless the timestamps are rounded down
+	if stream.currentMinute.IsZero() {
+		stream.currentMinute = minute.Add(-labelOffset(stream.window.options))
+	}
+
+	if minute.Before(stream.currentMinute) {
+		stream.outOfOrderEvents++
+		return false
+	}
+
+	for stream.currentMinute.Before(minute) {
+		stream.completeMinute()
+		completed = true
+	}
+
+	stream.currentMinuteData.add(duration, stream.window.options.explode)
+	return completed
+}
+
+// function to calculate the values of the current minute and move to the next one
+func (stream *eventsStream) completeMinute() {
+	if printableValues, printable := stream.window.next(stream.currentMinute, stream.currentMinuteData); printable {
+		stream.emit(printableValues)
+	}
+
+	stream.currentMinute = stream.currentMinute.Add(stream.window.options.bucket)
+	stream.currentMinuteData = MinuteDeliveries{}
+}
+
+// function to complete the last minute at the end of the stream
+func (stream *eventsStream) close() {
+	if !stream.currentMinute.IsZero() {
+		stream.completeMinute()
+	}
+}
+
+// function to read a stream of events line by line and calculate the moving averages as they arrive
+// returns when the reader ends or fails, the last minute is only completed if the reader ends
+func streamEvents(ctx context.Context, reader io.Reader, options options, emit func(PrintableValues)) error {
+	var stream = newEventsStream(options, emit)
+	var scanner = bufio.NewScanner(reader)
+
+	for scanner.Scan() && ctx.Err() == nil {
+		stream.addLine(scanner.Bytes())
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	stream.close()
+	return ctx.Err()
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// function to listen for TCP connections and stream the moving averages of the events received in them
+// runs until the context is canceled
+func listenAndStreamTCP(ctx context.Context, options options, stdout io.Writer, stderr io.Writer) error {
+	listener, err := net.Listen("tcp", options.listenTCP)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(stderr, "listening for events on", listener.Addr())
+
+	output, closeOutput, err := createOutputWriter(stdout, options.outputFilePath, options.gzipOutput, nil)
+	if err != nil {
+		listener.Close()
+		return err
+	}
+
+	err = serveTCP(ctx, listener, options, output, stderr)
+
+	if closeError := closeOutput(); closeError != nil {
+		return closeError
+	}
+
+	return err
+}
+
+// function to accept the connections of the listener until the context is canceled
+// each connection is an independent stream, handled in its own goroutine
+// the complete minutes of every stream are written to the output and flushed right away, one stream at a time
+// with --autoflush they are flushed with its cadence instead
+func serveTCP(ctx context.Context, listener net.Listener, options options, output *bufio.Writer, stderr io.Writer) error {
+	// closing the listener stops the accept loop
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+
+	var outputMutex sync.Mutex
+
+	var rowsOutput io.Writer = output
+	var flusher *autoFlushWriter
+	if options.autoFlush.enabled() {
+		flusher = newAutoFlushWriter(output, options.autoFlush)
+		rowsOutput = flusher
+		defer flusher.stop()
+	}
+
+	// the open connections are waited for before returning, also when the listener fails, so nothing is written after it
+	// it is deferred after the flusher, so the flusher is stopped after the last connection
+	var connections sync.WaitGroup
+	defer connections.Wait()
+
+	for {
+		connection, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			return err
+		}
+
+		connections.Add(1)
+		go func() {
+			defer connections.Done()
+			defer connection.Close()
+
+			// the connection is counted with its duration when it ends (--metrics-addr)
+			start := time.Now()
+			defer func() { options.metrics.addConnection(time.Since(start)) }()
+
+			// the connection is closed when the program is interrupted, which stops reading from it
+			stop := context.AfterFunc(ctx, func() { connection.Close() })
+			defer stop()
+
+			var printer = newValuesPrinter(rowsOutput, options)
+			printer.warnings = stderr
+			err := streamEvents(ctx, connection, options, func(printableValues PrintableValues) {
+				outputMutex.Lock()
+				defer outputMutex.Unlock()
+
+				printer.print(printableValues)
+				if flusher == nil {
+					output.Flush()
+				}
+			})
+
+			if err != nil && ctx.Err() == nil {
+				fmt.Fprintln(stderr, "connection from", connection.RemoteAddr(), "failed:", err)
+			}
+		}()
+	}
+
+	return ctx.Err()
+}
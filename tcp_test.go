@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// buffer that can be written by the server and read by the test at the same time
+type syncBuffer struct {
+	mutex  sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buffer.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buffer.String()
+}
+
+func Test_serveTCP(t *testing.T) {
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	options, err := parseFlags([]string{"--window_size=10"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var console syncBuffer
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error)
+
+	go func() {
+		served <- serveTCP(ctx, listener, options, bufio.NewWriter(&console), io.Discard)
+	}()
+
+	connection, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the first event completes the minute before it
+	// and the second event completes the minutes up to its own
+	io.WriteString(connection, `{"timestamp": "2018-12-26 18:11:08.509654","duration": 20}`+"\n")
+	io.WriteString(connection, `{"timestamp": "2018-12-26 18:15:19.903159","duration": 31}`+"\n")
+	waitForOutput(t, &console, `{"date":"2018-12-26 18:11:00","average_delivery_time":0}
+{"date":"2018-12-26 18:12:00","average_delivery_time":20}
+{"date":"2018-12-26 18:13:00","average_delivery_time":20}
+{"date":"2018-12-26 18:14:00","average_delivery_time":20}
+{"date":"2018-12-26 18:15:00","average_delivery_time":20}
+`)
+
+	// closing the connection completes the last minute
+	io.WriteString(connection, `{"timestamp": "2018-12-26 18:23:19.903159","duration": 54}`+"\n")
+	connection.Close()
+	waitForOutput(t, &console, getConsoleOutput(t, "--input_file=./events.json"))
+
+	cancel()
+	if err := <-served; err != context.Canceled {
+		t.Errorf("Expected the server to stop when canceled, got %v", err)
+	}
+}
+
+// listener that accepts the first connection and then fails, when the fail channel is closed
+type failingListener struct {
+	net.Listener
+	accepted bool
+	fail     chan struct{}
+}
+
+func (listener *failingListener) Accept() (net.Conn, error) {
+	if !listener.accepted {
+		listener.accepted = true
+		return listener.Listener.Accept()
+	}
+
+	<-listener.fail
+	return nil, errors.New("accept failed")
+}
+
+func Test_serveTCP_AcceptError(t *testing.T) {
+
+	tcpListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener := &failingListener{Listener: tcpListener, fail: make(chan struct{})}
+	defer listener.Close()
+
+	options, err := parseFlags([]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var console syncBuffer
+	served := make(chan error)
+	go func() {
+		served <- serveTCP(context.Background(), listener, options, bufio.NewWriter(&console), io.Discard)
+	}()
+
+	connection, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(connection, `{"timestamp": "2018-12-26 18:11:08.509654","duration": 20}`+"\n")
+	io.WriteString(connection, `{"timestamp": "2018-12-26 18:15:19.903159","duration": 31}`+"\n")
+	io.WriteString(connection, `{"timestamp": "2018-12-26 18:23:19.903159","duration": 54}`+"\n")
+
+	// the listener fails while the connection is open, the server returns after the connection ends
+	close(listener.fail)
+	select {
+	case err := <-served:
+		t.Fatalf("Expected the server to wait for the open connection, returned %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// closing the connection completes the last minute before the server returns
+	connection.Close()
+	if err := <-served; err == nil || err.Error() != "accept failed" {
+		t.Errorf("Expected the error of the listener, got %v", err)
+	}
+	if expected := getConsoleOutput(t, "--input_file=./events.json"); console.String() != expected {
+		t.Errorf("Expected the whole output of the connection when the server returns, got %q", console.String())
+	}
+}
+
+// function to wait until the console has the expected output, or fail after a few seconds
+func waitForOutput(t *testing.T, console *syncBuffer, expected string) {
+
+	deadline := time.Now().Add(5 * time.Second)
+
+	for console.String() != expected {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected output %q, got %q", expected, console.String())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// struct with the result of the validation of the events
+// Valid_lines, Invalid_lines: number of lines that can and can't be used in the calculations, the empty lines are invalid
+// First_timestamp, Last_timestamp: earliest and latest timestamps of the valid lines, zero if there are none
+// First_error: error of the first invalid line, with its line number, nil if every line is valid
+type LineStats struct {
+	Valid_lines     int
+	Invalid_lines   int
+	First_timestamp time.Time
+	Last_timestamp  time.Time
+	First_error     error
+}
+
+// function to check the events of a stream, one JSON event per line, with the default names of the fields
+// the invalid lines are counted and don't stop the validation
+// returns an error only if the stream can't be read
+func Validate(reader io.Reader) (LineStats, error) {
+	return validateEvents(reader, nil, false, 0)
+}
+
+// function to check the events of a stream, reading the fields with the names of the field map
+// with strictSchema the events with unknown fields are also invalid
+// the first skipLines lines are not checked, like the header lines of --skip-lines
+func validateEvents(reader io.Reader, fieldMap fieldMap, strictSchema bool, skipLines int) (LineStats, error) {
+	var lineStats LineStats
+	var scanner = bufio.NewScanner(reader)
+	var lineNumber int
+
+	for lineNumber < skipLines && scanner.Scan() {
+		lineNumber++
+	}
+
+	for scanner.Scan() {
+		lineNumber++
+
+		// the lines are valid with the same rules used when calculating the averages
+		deliveredTranslation, err := parseDeliveredTranslation(scanner.Bytes(), fieldMap)
+		if err == nil && strictSchema {
+			err = checkEventSchema(scanner.Bytes(), fieldMap)
+		}
+		var eventTime time.Time
+		if err == nil {
+			eventTime, err = parseTimestamp(deliveredTranslation.Timestamp)
+		}
+
+		if err != nil {
+			if lineStats.First_error == nil {
+				lineStats.First_error = fmt.Errorf("line %d: %w", lineNumber, err)
+			}
+			lineStats.Invalid_lines++
+			continue
+		}
+
+		// the events don't need to be sorted, so the span is the earliest and latest timestamps
+		if lineStats.Valid_lines == 0 || eventTime.Before(lineStats.First_timestamp) {
+			lineStats.First_timestamp = eventTime
+		}
+		if lineStats.Valid_lines == 0 || eventTime.After(lineStats.Last_timestamp) {
+			lineStats.Last_timestamp = eventTime
+		}
+		lineStats.Valid_lines++
+	}
+
+	return lineStats, scanner.Err()
+}
+
+// function with the validate subcommand, that checks the input file instead of calculating the averages
+// prints the result of the validation to stdout, and returns the first error if there are invalid lines
+func runValidate(options options, stdout io.Writer) error {
+	file, err := os.Open(options.inputFilePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	lineStats, err := validateEvents(bufio.NewReaderSize(file, options.inputBufferSize), options.inputFieldMap, options.strictSchema, options.skipLines)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(stdout, "valid lines:", lineStats.Valid_lines)
+	fmt.Fprintln(stdout, "invalid lines:", lineStats.Invalid_lines)
+	if lineStats.Valid_lines > 0 {
+		fmt.Fprintln(stdout, "time span:", lineStats.First_timestamp.Format("2006-01-02 15:04:05"), "to", lineStats.Last_timestamp.Format("2006-01-02 15:04:05"))
+	}
+
+	if lineStats.First_error != nil {
+		return fmt.Errorf("%d invalid lines, the first is %w", lineStats.Invalid_lines, lineStats.First_error)
+	}
+	return nil
+}
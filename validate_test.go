@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+)
+
+func Test_Validate(t *testing.T) {
+
+	// a valid stream, with the events not sorted
+	lineStats, err := Validate(strings.NewReader(`{"timestamp": "2018-12-26 18:15:19.903159","duration": 31}
+{"timestamp": "2018-12-26 18:11:08.509654","duration": 20}
+{"timestamp": "2018-12-26 18:23:19","duration": 54}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lineStats.Valid_lines != 3 || lineStats.Invalid_lines != 0 || lineStats.First_error != nil {
+		t.Errorf("Expected 3 valid lines, got %+v", lineStats)
+	}
+	if lineStats.First_timestamp.Format("15:04:05") != "18:11:08" || lineStats.Last_timestamp.Format("15:04:05") != "18:23:19" {
+		t.Errorf("Expected the span from 18:11:08 to 18:23:19, got %s to %s", lineStats.First_timestamp, lineStats.Last_timestamp)
+	}
+
+	// a partially invalid stream, the first error has the line number
+	lineStats, err = Validate(strings.NewReader(`{"timestamp": "2018-12-26 18:11:08","duration": 20}
+not json
+{"timestamp": "yesterday","duration": 20}
+
+{"timestamp": "2018-12-26 18:12:08","duration": 20}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lineStats.Valid_lines != 2 || lineStats.Invalid_lines != 3 {
+		t.Errorf("Expected 2 valid and 3 invalid lines, got %+v", lineStats)
+	}
+	if lineStats.First_error == nil || !strings.HasPrefix(lineStats.First_error.Error(), "line 2:") {
+		t.Errorf("Expected the first error in the line 2, got %v", lineStats.First_error)
+	}
+
+	// an empty stream
+	lineStats, err = Validate(strings.NewReader(""))
+	if err != nil || lineStats != (LineStats{}) {
+		t.Errorf("Expected empty stats for an empty stream, got %+v and %v", lineStats, err)
+	}
+}
+
+func Test_main_Validate(t *testing.T) {
+
+	var console bytes.Buffer
+	if err := run(context.Background(), []string{"validate", "--input_file=./events.json"}, &console, io.Discard); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "valid lines: 3\ninvalid lines: 0\ntime span: 2018-12-26 18:11:08 to 2018-12-26 18:23:19\n"
+	if console.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, console.String())
+	}
+}
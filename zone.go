@@ -0,0 +1,36 @@
+package main
+
+import "time"
+
+// struct with the time zone of the dates of the output with --retain-input-tz, the offset of the first event of the input
+// the options are copied, so it is shared by a pointer like the metrics, the zone is only known when the first event is read
+type inputZone struct {
+	location *time.Location
+}
+
+// function to keep the offset of the timestamp of an event, if it is the first one
+// the timestamps without an offset are in UTC
+func (zone *inputZone) set(timestamp string) {
+	if zone == nil || zone.location != nil {
+		return
+	}
+
+	zone.location = time.UTC
+	if parsed, err := time.Parse(time.RFC3339Nano, timestamp); err == nil {
+		zone.location = parsed.Location()
+	}
+}
+
+// function to convert a date of the output, in UTC, to the zone of the input, in RFC 3339 like "2018-12-26T19:12:00+01:00"
+// the dates are returned as they are before the zone is known
+func (zone *inputZone) format(date string) string {
+	if zone == nil || zone.location == nil || date == "" {
+		return date
+	}
+
+	minute, err := time.Parse("2006-01-02 15:04:05", date)
+	if err != nil {
+		return date
+	}
+	return minute.In(zone.location).Format(time.RFC3339)
+}
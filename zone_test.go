@@ -0,0 +1,61 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_main_RetainInputTz(t *testing.T) {
+
+	// the sample events with the timestamps one hour ahead, in the +01:00 offset
+	events, err := os.ReadFile("./events.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var offsetEvents = regexp.MustCompile(`"timestamp": "([^"]+)"`).ReplaceAllStringFunc(string(events), func(field string) string {
+		timestamp, err := time.Parse("2006-01-02 15:04:05", strings.TrimSuffix(strings.TrimPrefix(field, `"timestamp": "`), `"`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return `"timestamp": "` + timestamp.In(time.FixedZone("", 3600)).Format(time.RFC3339Nano) + `"`
+	})
+	inputFilePath := filepath.Join(t.TempDir(), "events.json")
+	if err := os.WriteFile(inputFilePath, []byte(offsetEvents), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// without the flag the timestamps are converted to UTC, the output is the same as the sample events
+	expected := getContentFromConsole(t, "--input_file=./events.json", "--with-window-span")
+	output := getContentFromConsole(t, "--input_file="+inputFilePath, "--with-window-span")
+	if len(output) != len(expected) || output[0] != expected[0] || output[len(output)-1] != expected[len(expected)-1] {
+		t.Errorf("Expected the output of the sample events, got %v", output)
+	}
+
+	// with the flag the offset of the input round-trips to the dates of the output
+	output = getContentFromConsole(t, "--input_file="+inputFilePath, "--with-window-span", "--retain-input-tz")
+	if len(output) != len(expected) {
+		t.Fatalf("Expected %d minutes, got %v", len(expected), output)
+	}
+	for i, printableValues := range output {
+		date, err := time.Parse(time.RFC3339, printableValues.Date)
+		if err != nil || !strings.HasSuffix(printableValues.Date, "+01:00") || !strings.HasSuffix(printableValues.Window_start, "+01:00") {
+			t.Errorf("Expected the dates in the +01:00 offset, got %+v", printableValues)
+			continue
+		}
+		if date.UTC().Format("2006-01-02 15:04:05") != expected[i].Date || printableValues.Average_delivery_time != expected[i].Average_delivery_time {
+			t.Errorf("Expected the minute %+v, got %+v", expected[i], printableValues)
+		}
+	}
+	if output[0].Date != "2018-12-26T19:11:00+01:00" {
+		t.Errorf("Expected the first minute at 19:11 in the input offset, got %s", output[0].Date)
+	}
+
+	// the timestamps without an offset are in UTC
+	if output := getContentFromConsole(t, "--input_file=./events.json", "--retain-input-tz"); len(output) == 0 || output[0].Date != "2018-12-26T18:11:00Z" {
+		t.Errorf("Expected the dates in UTC, got %v", output)
+	}
+}